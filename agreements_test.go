@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"strconv"
+	"testing"
+)
+
+func TestAgreementFlow(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	budget := s.department(token, models.Department{Name: "Budget", ParentId: finance})
+	head := s.addEmployee("head@example.com", "DEP_HEAD", finance)
+	s.ok(s.do(http.MethodPut, "/department/heads", token, models.Department{Id: finance, HeadId: head}), nil)
+	headToken := s.login("head@example.com")
+
+	letter := s.letter(token, "Budget proposal")
+	s.ok(s.do(http.MethodPost, "/letters/agreement", token, models.Agreement{LetterId: letter, DepartmentId: budget}), nil)
+
+	var agreements []models.Agreement
+	s.ok(s.do(http.MethodPost, "/letters/agreements", headToken, nil), &agreements)
+	if len(agreements) != 0 {
+		t.Errorf("head sees %d sub-department agreements without subdepartments", len(agreements))
+	}
+
+	s.ok(s.do(http.MethodPost, "/letters/agreements?subdepartments=true", headToken, nil), &agreements)
+	if len(agreements) != 1 || agreements[0].Letter.Id != letter || agreements[0].Department.Id != budget {
+		t.Fatalf("unexpected inbox %+v", agreements)
+	}
+	if agreements[0].Viewed || agreements[0].Agreed {
+		t.Errorf("new agreement is already viewed or agreed %+v", agreements[0])
+	}
+
+	id := strconv.Itoa(agreements[0].Id)
+
+	// Fields are omitted when false, so every read decodes into a fresh value.
+	get := func() (agreement models.Agreement) {
+		s.ok(s.do(http.MethodPost, "/letters/agreement/"+id, headToken, nil), &agreement)
+		return agreement
+	}
+
+	agreement := get()
+	if !agreement.Viewed || agreement.Agreed {
+		t.Errorf("opened agreement is not viewed %+v", agreement)
+	}
+
+	// Only the heads whose inbox holds the agreement decide it.
+	s.addEmployee("clerk@example.com", "EMPLOYEE", budget)
+	clerk := s.login("clerk@example.com")
+	s.ok(s.do(http.MethodPost, "/letters/agreements?subdepartments=true", clerk, nil), &agreements)
+	if len(agreements) != 0 {
+		t.Errorf("employee without a head position sees %d agreements", len(agreements))
+	}
+	s.fails(s.do(http.MethodPost, "/letters/agreement/"+id+"/true", clerk, nil), apperr.Forbidden)
+	agreed := true
+	s.fails(s.do(http.MethodPatch, "/api/v2/agreements/"+id, clerk, models.AgreementDecision{Agreed: &agreed}), apperr.Forbidden)
+
+	// Nor does anyone outside it read or open it, except admins.
+	viewed := true
+	s.fails(s.do(http.MethodGet, "/api/v2/agreements/"+id, clerk, nil), apperr.Forbidden)
+	s.fails(s.do(http.MethodPatch, "/api/v2/agreements/"+id, clerk, models.AgreementDecision{Viewed: &viewed}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPost, "/letters/agreement/"+id, clerk, nil), apperr.Forbidden)
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements/"+id, token, nil), nil)
+	s.fails(s.do(http.MethodGet, "/api/v2/agreements/999", clerk, nil), apperr.AgreementNotFound)
+
+	s.ok(s.do(http.MethodPost, "/letters/agreement/"+id+"/true", headToken, nil), nil)
+	agreement = get()
+	if !agreement.Agreed {
+		t.Errorf("agreement was not agreed %+v", agreement)
+	}
+
+	// A decision is final.
+	s.fails(s.do(http.MethodPost, "/letters/agreement/"+id+"/false", headToken, nil), apperr.AgreementDecided)
+	agreement = get()
+	if !agreement.Agreed {
+		t.Errorf("decided agreement changed %+v", agreement)
+	}
+}
+
+func TestAgreementValidation(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	archived := s.department(token, models.Department{Name: "Archive"})
+	s.ok(s.do(http.MethodPut, "/department/archive", token, models.Department{Id: archived}), nil)
+	letter := s.letter(token, "Budget proposal")
+
+	tests := []struct {
+		name      string
+		agreement models.Agreement
+		code      apperr.Code
+	}{
+		{"unknown department", models.Agreement{LetterId: letter, DepartmentId: finance + 100}, apperr.DepartmentNotFound},
+		{"archived department", models.Agreement{LetterId: letter, DepartmentId: archived}, apperr.DepartmentArchived},
+		{"no department", models.Agreement{LetterId: letter}, apperr.DepartmentNotFound},
+		{"unknown letter", models.Agreement{LetterId: letter + 100, DepartmentId: finance}, apperr.LetterNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+			s.fails(s.do(http.MethodPost, "/letters/agreement", token, tt.agreement), tt.code)
+		})
+	}
+
+	s.fails(s.do(http.MethodPost, "/letters/agreement/"+strconv.Itoa(letter+100), token, nil), apperr.AgreementNotFound)
+}
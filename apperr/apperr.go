@@ -0,0 +1,189 @@
+// Package apperr is the catalog of errors the API reports to clients. Every
+// error carries a stable machine-readable code, the HTTP status it maps to
+// and a message that is safe to show to users. Causes are kept for logging
+// only and never leave the server.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"sed/models"
+)
+
+type Code string
+
+const (
+	Internal               Code = "INTERNAL"
+	MalformedRequest       Code = "MALFORMED_REQUEST"
+	MalformedQuery         Code = "MALFORMED_QUERY"
+	InvalidCursor          Code = "INVALID_CURSOR"
+	ValidationFailed       Code = "VALIDATION_FAILED"
+	Unauthorized           Code = "UNAUTHORIZED"
+	InvalidCredentials     Code = "INVALID_CREDENTIALS"
+	Forbidden              Code = "FORBIDDEN"
+	LetterNotFound         Code = "LETTER_NOT_FOUND"
+	EmployeeNotFound       Code = "EMPLOYEE_NOT_FOUND"
+	DepartmentNotFound     Code = "DEPARTMENT_NOT_FOUND"
+	AgreementNotFound      Code = "AGREEMENT_NOT_FOUND"
+	DepartmentArchived     Code = "DEPARTMENT_ARCHIVED"
+	DepartmentNotEmpty     Code = "DEPARTMENT_NOT_EMPTY"
+	DepartmentCycle        Code = "DEPARTMENT_CYCLE"
+	NotDepartmentStaff     Code = "NOT_DEPARTMENT_STAFF"
+	UnsupportedFormat      Code = "UNSUPPORTED_FORMAT"
+	ImportFileInvalid      Code = "IMPORT_FILE_INVALID"
+	ImportRejected         Code = "IMPORT_REJECTED"
+	NotReady               Code = "NOT_READY"
+	VersionRequired        Code = "VERSION_REQUIRED"
+	VersionConflict        Code = "VERSION_CONFLICT"
+	IdempotencyReused      Code = "IDEMPOTENCY_KEY_REUSED"
+	IdempotencyInUse       Code = "IDEMPOTENCY_KEY_IN_USE"
+	LetterRetained         Code = "LETTER_RETAINED"
+	LetterDestroyed        Code = "LETTER_DESTROYED"
+	LetterNotDue           Code = "LETTER_NOT_DUE"
+	DocumentTypeNotFound   Code = "DOCUMENT_TYPE_NOT_FOUND"
+	RetentionRuleNotFound  Code = "RETENTION_RULE_NOT_FOUND"
+	DestructionActNotFound Code = "DESTRUCTION_ACT_NOT_FOUND"
+	DestructionActStatus   Code = "DESTRUCTION_ACT_STATUS"
+	CaseNotFound           Code = "CASE_NOT_FOUND"
+	CaseClosed             Code = "CASE_CLOSED"
+	CaseIndexTaken         Code = "CASE_INDEX_TAKEN"
+	CaseYearMismatch       Code = "CASE_YEAR_MISMATCH"
+	AgreementDecided       Code = "AGREEMENT_DECIDED"
+	LetterInAct            Code = "LETTER_IN_ACT"
+)
+
+type entry struct {
+	status  int
+	message string
+}
+
+var catalog = map[Code]entry{
+	Internal:               {http.StatusInternalServerError, "internal server error"},
+	MalformedRequest:       {http.StatusBadRequest, "request body is not valid JSON"},
+	MalformedQuery:         {http.StatusBadRequest, "query string is not valid"},
+	InvalidCursor:          {http.StatusBadRequest, "cursor is not valid"},
+	ValidationFailed:       {http.StatusBadRequest, "request validation failed"},
+	Unauthorized:           {http.StatusUnauthorized, "missing or invalid bearer token"},
+	InvalidCredentials:     {http.StatusUnauthorized, "invalid email or password"},
+	Forbidden:              {http.StatusForbidden, "no access to this page"},
+	LetterNotFound:         {http.StatusNotFound, "letter not found"},
+	EmployeeNotFound:       {http.StatusNotFound, "employee not found"},
+	DepartmentNotFound:     {http.StatusNotFound, "department not found"},
+	AgreementNotFound:      {http.StatusNotFound, "agreement not found"},
+	DepartmentArchived:     {http.StatusConflict, "department is archived"},
+	DepartmentNotEmpty:     {http.StatusConflict, "department still has employees or active sub-departments, merge it instead"},
+	DepartmentCycle:        {http.StatusConflict, "department cannot be placed inside its own subtree"},
+	NotDepartmentStaff:     {http.StatusConflict, "employee does not belong to the department"},
+	UnsupportedFormat:      {http.StatusBadRequest, "unsupported format"},
+	ImportFileInvalid:      {http.StatusBadRequest, "import file could not be read"},
+	ImportRejected:         {http.StatusUnprocessableEntity, "import has validation errors"},
+	NotReady:               {http.StatusServiceUnavailable, "service is not ready"},
+	VersionRequired:        {http.StatusPreconditionRequired, "version or If-Match is required"},
+	VersionConflict:        {http.StatusPreconditionFailed, "version is out of date"},
+	IdempotencyReused:      {http.StatusUnprocessableEntity, "idempotency key reused with another request"},
+	IdempotencyInUse:       {http.StatusConflict, "idempotency key is still in use"},
+	LetterRetained:         {http.StatusConflict, "letter is kept in the trash until its retention period ends"},
+	LetterDestroyed:        {http.StatusConflict, "letter was destroyed under a destruction act"},
+	LetterNotDue:           {http.StatusConflict, "letter is not past its retention period or is already in a destruction act"},
+	DocumentTypeNotFound:   {http.StatusNotFound, "document type not found"},
+	RetentionRuleNotFound:  {http.StatusNotFound, "retention rule not found"},
+	DestructionActNotFound: {http.StatusNotFound, "destruction act not found"},
+	DestructionActStatus:   {http.StatusConflict, "destruction act is not in the status this step requires"},
+	CaseNotFound:           {http.StatusNotFound, "case not found"},
+	CaseClosed:             {http.StatusConflict, "case is closed"},
+	CaseIndexTaken:         {http.StatusConflict, "department already has a case with this index in this year"},
+	CaseYearMismatch:       {http.StatusConflict, "letter was registered in another year than the case"},
+	AgreementDecided:       {http.StatusConflict, "agreement is already decided"},
+	LetterInAct:            {http.StatusConflict, "letter is in a destruction act"},
+}
+
+// Error is an API error. Only Code, Status, Message and Fields are meant
+// for clients.
+type Error struct {
+	Code    Code
+	Status  int
+	Message string
+	Fields  []models.FieldError
+	cause   error
+}
+
+// New returns the catalog error for code. Unknown codes are reported as
+// internal errors.
+func New(code Code) *Error {
+	e, ok := catalog[code]
+	if !ok {
+		return Wrap(Internal, fmt.Errorf("unknown error code %q", code))
+	}
+
+	return &Error{Code: code, Status: e.status, Message: e.message}
+}
+
+// Wrap returns the catalog error for code with err kept as its cause.
+func Wrap(code Code, err error) *Error {
+	e := New(code)
+	e.cause = err
+	return e
+}
+
+// Field returns a validation error for a single field.
+func Field(field, rule string) *Error {
+	return New(ValidationFailed).WithFields(models.FieldError{Field: field, Rule: rule})
+}
+
+// Validation converts validator errors into a VALIDATION_FAILED error with
+// one entry per failed field. Any other error is treated as internal.
+func Validation(err error) *Error {
+	var failed validator.ValidationErrors
+	if !errors.As(err, &failed) {
+		return Wrap(Internal, err)
+	}
+
+	e := Wrap(ValidationFailed, err)
+	for _, field := range failed {
+		e.Fields = append(e.Fields, models.FieldError{
+			Field: field.Field(),
+			Rule:  field.Tag(),
+			Param: field.Param(),
+		})
+	}
+
+	return e
+}
+
+// WithMessage returns a copy of e with a more specific message. The message
+// is sent to clients, so it must never contain a cause.
+func (e *Error) WithMessage(message string) *Error {
+	copied := *e
+	copied.Message = message
+	return &copied
+}
+
+// WithFields returns a copy of e with fields appended.
+func (e *Error) WithFields(fields ...models.FieldError) *Error {
+	copied := *e
+	copied.Fields = append(append([]models.FieldError(nil), e.Fields...), fields...)
+	return &copied
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return string(e.Code) + ": " + e.cause.Error()
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// From returns err as an *Error, treating anything outside the catalog as
+// internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Wrap(Internal, err)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sed/apperr"
+	"sed/models"
+	"sed/repository"
+	"sed/repository/memory"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.addEmployee("admin@example.com", "ADMIN", 0)
+	token := s.login("admin@example.com")
+
+	id := s.letter(token, "Budget proposal")
+	var letter models.Letter
+	s.ok(s.do(http.MethodGet, "/api/v2/letters/"+strconv.Itoa(id), token, nil), &letter)
+	s.ok(s.do(http.MethodPatch, "/api/v2/letters/"+strconv.Itoa(id), token, map[string]interface{}{"sender": "Tax Committee", "version": letter.Version}), nil)
+	s.department(token, models.Department{Name: "Finance"})
+
+	var entries []models.AuditEntry
+	s.ok(s.do(http.MethodGet, "/api/v2/audit?entity=letter&entity_id="+strconv.Itoa(id), token, nil), &entries)
+	if len(entries) != 2 || entries[0].Action != "update" || entries[1].Action != "create" {
+		t.Fatalf("letter entries %+v", entries)
+	}
+
+	update := entries[0]
+	if update.ActorId != admin || update.IP == "" || update.RequestId == "" {
+		t.Errorf("update entry %+v", update)
+	}
+	if sender := update.Changes["sender"]; sender.Before != letter.Sender || sender.After != "Tax Committee" {
+		t.Errorf("sender change %+v", sender)
+	}
+	if _, ok := update.Changes["content"]; ok {
+		t.Errorf("unchanged content recorded %+v", update.Changes)
+	}
+	if digest, ok := entries[1].Changes["content_sha256"]; !ok || digest.After == "" || entries[1].Changes["content"].After != nil {
+		t.Errorf("content of the created letter recorded %+v", entries[1].Changes)
+	}
+
+	s.ok(s.do(http.MethodGet, "/api/v2/audit?actor_id="+strconv.Itoa(admin), token, nil), &entries)
+	if len(entries) != 3 {
+		t.Errorf("%d entries by the admin", len(entries))
+	}
+
+	s.ok(s.do(http.MethodGet, "/api/v2/audit?from="+url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339)), token, nil), &entries)
+	if len(entries) != 0 {
+		t.Errorf("%d entries from the future", len(entries))
+	}
+
+	s.invalid(s.do(http.MethodGet, "/api/v2/audit?entity=invoice", token, nil), "entity", "oneof")
+
+	var verification models.AuditVerification
+	s.ok(s.do(http.MethodGet, "/api/v2/audit/verify", token, nil), &verification)
+	if !verification.Valid || verification.Entries != 3 {
+		t.Errorf("verification %+v", verification)
+	}
+
+	s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+	s.fails(s.do(http.MethodGet, "/api/v2/audit", s.login("clerk@example.com"), nil), apperr.Forbidden)
+}
+
+func TestAuditClientIP(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		proxies []string
+		ip      string
+	}{
+		{"no trusted proxies", nil, "192.0.2.1"},
+		{"trusted proxy", []string{"192.0.2.0/24"}, "203.0.113.9"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.New()
+			settings := testSettings()
+			settings.HTTP.TrustedProxies = tt.proxies
+			s := &testServer{t: t, store: store, router: newTestRouter(store.Repositories(), settings)}
+			token := s.admin()
+
+			// httptest requests come from 192.0.2.1.
+			s.created(s.request(http.MethodPost, "/api/v2/departments", map[string]string{"Authorization": "Bearer " + token, "X-Forwarded-For": "203.0.113.9"},
+				models.Department{Name: "Finance"}), nil)
+
+			var entries []models.AuditEntry
+			s.ok(s.do(http.MethodGet, "/api/v2/audit?entity=department", token, nil), &entries)
+			if len(entries) != 1 || entries[0].IP != tt.ip {
+				t.Errorf("entries %+v, want ip %s", entries, tt.ip)
+			}
+		})
+	}
+}
+
+// brokenAudit fails every append, as a database gone away would.
+type brokenAudit struct {
+	repository.AuditRepository
+}
+
+func (brokenAudit) Append(context.Context, models.AuditEntry) error {
+	return errors.New("audit log unavailable")
+}
+
+func TestAuditFailure(t *testing.T) {
+	store := memory.New()
+	repositories := store.Repositories()
+	repositories.Audit = brokenAudit{repositories.Audit}
+	s := &testServer{t: t, store: store, router: newTestRouter(repositories, testSettings())}
+	token := s.admin()
+
+	// A change that is not in the audit log is not reported as done.
+	response := s.do(http.MethodPost, "/api/v2/departments", token, models.Department{Name: "Finance"})
+	s.fails(response, apperr.Internal)
+	if response.CorrelationId == "" {
+		t.Error("failure without a correlation id")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sed/apperr"
+	"sed/handlers"
+	"sed/models"
+	"sed/repository/memory"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	id := s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+
+	var payload struct {
+		Token string `json:"token"`
+		Role  string `json:"role"`
+		Id    int    `json:"id"`
+		Email string `json:"email"`
+	}
+	s.ok(s.do(http.MethodPost, "/login", "", models.Employee{Email: "clerk@example.com", Password: testPassword}), &payload)
+
+	if len(payload.Token) < 30 {
+		t.Errorf("token %q is too short", payload.Token)
+	}
+	if payload.Role != "EMPLOYEE" || payload.Id != id || payload.Email != "clerk@example.com" {
+		t.Errorf("unexpected login payload %+v", payload)
+	}
+
+	var profile models.Employee
+	s.ok(s.do(http.MethodPost, "/users/"+strconv.Itoa(id), payload.Token, nil), &profile)
+	if profile.Id != id || profile.Password != "" {
+		t.Errorf("unexpected profile %+v", profile)
+	}
+}
+
+func TestLoginFailures(t *testing.T) {
+	s := newTestServer(t)
+	s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+
+	tests := []struct {
+		name     string
+		employee models.Employee
+	}{
+		{"unknown email", models.Employee{Email: "nobody@example.com", Password: testPassword}},
+		{"empty email", models.Employee{Password: testPassword}},
+		{"wrong password", models.Employee{Email: "clerk@example.com", Password: "wrong"}},
+		{"empty password", models.Employee{Email: "clerk@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+			s.fails(s.do(http.MethodPost, "/login", "", tt.employee), apperr.InvalidCredentials)
+		})
+	}
+}
+
+func TestAuthorizationFailures(t *testing.T) {
+	s := newTestServer(t)
+	s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+	token := s.login("clerk@example.com")
+
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"missing header", ""},
+		{"no scheme", token},
+		{"extra parts", "Bearer " + token + " extra"},
+		{"wrong scheme", "Basic " + token},
+		{"short token", "Bearer abc"},
+		{"unknown token", "Bearer " + strings.Repeat("x", 40)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+			response := s.request(http.MethodPost, "/letters/types", map[string]string{"Authorization": tt.header}, nil)
+			s.fails(response, apperr.Unauthorized)
+			if len(response.Payload) != 0 {
+				t.Errorf("unexpected payload %s", response.Payload)
+			}
+		})
+	}
+}
+
+func TestTokenSignatureAndExpiry(t *testing.T) {
+	s := newTestServer(t)
+	s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+	token := s.login("clerk@example.com")
+	s.ok(s.do(http.MethodPost, "/letters/types", token, nil), nil)
+
+	settings := testSettings()
+	settings.Auth.JWTSecret = strings.Repeat("rotated", 5)
+	s.router = newTestRouter(s.store.Repositories(), settings)
+	s.fails(s.do(http.MethodPost, "/letters/types", token, nil), apperr.Unauthorized)
+
+	settings = testSettings()
+	settings.Auth.TokenTTL = -time.Minute
+	s.router = newTestRouter(s.store.Repositories(), settings)
+	s.fails(s.do(http.MethodPost, "/letters/types", s.login("clerk@example.com"), nil), apperr.Unauthorized)
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, route := range s.router.Routes() {
+		switch route.Path {
+		case "/ping", "/login", "/api/v2/sessions", "/healthz", "/readyz", "/openapi.json", "/docs/*file":
+			continue
+		}
+
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			s := s.with(t)
+			s.fails(s.do(route.Method, route.Path, "", nil), apperr.Unauthorized)
+		})
+	}
+}
+
+func TestAdminOnlyRoutes(t *testing.T) {
+	s := newTestServer(t)
+	id := s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+	token := s.login("clerk@example.com")
+
+	s.fails(s.do(http.MethodPut, "/user", token, models.Employee{Id: id, FullName: "Clerk"}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPost, "/users/"+strconv.Itoa(id+100), token, nil), apperr.Forbidden)
+}
+
+func TestLegacyStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		always bool
+		header string
+		status int
+	}{
+		{"real status", false, "", http.StatusUnauthorized},
+		{"legacy header", false, "true", http.StatusOK},
+		{"legacy header off", false, "false", http.StatusUnauthorized},
+		{"legacy everywhere", true, "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := testSettings()
+			settings.HTTP.LegacyStatus = tt.always
+			router := newTestRouter(memory.New().Repositories(), settings)
+
+			request := httptest.NewRequest(http.MethodPost, "/letters/types", nil)
+			if tt.header != "" {
+				request.Header.Set(handlers.LegacyStatusHeader, tt.header)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.status {
+				t.Errorf("status %d, want %d", recorder.Code, tt.status)
+			}
+
+			var response models.Response
+			err := json.Unmarshal(recorder.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Code != http.StatusUnauthorized {
+				t.Errorf("envelope code %d, want %d", response.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
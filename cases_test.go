@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"sed/apperr"
+	"sed/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nomenclatureCase creates a case through the API and returns its id.
+func (s *testServer) nomenclatureCase(token string, nomenclatureCase models.Case) int {
+	s.t.Helper()
+
+	var stored models.Case
+	s.created(s.do(http.MethodPost, "/api/v2/cases", token, nomenclatureCase), &stored)
+
+	return stored.Id
+}
+
+// file files the letter into the case.
+func (s *testServer) file(token string, letterId, caseId int) envelope {
+	s.t.Helper()
+
+	return s.do(http.MethodPut, "/api/v2/letters/"+strconv.Itoa(letterId)+"/case", token, models.LetterFiling{CaseId: caseId})
+}
+
+func TestCaseNomenclature(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	chancellery := s.department(token, models.Department{Name: "Chancellery"})
+	year := time.Now().Year()
+
+	s.invalid(s.do(http.MethodPost, "/api/v2/cases", token, models.Case{DepartmentId: chancellery, Year: year, Title: "Orders"}), "index", "required")
+
+	s.addEmployee("clerk@example.com", "EMPLOYEE", chancellery)
+	clerk := s.login("clerk@example.com")
+	s.fails(s.do(http.MethodPost, "/api/v2/cases", clerk,
+		models.Case{DepartmentId: chancellery, Year: year, Index: "01-05", Title: "Correspondence with ministries"}), apperr.Forbidden)
+
+	ministries := s.nomenclatureCase(token, models.Case{DepartmentId: chancellery, Year: year, Index: " 01-05 ",
+		Title: "Correspondence with ministries", RetentionYears: 5, Article: "Art. 23"})
+	s.fails(s.do(http.MethodPost, "/api/v2/cases", token,
+		models.Case{DepartmentId: chancellery, Year: year, Index: "01-05", Title: "Correspondence with agencies"}), apperr.CaseIndexTaken)
+	orders := s.nomenclatureCase(token, models.Case{DepartmentId: chancellery, Year: year, Index: "01-01", Title: "Orders"})
+	lastYear := s.nomenclatureCase(token, models.Case{DepartmentId: chancellery, Year: year - 1, Index: "01-05", Title: "Correspondence with ministries"})
+
+	first := s.letter(token, "Budget proposal")
+	second := s.letter(token, "Budget report")
+
+	s.fails(s.file(clerk, first, 9999), apperr.CaseNotFound)
+
+	// Only admins and the staff of the department file into its cases.
+	s.addEmployee("lawyer@example.com", "EMPLOYEE", s.department(token, models.Department{Name: "Legal"}))
+	s.fails(s.file(s.login("lawyer@example.com"), first, ministries), apperr.Forbidden)
+
+	s.fails(s.file(clerk, first, lastYear), apperr.CaseYearMismatch)
+
+	var filed models.Letter
+	s.ok(s.file(clerk, first, ministries), &filed)
+	if filed.CaseId != ministries || filed.CaseIndex != "01-05" || filed.Version != 2 {
+		t.Fatalf("filed letter %+v", filed)
+	}
+
+	var letters []models.Letter
+	s.ok(s.do(http.MethodGet, "/api/v2/letters?case_index=01-05", token, nil), &letters)
+	if len(letters) != 1 || letters[0].Id != first || letters[0].CaseIndex != "01-05" {
+		t.Fatalf("letters in 01-05 %+v", letters)
+	}
+
+	// Nothing is filed into or out of a closed case.
+	var closed models.Case
+	s.fails(s.do(http.MethodPost, "/api/v2/cases/"+strconv.Itoa(ministries)+"/close", clerk, nil), apperr.Forbidden)
+	s.ok(s.do(http.MethodPost, "/api/v2/cases/"+strconv.Itoa(ministries)+"/close", token, nil), &closed)
+	if closed.ClosedAt == nil || closed.Letters != 1 || closed.FirstEntryDate == nil {
+		t.Fatalf("closed case %+v", closed)
+	}
+	s.fails(s.file(clerk, second, ministries), apperr.CaseClosed)
+	s.fails(s.file(clerk, first, orders), apperr.CaseClosed)
+
+	s.ok(s.do(http.MethodPost, "/api/v2/cases/"+strconv.Itoa(ministries)+"/open", token, nil), nil)
+	s.ok(s.file(clerk, first, orders), nil)
+	s.ok(s.file(clerk, second, ministries), nil)
+
+	var cases []models.Case
+	s.ok(s.do(http.MethodGet, "/api/v2/cases?year="+strconv.Itoa(year)+"&sort=index", clerk, nil), &cases)
+	if len(cases) != 2 || cases[0].Id != orders || cases[0].Letters != 1 || cases[1].Id != ministries || cases[1].Letters != 1 {
+		t.Fatalf("cases of %d %+v", year, cases)
+	}
+
+	var entries []models.AuditEntry
+	s.ok(s.do(http.MethodGet, "/api/v2/audit?entity=case&entity_id="+strconv.Itoa(ministries)+"&sort=id", token, nil), &entries)
+	if len(entries) != 3 || entries[0].Action != "create" || entries[1].Action != "close" || entries[2].Action != "open" {
+		t.Errorf("case entries %+v", entries)
+	}
+	s.ok(s.do(http.MethodGet, "/api/v2/audit?entity=letter&entity_id="+strconv.Itoa(first), token, nil), &entries)
+	if len(entries) < 2 || entries[0].Action != "file" || entries[0].Changes["case_id"].After != float64(orders) {
+		t.Errorf("letter entries %+v", entries)
+	}
+}
+
+func TestCaseInventory(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	chancellery := s.department(token, models.Department{Name: "Chancellery"})
+	year := time.Now().Year()
+
+	ministries := s.nomenclatureCase(token, models.Case{DepartmentId: chancellery, Year: year, Index: "01-05",
+		Title: "Correspondence with ministries", RetentionYears: 5, Article: "Art. 23"})
+	s.nomenclatureCase(token, models.Case{DepartmentId: chancellery, Year: year, Index: "01-01", Title: "Orders"})
+	s.nomenclatureCase(token, models.Case{DepartmentId: chancellery, Year: year - 1, Index: "01-01", Title: "Orders"})
+	s.ok(s.file(token, s.letter(token, "Budget proposal"), ministries), nil)
+
+	path := "/api/v2/cases/inventory/"
+	query := "?department_id=" + strconv.Itoa(chancellery) + "&year=" + strconv.Itoa(year)
+
+	s.fails(s.do(http.MethodGet, path+"dot"+query, token, nil), apperr.UnsupportedFormat)
+	s.invalid(s.do(http.MethodGet, path+"json?department_id="+strconv.Itoa(chancellery), token, nil), "year", "required")
+	s.fails(s.do(http.MethodGet, path+"json?department_id=9999&year="+strconv.Itoa(year), token, nil), apperr.DepartmentNotFound)
+
+	var cases []models.Case
+	s.ok(s.do(http.MethodGet, path+"json"+query, token, nil), &cases)
+	if len(cases) != 2 || cases[0].Index != "01-01" || cases[1].Index != "01-05" {
+		t.Fatalf("inventory %+v", cases)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, path+"csv"+query, nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("status %d, content type %q", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+
+	rows, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][0] != "index" || rows[1][5] != "permanent" {
+		t.Fatalf("rows %q", rows)
+	}
+	if row := rows[2]; row[0] != "01-05" || row[2] != "1" || row[3] == "" || row[5] != "5" || row[6] != "Art. 23" {
+		t.Errorf("row %q", row)
+	}
+}
+
+func TestCaseRetention(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	documentTypeId := s.documentTypeId(token)
+	s.ok(s.do(http.MethodPut, "/api/v2/document-types/"+strconv.Itoa(documentTypeId)+"/retention", token, models.RetentionRule{Years: 5}), nil)
+	chancellery := s.department(token, models.Department{Name: "Chancellery"})
+
+	// The period of the case takes precedence over the rule of the
+	// document type: a permanent case keeps an overdue letter and a short
+	// one makes a recent letter due.
+	kept := s.oldLetter(documentTypeId, "Budget proposal", 6)
+	due := s.oldLetter(documentTypeId, "Budget report", 2)
+
+	var letter models.Letter
+	s.ok(s.do(http.MethodGet, "/api/v2/letters/"+strconv.Itoa(kept), token, nil), &letter)
+	permanent := s.nomenclatureCase(token, models.Case{DepartmentId: chancellery, Year: letter.EntryDate.Year(), Index: "01-01", Title: "Orders"})
+	s.ok(s.file(token, kept, permanent), nil)
+
+	s.ok(s.do(http.MethodGet, "/api/v2/letters/"+strconv.Itoa(due), token, nil), &letter)
+	short := s.nomenclatureCase(token, models.Case{DepartmentId: chancellery, Year: letter.EntryDate.Year(), Index: "01-09",
+		Title: "Invitations", RetentionYears: 1})
+	s.ok(s.file(token, due, short), nil)
+
+	var overdue []models.Letter
+	s.ok(s.do(http.MethodGet, "/api/v2/retention/overdue", token, nil), &overdue)
+	if len(overdue) != 1 || overdue[0].Id != due || overdue[0].RetainUntil == nil || overdue[0].RetainUntil.After(time.Now()) {
+		t.Fatalf("overdue %+v", overdue)
+	}
+
+	// Destroyed letters stay in their case but are never filed again.
+	var act models.DestructionAct
+	s.created(s.do(http.MethodPost, "/api/v2/destruction-acts", token, models.DestructionAct{DepartmentId: chancellery, LetterIds: []int{due}}), &act)
+	s.ok(s.do(http.MethodPut, "/department/heads", token,
+		models.Department{Id: chancellery, HeadId: s.addEmployee("head@example.com", "DEP_HEAD", chancellery)}), nil)
+	s.ok(s.do(http.MethodPost, "/api/v2/destruction-acts/"+strconv.Itoa(act.Id)+"/submit", token, nil), nil)
+	s.decideAll(s.login("head@example.com"), true)
+	s.ok(s.do(http.MethodPost, "/api/v2/destruction-acts/"+strconv.Itoa(act.Id)+"/execute", token, nil), nil)
+
+	s.fails(s.file(token, due, short), apperr.LetterDestroyed)
+	s.ok(s.do(http.MethodGet, "/api/v2/letters/"+strconv.Itoa(due), token, nil), &letter)
+	if letter.CaseIndex != "01-09" {
+		t.Errorf("destroyed letter %+v", letter)
+	}
+}
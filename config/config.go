@@ -0,0 +1,368 @@
+// Package config loads the server configuration. Every setting has a
+// default and can be overridden, from lowest to highest precedence, by a
+// YAML file, an environment variable and a command line flag.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v2"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnv names the environment variable holding the path of the YAML file.
+// The -config flag takes precedence over it.
+const FileEnv = "SED_CONFIG"
+
+type Config struct {
+	Database Database `yaml:"database"`
+	HTTP     HTTP     `yaml:"http"`
+	Auth     Auth     `yaml:"auth"`
+	Storage  Storage  `yaml:"storage"`
+	Mail     Mail     `yaml:"mail"`
+	Metrics  Metrics  `yaml:"metrics"`
+	Log      Log      `yaml:"log"`
+	Tracing  Tracing  `yaml:"tracing"`
+}
+
+type Database struct {
+	URL             string        `yaml:"url" validate:"required"`
+	MaxConns        int           `yaml:"max_conns" validate:"min=1"`
+	MinConns        int           `yaml:"min_conns" validate:"min=0,ltefield=MaxConns"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" validate:"min=0"`
+}
+
+type HTTP struct {
+	Address string `yaml:"address" validate:"required,hostname_port"`
+	// Mode is the gin mode: debug, release or test.
+	Mode string `yaml:"mode" validate:"oneof=debug release test"`
+	TLS  TLS    `yaml:"tls"`
+	// CORSOrigins lists the origins allowed to call the API; "*" allows any
+	// origin but then browsers do not send credentials.
+	CORSOrigins []string `yaml:"cors_origins" validate:"min=1,dive,eq=*|url"`
+	// TrustedProxies lists the addresses and networks of the reverse
+	// proxies whose X-Forwarded-For and X-Real-Ip name the client. With
+	// none, the client is the peer address.
+	TrustedProxies []string `yaml:"trusted_proxies" validate:"dive,ip|cidr"`
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// after SIGTERM before the server closes them.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" validate:"min=0"`
+	// LegacyStatus answers every request with HTTP 200 until all frontends
+	// read the real status codes.
+	LegacyStatus bool `yaml:"legacy_status"`
+	// IdempotencyTTL is how long the response to a request sent with an
+	// Idempotency-Key is replayed to retries.
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" validate:"gt=0"`
+}
+
+// TLS is enabled when both files are set.
+type TLS struct {
+	CertFile string `yaml:"cert_file" validate:"required_with=KeyFile,omitempty,file"`
+	KeyFile  string `yaml:"key_file" validate:"required_with=CertFile,omitempty,file"`
+}
+
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+type Auth struct {
+	JWTSecret string        `yaml:"jwt_secret" validate:"required,min=32"`
+	TokenTTL  time.Duration `yaml:"token_ttl" validate:"gt=0"`
+}
+
+type Storage struct {
+	// Dir keeps uploaded files.
+	Dir string `yaml:"dir" validate:"required"`
+	// MaxUploadSize limits request bodies with uploads, in bytes.
+	MaxUploadSize int64 `yaml:"max_upload_size" validate:"min=1"`
+	// TrashRetention is how long a deleted letter stays in the trash, where
+	// it can be restored, before it may be purged.
+	TrashRetention time.Duration `yaml:"trash_retention" validate:"gt=0"`
+}
+
+// Mail is the SMTP relay for notifications, disabled while Host is empty.
+type Mail struct {
+	Host     string `yaml:"host" validate:"omitempty,hostname|ip"`
+	Port     int    `yaml:"port" validate:"required_with=Host,omitempty,min=1,max=65535"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from" validate:"required_with=Host,omitempty,email"`
+}
+
+// Metrics serves GET /metrics to scrapers presenting Token as their bearer
+// credentials.
+type Metrics struct {
+	Enabled bool   `yaml:"enabled"`
+	Token   string `yaml:"token" validate:"required_with=Enabled,omitempty,min=16"`
+}
+
+// Log configures the JSON logs written to stderr.
+type Log struct {
+	Level string `yaml:"level" validate:"oneof=debug info warn error"`
+}
+
+// Tracing picks where OpenTelemetry spans go: nowhere, an OTLP/HTTP
+// collector, stdout or a file.
+type Tracing struct {
+	Exporter string `yaml:"exporter" validate:"oneof=none otlp stdout file"`
+	// Endpoint is the collector host:port; when empty the standard
+	// OTEL_EXPORTER_OTLP_ENDPOINT variable applies.
+	Endpoint    string  `yaml:"endpoint" validate:"omitempty,hostname_port"`
+	Insecure    bool    `yaml:"insecure"`
+	File        string  `yaml:"file" validate:"required_if=Exporter file"`
+	SampleRatio float64 `yaml:"sample_ratio" validate:"min=0,max=1"`
+	ServiceName string  `yaml:"service_name" validate:"required"`
+}
+
+// Default returns the settings used when nothing overrides them. It has no
+// JWT secret, which has to be configured explicitly.
+func Default() Config {
+	return Config{
+		Database: Database{
+			MaxConns:        10,
+			MaxConnLifetime: time.Hour,
+		},
+		HTTP: HTTP{
+			Address:         ":8080",
+			Mode:            "release",
+			CORSOrigins:     []string{"*"},
+			ShutdownTimeout: 15 * time.Second,
+			IdempotencyTTL:  24 * time.Hour,
+		},
+		Auth: Auth{
+			TokenTTL: 24 * time.Hour,
+		},
+		Storage: Storage{
+			Dir:            "storage",
+			MaxUploadSize:  32 << 20,
+			TrashRetention: 30 * 24 * time.Hour,
+		},
+		Mail: Mail{
+			Port: 587,
+		},
+		Log: Log{
+			Level: "info",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "sed",
+		},
+	}
+}
+
+// setting binds one field to its environment variable and flag. The flag
+// name is the YAML path of the field.
+type setting struct {
+	flag  string
+	env   []string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"database.url", []string{"SED_DATABASE_URL", "DATABASE_URL"}, "PostgreSQL connection string", stringValue(func(c *Config) *string { return &c.Database.URL })},
+	{"database.max_conns", []string{"SED_DATABASE_MAX_CONNS"}, "maximum size of the connection pool", intValue(func(c *Config) *int { return &c.Database.MaxConns })},
+	{"database.min_conns", []string{"SED_DATABASE_MIN_CONNS"}, "connections kept open when idle", intValue(func(c *Config) *int { return &c.Database.MinConns })},
+	{"database.max_conn_lifetime", []string{"SED_DATABASE_MAX_CONN_LIFETIME"}, "age after which a connection is closed", durationValue(func(c *Config) *time.Duration { return &c.Database.MaxConnLifetime })},
+	{"http.address", []string{"SED_HTTP_ADDRESS"}, "address to listen on, host:port", stringValue(func(c *Config) *string { return &c.HTTP.Address })},
+	{"http.mode", []string{"SED_HTTP_MODE", "GIN_MODE"}, "gin mode: debug, release or test", stringValue(func(c *Config) *string { return &c.HTTP.Mode })},
+	{"http.tls.cert_file", []string{"SED_HTTP_TLS_CERT_FILE"}, "TLS certificate, enables HTTPS with key_file", stringValue(func(c *Config) *string { return &c.HTTP.TLS.CertFile })},
+	{"http.tls.key_file", []string{"SED_HTTP_TLS_KEY_FILE"}, "TLS private key", stringValue(func(c *Config) *string { return &c.HTTP.TLS.KeyFile })},
+	{"http.cors_origins", []string{"SED_HTTP_CORS_ORIGINS"}, "comma separated origins allowed by CORS", listValue(func(c *Config) *[]string { return &c.HTTP.CORSOrigins })},
+	{"http.trusted_proxies", []string{"SED_HTTP_TRUSTED_PROXIES"}, "comma separated proxy addresses and networks trusted to name the client", listValue(func(c *Config) *[]string { return &c.HTTP.TrustedProxies })},
+	{"http.shutdown_timeout", []string{"SED_HTTP_SHUTDOWN_TIMEOUT"}, "time in-flight requests get to finish on shutdown", durationValue(func(c *Config) *time.Duration { return &c.HTTP.ShutdownTimeout })},
+	{"http.legacy_status", []string{"SED_HTTP_LEGACY_STATUS", "LEGACY_STATUS"}, "answer every request with HTTP 200", boolValue(func(c *Config) *bool { return &c.HTTP.LegacyStatus })},
+	{"http.idempotency_ttl", []string{"SED_HTTP_IDEMPOTENCY_TTL"}, "time responses are replayed to retries with the same Idempotency-Key", durationValue(func(c *Config) *time.Duration { return &c.HTTP.IdempotencyTTL })},
+	{"auth.jwt_secret", []string{"SED_AUTH_JWT_SECRET"}, "secret signing the access tokens, at least 32 bytes", stringValue(func(c *Config) *string { return &c.Auth.JWTSecret })},
+	{"auth.token_ttl", []string{"SED_AUTH_TOKEN_TTL"}, "lifetime of an access token", durationValue(func(c *Config) *time.Duration { return &c.Auth.TokenTTL })},
+	{"storage.dir", []string{"SED_STORAGE_DIR"}, "directory for uploaded files", stringValue(func(c *Config) *string { return &c.Storage.Dir })},
+	{"storage.max_upload_size", []string{"SED_STORAGE_MAX_UPLOAD_SIZE"}, "maximum upload size in bytes", int64Value(func(c *Config) *int64 { return &c.Storage.MaxUploadSize })},
+	{"storage.trash_retention", []string{"SED_STORAGE_TRASH_RETENTION"}, "time deleted letters stay restorable before they may be purged", durationValue(func(c *Config) *time.Duration { return &c.Storage.TrashRetention })},
+	{"mail.host", []string{"SED_MAIL_HOST"}, "SMTP host, mail is disabled when empty", stringValue(func(c *Config) *string { return &c.Mail.Host })},
+	{"mail.port", []string{"SED_MAIL_PORT"}, "SMTP port", intValue(func(c *Config) *int { return &c.Mail.Port })},
+	{"mail.username", []string{"SED_MAIL_USERNAME"}, "SMTP user name", stringValue(func(c *Config) *string { return &c.Mail.Username })},
+	{"mail.password", []string{"SED_MAIL_PASSWORD"}, "SMTP password", stringValue(func(c *Config) *string { return &c.Mail.Password })},
+	{"mail.from", []string{"SED_MAIL_FROM"}, "sender address of outgoing mail", stringValue(func(c *Config) *string { return &c.Mail.From })},
+	{"metrics.enabled", []string{"SED_METRICS_ENABLED"}, "serve Prometheus metrics on /metrics", boolValue(func(c *Config) *bool { return &c.Metrics.Enabled })},
+	{"metrics.token", []string{"SED_METRICS_TOKEN"}, "bearer token scrapers have to present, at least 16 bytes", stringValue(func(c *Config) *string { return &c.Metrics.Token })},
+	{"log.level", []string{"SED_LOG_LEVEL"}, "minimum level logged: debug, info, warn or error", stringValue(func(c *Config) *string { return &c.Log.Level })},
+	{"tracing.exporter", []string{"SED_TRACING_EXPORTER"}, "span exporter: none, otlp, stdout or file", stringValue(func(c *Config) *string { return &c.Tracing.Exporter })},
+	{"tracing.endpoint", []string{"SED_TRACING_ENDPOINT"}, "OTLP/HTTP collector host:port", stringValue(func(c *Config) *string { return &c.Tracing.Endpoint })},
+	{"tracing.insecure", []string{"SED_TRACING_INSECURE"}, "send spans to the collector without TLS", boolValue(func(c *Config) *bool { return &c.Tracing.Insecure })},
+	{"tracing.file", []string{"SED_TRACING_FILE"}, "file the file exporter appends spans to", stringValue(func(c *Config) *string { return &c.Tracing.File })},
+	{"tracing.sample_ratio", []string{"SED_TRACING_SAMPLE_RATIO"}, "share of new traces recorded, 0 to 1", floatValue(func(c *Config) *float64 { return &c.Tracing.SampleRatio })},
+	{"tracing.service_name", []string{"SED_TRACING_SERVICE_NAME", "OTEL_SERVICE_NAME"}, "service name reported with the spans", stringValue(func(c *Config) *string { return &c.Tracing.ServiceName })},
+}
+
+// Load builds the configuration from the defaults, the YAML file, the
+// environment and the flags in args, in that order. It does not validate
+// the result, see Validate.
+func Load(args []string) (Config, error) {
+	flags := flag.NewFlagSet("sed", flag.ContinueOnError)
+	file := flags.String("config", os.Getenv(FileEnv), "YAML configuration file, also "+FileEnv)
+	for _, s := range settings {
+		flags.String(s.flag, "", fmt.Sprintf("%s (env %s)", s.usage, strings.Join(s.env, ", ")))
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+	if flags.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	config := Default()
+
+	if *file != "" {
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return Config{}, err
+		}
+
+		err = yaml.UnmarshalStrict(data, &config)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", *file, err)
+		}
+	}
+
+	for _, s := range settings {
+		for _, env := range s.env {
+			value, ok := os.LookupEnv(env)
+			if !ok {
+				continue
+			}
+
+			err = s.set(&config, value)
+			if err != nil {
+				return Config{}, fmt.Errorf("%s: %w", env, err)
+			}
+			break
+		}
+	}
+
+	// Heroku and similar platforms only tell the port.
+	if port := os.Getenv("PORT"); port != "" && os.Getenv("SED_HTTP_ADDRESS") == "" {
+		config.HTTP.Address = ":" + port
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && err == nil {
+				err = s.set(&config, f.Value.String())
+				if err != nil {
+					err = fmt.Errorf("-%s: %w", f.Name, err)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// Validate checks every setting and reports all problems at once, by their
+// YAML path.
+func (c Config) Validate() error {
+	return validate(c)
+}
+
+// Validate checks the database settings only, for commands that do not
+// serve HTTP.
+func (d Database) Validate() error {
+	return validate(d)
+}
+
+func validate(value interface{}) error {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.SplitN(field.Tag.Get("yaml"), ",", 2)[0]
+	})
+
+	err := validate.Struct(value)
+
+	var fields validator.ValidationErrors
+	if !errors.As(err, &fields) {
+		return err
+	}
+
+	problems := make([]string, 0, len(fields))
+	for _, field := range fields {
+		path := field.Namespace()
+		if i := strings.Index(path, "."); i >= 0 {
+			path = path[i+1:]
+		}
+
+		problem := path + " fails " + field.Tag()
+		if field.Param() != "" {
+			problem += "=" + field.Param()
+		}
+		problems = append(problems, problem)
+	}
+
+	return fmt.Errorf("invalid configuration: %s", strings.Join(problems, ", "))
+}
+
+func stringValue(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func listValue(field func(c *Config) *[]string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func intValue(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) (err error) {
+		*field(c), err = strconv.Atoi(value)
+		return err
+	}
+}
+
+func int64Value(field func(c *Config) *int64) func(c *Config, value string) error {
+	return func(c *Config, value string) (err error) {
+		*field(c), err = strconv.ParseInt(value, 10, 64)
+		return err
+	}
+}
+
+func floatValue(field func(c *Config) *float64) func(c *Config, value string) error {
+	return func(c *Config, value string) (err error) {
+		*field(c), err = strconv.ParseFloat(value, 64)
+		return err
+	}
+}
+
+func boolValue(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) (err error) {
+		*field(c), err = strconv.ParseBool(value)
+		return err
+	}
+}
+
+func durationValue(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) (err error) {
+		*field(c), err = time.ParseDuration(value)
+		return err
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const secret = "test-secret-test-secret-test-secret"
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sed.yaml")
+	err := os.WriteFile(file, []byte(`
+database:
+  url: postgres://file/sed
+  max_conns: 20
+http:
+  address: ":9000"
+  cors_origins: [https://sed.example.com]
+auth:
+  jwt_secret: `+secret+`
+  token_ttl: 2h
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(FileEnv, file)
+	t.Setenv("DATABASE_URL", "postgres://env/sed")
+	t.Setenv("SED_DATABASE_MAX_CONNS", "30")
+
+	config, err := Load([]string{"-database.max_conns", "40", "-http.cors_origins", "https://a.example.com, https://b.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Database.URL != "postgres://env/sed" {
+		t.Errorf("env did not override the file: %q", config.Database.URL)
+	}
+	if config.Database.MaxConns != 40 {
+		t.Errorf("flag did not override env: %d", config.Database.MaxConns)
+	}
+	if config.HTTP.Address != ":9000" || config.Auth.TokenTTL != 2*time.Hour {
+		t.Errorf("file settings were not applied %+v", config)
+	}
+	if strings.Join(config.HTTP.CORSOrigins, " ") != "https://a.example.com https://b.example.com" {
+		t.Errorf("unexpected origins %v", config.HTTP.CORSOrigins)
+	}
+	if config.Storage.MaxUploadSize != Default().Storage.MaxUploadSize {
+		t.Errorf("default was lost: %d", config.Storage.MaxUploadSize)
+	}
+
+	err = config.Validate()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"unknown flag", []string{"-http.port", "80"}, nil},
+		{"bad flag value", []string{"-database.max_conns", "many"}, nil},
+		{"bad env value", nil, map[string]string{"SED_AUTH_TOKEN_TTL": "a day"}},
+		{"missing file", nil, map[string]string{FileEnv: "missing.yaml"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			_, err := Load(tt.args)
+			if err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Default()
+	valid.Database.URL = "postgres://localhost/sed"
+	valid.Auth.JWTSecret = secret
+
+	err := valid.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		problem string
+	}{
+		{"no database", func(c *Config) { c.Database.URL = "" }, "database.url fails required"},
+		{"empty pool", func(c *Config) { c.Database.MaxConns = 0 }, "database.max_conns fails min=1"},
+		{"short secret", func(c *Config) { c.Auth.JWTSecret = "secret" }, "auth.jwt_secret fails min=32"},
+		{"no ttl", func(c *Config) { c.Auth.TokenTTL = 0 }, "auth.token_ttl fails gt=0"},
+		{"bad address", func(c *Config) { c.HTTP.Address = "localhost" }, "http.address fails hostname_port"},
+		{"bad origin", func(c *Config) { c.HTTP.CORSOrigins = []string{"example.com"} }, "http.cors_origins[0] fails eq=*|url"},
+		{"bad proxy", func(c *Config) { c.HTTP.TrustedProxies = []string{"proxy.example.com"} }, "http.trusted_proxies[0] fails ip|cidr"},
+		{"half tls", func(c *Config) { c.HTTP.TLS.KeyFile = "key.pem" }, "http.tls.cert_file fails required_with=KeyFile"},
+		{"open metrics", func(c *Config) { c.Metrics.Enabled = true }, "metrics.token fails required_with=Enabled"},
+		{"trace file without path", func(c *Config) { c.Tracing.Exporter = "file" }, "tracing.file fails required_if=Exporter file"},
+		{"unknown exporter", func(c *Config) { c.Tracing.Exporter = "jaeger" }, "tracing.exporter fails oneof=none otlp stdout file"},
+		{"mail without sender", func(c *Config) { c.Mail.Host = "smtp.example.com" }, "mail.from fails required_with=Host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.change(&config)
+
+			err := config.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("got %v, want %q", err, tt.problem)
+			}
+		})
+	}
+}
@@ -2,13 +2,27 @@ package db
 
 import (
 	"context"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"os"
+	"sed/config"
+	"sed/tracing"
 )
 
-var Pool *pgxpool.Pool
+// Connect opens a connection pool sized by settings.
+func Connect(ctx context.Context, settings config.Database) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(settings.URL)
+	if err != nil {
+		return nil, err
+	}
 
-func Connect() (err error) {
-	Pool, err = pgxpool.Connect(context.Background(), os.Getenv("DATABASE_URL"))
-	return err
+	poolConfig.MaxConns = int32(settings.MaxConns)
+	poolConfig.MinConns = int32(settings.MinConns)
+	poolConfig.MaxConnLifetime = settings.MaxConnLifetime
+
+	// Queries are reported at the info level; the tracer turns them into
+	// spans of the request that sent them.
+	poolConfig.ConnConfig.Logger = tracing.QueryTracer{}
+	poolConfig.ConnConfig.LogLevel = pgx.LogLevelInfo
+
+	return pgxpool.ConnectConfig(ctx, poolConfig)
 }
@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the advisory lock held while migrating, so that
+// instances starting at the same time apply each migration only once.
+const migrationLockKey = 7_391_002_026
+
+type Migration struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	up        string
+	down      string
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		}
+
+		if direction == "up" {
+			migration.up = string(data)
+		} else {
+			migration.down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrate applies every pending migration.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	return withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		migrations, applied, err := migrationState(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err = runMigration(ctx, conn, migration.up,
+				`insert into schema_migrations (version, name) values ($1, $2);`,
+				migration.Version, migration.Name,
+			)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// MigrateDown reverts the last steps applied migrations.
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, steps int) error {
+	return withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		migrations, applied, err := migrationState(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.down == "" {
+				return fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+			}
+
+			err = runMigration(ctx, conn, migration.down,
+				`delete from schema_migrations where version = $1;`,
+				migration.Version,
+			)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// MigrationStatus lists every known migration with the time it was applied, if any.
+func MigrationStatus(ctx context.Context, pool *pgxpool.Pool) ([]Migration, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	migrations, applied, err := migrationState(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range migrations {
+		if appliedAt, ok := applied[migrations[i].Version]; ok {
+			migrations[i].AppliedAt = &appliedAt
+		}
+	}
+
+	return migrations, nil
+}
+
+func withMigrationLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `select pg_advisory_lock($1);`, migrationLockKey)
+	if err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), `select pg_advisory_unlock($1);`, migrationLockKey)
+
+	_, err = conn.Exec(
+		ctx,
+		`create table if not exists schema_migrations
+(
+    version    integer primary key,
+    name       varchar(255) not null,
+    applied_at timestamptz  not null default now()
+);`,
+	)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func migrationState(ctx context.Context, conn *pgxpool.Conn) ([]Migration, map[int]time.Time, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied := make(map[int]time.Time)
+
+	rows, err := conn.Query(
+		ctx,
+		`select version, applied_at
+from schema_migrations;`,
+	)
+	if err != nil {
+		var pgErr interface{ SQLState() string }
+		if errors.As(err, &pgErr) && pgErr.SQLState() == "42P01" {
+			// schema_migrations does not exist yet, nothing has been applied.
+			return migrations, applied, nil
+		}
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		version, appliedAt := 0, time.Time{}
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return migrations, applied, rows.Err()
+}
+
+func runMigration(ctx context.Context, conn *pgxpool.Conn, script, record string, args ...interface{}) error {
+	return conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, script)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, record, args...)
+		return err
+	})
+}
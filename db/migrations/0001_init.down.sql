@@ -0,0 +1,7 @@
+drop table if exists agreements;
+drop table if exists described_letters;
+drop table if exists letters;
+drop table if exists employees;
+drop table if exists departments;
+drop table if exists document_type;
+drop table if exists role_group;
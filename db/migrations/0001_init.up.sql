@@ -0,0 +1,69 @@
+create table if not exists role_group
+(
+    id   serial primary key,
+    role varchar(64) not null
+);
+
+create table if not exists document_type
+(
+    id   serial primary key,
+    type varchar(255) not null
+);
+
+create table if not exists departments
+(
+    id              serial primary key,
+    name            varchar(255) not null,
+    internal_number varchar(64)  not null default '',
+    phone           varchar(64)  not null default ''
+);
+
+create table if not exists employees
+(
+    id            serial primary key,
+    full_name     varchar(255) not null,
+    role_id       integer references role_group (id),
+    email         varchar(255) not null unique,
+    password      varchar(255) not null default '',
+    token         text,
+    department_id integer references departments (id)
+);
+
+create index if not exists employees_token_idx on employees (token);
+create index if not exists employees_department_id_idx on employees (department_id);
+
+create table if not exists letters
+(
+    id                  serial primary key,
+    name                varchar(255) not null,
+    sender              varchar(255) not null,
+    document_type_id    integer references document_type (id),
+    registration_number varchar(64),
+    entry_date          timestamptz,
+    outgoing_number     varchar(64),
+    distribution_date   timestamptz,
+    content             text         not null default ''
+);
+
+create table if not exists described_letters
+(
+    id                 serial primary key,
+    letter_id          integer not null references letters (id),
+    department_id      integer not null references departments (id),
+    executive_employee integer references employees (id)
+);
+
+create index if not exists described_letters_letter_id_idx on described_letters (letter_id);
+create index if not exists described_letters_department_id_idx on described_letters (department_id);
+
+create table if not exists agreements
+(
+    id            serial primary key,
+    department_id integer not null references departments (id),
+    letter_id     integer not null references letters (id),
+    viewed        boolean not null default false,
+    agreed        boolean not null default false,
+    agreed_at     timestamptz
+);
+
+create index if not exists agreements_department_id_idx on agreements (department_id);
@@ -0,0 +1,2 @@
+alter table departments
+    drop column if exists parent_id;
@@ -0,0 +1,4 @@
+alter table departments
+    add column if not exists parent_id integer references departments (id);
+
+create index if not exists departments_parent_id_idx on departments (parent_id);
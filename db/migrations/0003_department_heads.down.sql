@@ -0,0 +1,6 @@
+alter table employees
+    drop column if exists position;
+
+alter table departments
+    drop column if exists deputy_id,
+    drop column if exists head_id;
@@ -0,0 +1,6 @@
+alter table departments
+    add column if not exists head_id   integer references employees (id),
+    add column if not exists deputy_id integer references employees (id);
+
+alter table employees
+    add column if not exists position varchar(255) not null default '';
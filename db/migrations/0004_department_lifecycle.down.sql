@@ -0,0 +1,5 @@
+drop table if exists employee_transfers;
+
+alter table departments
+    drop column if exists merged_into_id,
+    drop column if exists archived_at;
@@ -0,0 +1,15 @@
+alter table departments
+    add column if not exists archived_at    timestamptz,
+    add column if not exists merged_into_id integer references departments (id);
+
+create table if not exists employee_transfers
+(
+    id                 serial primary key,
+    employee_id        integer     not null references employees (id),
+    from_department_id integer references departments (id),
+    to_department_id   integer references departments (id),
+    transferred_by     integer references employees (id),
+    transferred_at     timestamptz not null default now()
+);
+
+create index if not exists employee_transfers_employee_id_idx on employee_transfers (employee_id);
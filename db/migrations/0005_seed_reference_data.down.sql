@@ -0,0 +1,9 @@
+delete
+from document_type dt
+where dt.type in ('Incoming letter', 'Outgoing letter', 'Internal memo', 'Order', 'Citizen appeal')
+  and not exists(select 1 from letters l where l.document_type_id = dt.id);
+
+delete
+from role_group rg
+where rg.role in ('ADMIN', 'DEP_HEAD', 'EMPLOYEE')
+  and not exists(select 1 from employees e where e.role_id = rg.id);
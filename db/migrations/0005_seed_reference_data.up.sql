@@ -0,0 +1,13 @@
+insert into role_group (role)
+select v.role
+from (values ('ADMIN'), ('DEP_HEAD'), ('EMPLOYEE')) v(role)
+where not exists(select 1 from role_group rg where rg.role = v.role);
+
+insert into document_type (type)
+select v.type
+from (values ('Incoming letter'),
+             ('Outgoing letter'),
+             ('Internal memo'),
+             ('Order'),
+             ('Citizen appeal')) v(type)
+where not exists(select 1 from document_type dt where dt.type = v.type);
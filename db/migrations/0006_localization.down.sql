@@ -0,0 +1,8 @@
+alter table role_group
+    drop column if exists labels;
+
+alter table document_type
+    drop column if exists labels;
+
+alter table employees
+    drop column if exists language;
@@ -0,0 +1,26 @@
+alter table employees
+    add column if not exists language varchar(2) not null default '';
+
+alter table document_type
+    add column if not exists labels jsonb not null default '{}';
+
+alter table role_group
+    add column if not exists labels jsonb not null default '{}';
+
+update document_type dt
+set labels = v.labels::jsonb
+from (values ('Incoming letter', '{"en": "Incoming letter", "ru": "Входящее письмо", "uz": "Kiruvchi xat"}'),
+             ('Outgoing letter', '{"en": "Outgoing letter", "ru": "Исходящее письмо", "uz": "Chiquvchi xat"}'),
+             ('Internal memo', '{"en": "Internal memo", "ru": "Служебная записка", "uz": "Xizmat xati"}'),
+             ('Order', '{"en": "Order", "ru": "Приказ", "uz": "Buyruq"}'),
+             ('Citizen appeal', '{"en": "Citizen appeal", "ru": "Обращение граждан", "uz": "Fuqarolar murojaati"}')) v(type, labels)
+where dt.type = v.type
+  and dt.labels = '{}';
+
+update role_group rg
+set labels = v.labels::jsonb
+from (values ('ADMIN', '{"en": "Administrator", "ru": "Администратор", "uz": "Administrator"}'),
+             ('DEP_HEAD', '{"en": "Head of department", "ru": "Начальник отдела", "uz": "Bo''lim boshlig''i"}'),
+             ('EMPLOYEE', '{"en": "Employee", "ru": "Сотрудник", "uz": "Xodim"}')) v(role, labels)
+where rg.role = v.role
+  and rg.labels = '{}';
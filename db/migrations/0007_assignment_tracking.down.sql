@@ -0,0 +1,7 @@
+drop index if exists described_letters_due_date_idx;
+
+alter table agreements
+    drop column if exists decided_at;
+
+alter table described_letters
+    drop column if exists due_date;
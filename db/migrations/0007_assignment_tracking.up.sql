@@ -0,0 +1,7 @@
+alter table described_letters
+    add column if not exists due_date timestamptz;
+
+alter table agreements
+    add column if not exists decided_at timestamptz;
+
+create index if not exists described_letters_due_date_idx on described_letters (due_date) where due_date is not null;
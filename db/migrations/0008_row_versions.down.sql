@@ -0,0 +1,5 @@
+alter table departments
+    drop column if exists version;
+
+alter table letters
+    drop column if exists version;
@@ -0,0 +1,5 @@
+alter table letters
+    add column if not exists version integer not null default 1;
+
+alter table departments
+    add column if not exists version integer not null default 1;
@@ -0,0 +1,14 @@
+create table if not exists idempotency_keys
+(
+    employee_id  integer     not null references employees (id),
+    key          text        not null,
+    request_hash text        not null,
+    status       integer     not null default 0,
+    headers      jsonb       not null default '{}',
+    body         bytea,
+    created_at   timestamptz not null default now(),
+    expires_at   timestamptz not null,
+    primary key (employee_id, key)
+);
+
+create index if not exists idempotency_keys_expires_at_idx on idempotency_keys (expires_at);
@@ -0,0 +1,34 @@
+create table if not exists audit_log
+(
+    id            serial primary key,
+    actor_id      integer references employees (id),
+    action        varchar(64)  not null,
+    entity        varchar(64)  not null,
+    entity_id     integer      not null,
+    changes       jsonb        not null default '{}',
+    ip            varchar(64)  not null default '',
+    request_id    varchar(128) not null default '',
+    created_at    timestamptz  not null,
+    previous_hash varchar(64)  not null default '',
+    hash          varchar(64)  not null
+);
+
+create index if not exists audit_log_entity_idx on audit_log (entity, entity_id);
+create index if not exists audit_log_actor_id_idx on audit_log (actor_id);
+create index if not exists audit_log_created_at_idx on audit_log (created_at);
+
+-- The log is append-only: rows can be neither changed nor removed.
+create or replace function audit_log_append_only() returns trigger
+    language plpgsql as
+$$
+begin
+    raise exception 'audit_log is append-only';
+end;
+$$;
+
+drop trigger if exists audit_log_append_only on audit_log;
+create trigger audit_log_append_only
+    before update or delete
+    on audit_log
+    for each row
+execute function audit_log_append_only();
@@ -0,0 +1,6 @@
+drop index if exists letters_deleted_at_idx;
+
+alter table letters
+    drop column if exists delete_reason,
+    drop column if exists deleted_by,
+    drop column if exists deleted_at;
@@ -0,0 +1,6 @@
+alter table letters
+    add column if not exists deleted_at    timestamptz,
+    add column if not exists deleted_by    integer references employees (id),
+    add column if not exists delete_reason text;
+
+create index if not exists letters_deleted_at_idx on letters (deleted_at) where deleted_at is not null;
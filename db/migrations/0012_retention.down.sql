@@ -0,0 +1,12 @@
+alter table letters
+    drop column if exists destruction_act_id,
+    drop column if exists destroyed_at;
+
+alter table agreements
+    drop column if exists destruction_act_id;
+
+drop table if exists destruction_act_letters;
+
+drop table if exists destruction_acts;
+
+drop table if exists retention_rules;
@@ -0,0 +1,35 @@
+create table if not exists retention_rules
+(
+    id               serial primary key,
+    document_type_id integer not null unique references document_type (id),
+    years            integer not null check (years > 0),
+    article          text    not null default ''
+);
+
+create table if not exists destruction_acts
+(
+    id            serial primary key,
+    department_id integer     not null references departments (id),
+    status        text        not null default 'draft',
+    note          text        not null default '',
+    created_by    integer references employees (id),
+    created_at    timestamptz not null default now(),
+    submitted_at  timestamptz,
+    executed_at   timestamptz
+);
+
+create table if not exists destruction_act_letters
+(
+    act_id    integer not null references destruction_acts (id),
+    letter_id integer not null references letters (id),
+    primary key (act_id, letter_id)
+);
+
+create index if not exists destruction_act_letters_letter_id_idx on destruction_act_letters (letter_id);
+
+alter table agreements
+    add column if not exists destruction_act_id integer references destruction_acts (id);
+
+alter table letters
+    add column if not exists destroyed_at       timestamptz,
+    add column if not exists destruction_act_id integer references destruction_acts (id);
@@ -0,0 +1,6 @@
+drop index if exists letters_case_id_idx;
+
+alter table letters
+    drop column if exists case_id;
+
+drop table if exists cases;
@@ -0,0 +1,20 @@
+create table if not exists cases
+(
+    id              serial primary key,
+    department_id   integer     not null references departments (id),
+    year            integer     not null,
+    index           text        not null,
+    title           text        not null,
+    retention_years integer     not null default 0 check (retention_years >= 0),
+    article         text        not null default '',
+    created_at      timestamptz not null default now(),
+    closed_at       timestamptz,
+    unique (department_id, year, index)
+);
+
+create index if not exists cases_index_idx on cases (index);
+
+alter table letters
+    add column if not exists case_id integer references cases (id);
+
+create index if not exists letters_case_id_idx on letters (case_id);
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"strconv"
+	"testing"
+)
+
+func TestDepartmentTree(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance", InternalNumber: "100"})
+	s.department(token, models.Department{Name: "Treasury", ParentId: finance})
+	budget := s.department(token, models.Department{Name: "Budget", ParentId: finance})
+	legal := s.department(token, models.Department{Name: "Legal"})
+
+	var tree []models.Department
+	s.ok(s.do(http.MethodPost, "/departments/tree", token, nil), &tree)
+
+	if len(tree) != 2 || tree[0].Name != "Finance" || tree[1].Name != "Legal" {
+		t.Fatalf("unexpected roots %+v", tree)
+	}
+	if len(tree[0].Children) != 2 || tree[0].Children[0].Name != "Budget" || tree[0].Children[1].Name != "Treasury" {
+		t.Fatalf("unexpected children %+v", tree[0].Children)
+	}
+
+	s.ok(s.do(http.MethodPut, "/department/move", token, models.Department{Id: budget, ParentId: legal}), nil)
+
+	s.ok(s.do(http.MethodPost, "/departments/tree", token, nil), &tree)
+	if len(tree[0].Children) != 1 || len(tree[1].Children) != 1 || tree[1].Children[0].Id != budget {
+		t.Errorf("budget was not moved under legal %+v", tree)
+	}
+
+	s.ok(s.do(http.MethodPut, "/department", token, models.Department{Id: legal, Name: "Legal affairs", ParentId: 0, Version: 1}), nil)
+
+	var departments []models.Department
+	s.ok(s.do(http.MethodPost, "/departments", token, nil), &departments)
+	for _, department := range departments {
+		if department.Id == legal && department.Name != "Legal affairs" {
+			t.Errorf("department was not renamed %+v", department)
+		}
+	}
+}
+
+func TestDepartmentValidation(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	budget := s.department(token, models.Department{Name: "Budget", ParentId: finance})
+	legal := s.department(token, models.Department{Name: "Legal"})
+	archived := s.department(token, models.Department{Name: "Archive"})
+	s.ok(s.do(http.MethodPut, "/department/archive", token, models.Department{Id: archived}), nil)
+
+	financeEmployee := s.addEmployee("finance@example.com", "EMPLOYEE", finance)
+	legalEmployee := s.addEmployee("legal@example.com", "EMPLOYEE", legal)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		code   apperr.Code
+		field  string
+	}{
+		{"create under unknown parent", http.MethodPost, "/department", models.Department{Name: "Orphan", ParentId: legal + 100}, apperr.DepartmentNotFound, ""},
+		{"create under archived parent", http.MethodPost, "/department", models.Department{Name: "Orphan", ParentId: archived}, apperr.DepartmentArchived, ""},
+		{"move without id", http.MethodPut, "/department/move", models.Department{ParentId: legal}, apperr.ValidationFailed, "id"},
+		{"move into own subtree", http.MethodPut, "/department/move", models.Department{Id: finance, ParentId: budget}, apperr.DepartmentCycle, ""},
+		{"move under archived", http.MethodPut, "/department/move", models.Department{Id: legal, ParentId: archived}, apperr.DepartmentArchived, ""},
+		{"move unknown", http.MethodPut, "/department/move", models.Department{Id: legal + 100}, apperr.DepartmentNotFound, ""},
+		{"heads without id", http.MethodPut, "/department/heads", models.Department{HeadId: financeEmployee}, apperr.ValidationFailed, "id"},
+		{"same head and deputy", http.MethodPut, "/department/heads", models.Department{Id: finance, HeadId: financeEmployee, DeputyId: financeEmployee}, apperr.ValidationFailed, "deputy_id"},
+		{"head from another department", http.MethodPut, "/department/heads", models.Department{Id: finance, HeadId: legalEmployee}, apperr.NotDepartmentStaff, ""},
+		{"unknown head", http.MethodPut, "/department/heads", models.Department{Id: finance, HeadId: legalEmployee + 100}, apperr.EmployeeNotFound, ""},
+		{"archive without id", http.MethodPut, "/department/archive", models.Department{}, apperr.ValidationFailed, "id"},
+		{"archive with employees", http.MethodPut, "/department/archive", models.Department{Id: legal}, apperr.DepartmentNotEmpty, ""},
+		{"archive with children", http.MethodPut, "/department/archive", models.Department{Id: finance}, apperr.DepartmentNotEmpty, ""},
+		{"merge without source", http.MethodPut, "/department/merge", models.DepartmentMerge{TargetId: legal}, apperr.ValidationFailed, "source_id"},
+		{"merge without target", http.MethodPut, "/department/merge", models.DepartmentMerge{SourceId: legal}, apperr.ValidationFailed, "target_id"},
+		{"merge into itself", http.MethodPut, "/department/merge", models.DepartmentMerge{SourceId: legal, TargetId: legal}, apperr.ValidationFailed, "target_id"},
+		{"merge into own subtree", http.MethodPut, "/department/merge", models.DepartmentMerge{SourceId: finance, TargetId: budget}, apperr.DepartmentCycle, ""},
+		{"merge archived", http.MethodPut, "/department/merge", models.DepartmentMerge{SourceId: archived, TargetId: legal}, apperr.DepartmentArchived, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+
+			response := s.do(tt.method, tt.path, token, tt.body)
+			s.fails(response, tt.code)
+			if tt.field != "" && (len(response.Details) != 1 || response.Details[0].Field != tt.field) {
+				t.Errorf("details %+v, want field %s", response.Details, tt.field)
+			}
+		})
+	}
+}
+
+func TestDepartmentHeadsAndMerge(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	budget := s.department(token, models.Department{Name: "Budget"})
+	treasury := s.department(token, models.Department{Name: "Treasury", ParentId: budget})
+	head := s.addEmployee("head@example.com", "DEP_HEAD", budget)
+	deputy := s.addEmployee("deputy@example.com", "EMPLOYEE", budget)
+
+	// Only admins appoint heads, through either API.
+	clerk := s.login("deputy@example.com")
+	s.fails(s.do(http.MethodPut, "/department/heads", clerk, models.Department{Id: budget, HeadId: deputy}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPut, "/api/v2/departments/"+strconv.Itoa(budget)+"/heads", clerk, models.DepartmentHeads{HeadId: deputy}), apperr.Forbidden)
+
+	// Nor do others create, edit, move, archive or merge departments.
+	s.fails(s.do(http.MethodPut, "/department", clerk, models.Department{Id: budget, Name: "Audit", Version: 1}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPatch, "/api/v2/departments/"+strconv.Itoa(budget), clerk, map[string]interface{}{"name": "Audit", "version": 1}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPost, "/department", clerk, models.Department{Name: "Audit"}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPost, "/api/v2/departments", clerk, models.Department{Name: "Audit"}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPut, "/department/move", clerk, models.Department{Id: treasury, ParentId: finance}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPut, "/api/v2/departments/"+strconv.Itoa(treasury)+"/parent", clerk, models.DepartmentParent{ParentId: finance}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPut, "/department/archive", clerk, models.Department{Id: treasury}), apperr.Forbidden)
+	s.fails(s.do(http.MethodDelete, "/api/v2/departments/"+strconv.Itoa(treasury), clerk, nil), apperr.Forbidden)
+	s.fails(s.do(http.MethodPut, "/department/merge", clerk, models.DepartmentMerge{SourceId: budget, TargetId: finance}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPost, "/api/v2/departments/"+strconv.Itoa(budget)+"/merge", clerk, models.DepartmentMergeTarget{TargetId: finance}), apperr.Forbidden)
+
+	s.ok(s.do(http.MethodPut, "/department/heads", token, models.Department{Id: budget, HeadId: head, DeputyId: deputy}), nil)
+
+	var departments []models.Department
+	s.ok(s.do(http.MethodPost, "/departments", token, nil), &departments)
+	for _, department := range departments {
+		if department.Id == budget && (department.Head == nil || department.Head.Id != head || department.Deputy == nil || department.Deputy.Id != deputy) {
+			t.Errorf("heads were not assigned %+v", department)
+		}
+	}
+
+	// A decided agreement stays with the merged department; a pending one
+	// moves to the target.
+	headToken := s.login("head@example.com")
+	var agreements []models.Agreement
+	s.ok(s.do(http.MethodPost, "/letters/agreement", token, models.Agreement{LetterId: s.letter(token, "Budget proposal"), DepartmentId: budget}), nil)
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements", headToken, nil), &agreements)
+	rejected := agreements[0].Id
+	s.ok(s.do(http.MethodPatch, "/api/v2/agreements/"+strconv.Itoa(rejected), headToken, map[string]bool{"agreed": false}), nil)
+	s.ok(s.do(http.MethodPost, "/letters/agreement", token, models.Agreement{LetterId: s.letter(token, "Budget report"), DepartmentId: budget}), nil)
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements?sort=id", headToken, nil), &agreements)
+	pending := agreements[len(agreements)-1].Id
+
+	s.ok(s.do(http.MethodPut, "/department/merge", token, models.DepartmentMerge{SourceId: budget, TargetId: finance}), nil)
+
+	var agreement models.Agreement
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements/"+strconv.Itoa(rejected), token, nil), &agreement)
+	if agreement.Department.Id != budget {
+		t.Errorf("rejected agreement moved %+v", agreement)
+	}
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements/"+strconv.Itoa(pending), token, nil), &agreement)
+	if agreement.Department.Id != finance {
+		t.Errorf("pending agreement stayed %+v", agreement)
+	}
+
+	var employees []models.Employee
+	s.ok(s.do(http.MethodPost, "/departments/"+strconv.Itoa(finance)+"/users", token, nil), &employees)
+	if len(employees) != 2 {
+		t.Errorf("got %d employees in finance, want 2", len(employees))
+	}
+
+	var transfers []models.EmployeeTransfer
+	s.ok(s.do(http.MethodPost, "/users/"+strconv.Itoa(head)+"/transfers", token, nil), &transfers)
+	if len(transfers) != 1 || transfers[0].FromDepartmentId != budget || transfers[0].ToDepartmentId != finance {
+		t.Errorf("unexpected transfers %+v", transfers)
+	}
+
+	// Employees see their own transfers only.
+	s.ok(s.do(http.MethodGet, "/api/v2/users/"+strconv.Itoa(deputy)+"/transfers", clerk, nil), &transfers)
+	if len(transfers) != 1 || transfers[0].EmployeeId != deputy {
+		t.Errorf("own transfers %+v", transfers)
+	}
+	s.fails(s.do(http.MethodGet, "/api/v2/users/"+strconv.Itoa(head)+"/transfers", clerk, nil), apperr.Forbidden)
+	s.fails(s.do(http.MethodPost, "/users/"+strconv.Itoa(head)+"/transfers", clerk, nil), apperr.Forbidden)
+
+	var tree []models.Department
+	s.ok(s.do(http.MethodPost, "/departments/tree", token, nil), &tree)
+	if len(tree) != 1 || tree[0].Id != finance || len(tree[0].Children) != 1 || tree[0].Children[0].Id != treasury {
+		t.Errorf("treasury was not reparented to finance %+v", tree)
+	}
+
+	s.ok(s.do(http.MethodPost, "/departments?archived=true", token, nil), &departments)
+	for _, department := range departments {
+		if department.Id == budget && (department.ArchivedAt == nil || department.MergedIntoId != finance) {
+			t.Errorf("budget was not archived into finance %+v", department)
+		}
+	}
+}
+
+func TestEditUserTransfersEmployee(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	legal := s.department(token, models.Department{Name: "Legal"})
+	id := s.addEmployee("clerk@example.com", "EMPLOYEE", finance)
+
+	s.ok(s.do(http.MethodPut, "/user", token, models.Employee{
+		Id:           id,
+		FullName:     "Clerk",
+		RoleId:       s.store.RoleId("EMPLOYEE"),
+		DepartmentId: legal,
+	}), nil)
+
+	var transfers []models.EmployeeTransfer
+	s.ok(s.do(http.MethodPost, "/users/"+strconv.Itoa(id)+"/transfers", token, nil), &transfers)
+	if len(transfers) != 1 || transfers[0].ToDepartment.Name != "Legal" {
+		t.Errorf("unexpected transfers %+v", transfers)
+	}
+
+	s.fails(s.do(http.MethodPut, "/user", token, models.Employee{Id: id, DepartmentId: legal + 100}), apperr.DepartmentNotFound)
+	s.fails(s.do(http.MethodPut, "/user", token, models.Employee{Id: id + 100}), apperr.EmployeeNotFound)
+}
+
+func TestUnknownDepartmentEmployees(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	s.addEmployee("clerk@example.com", "EMPLOYEE", s.department(token, models.Department{Name: "Finance"}))
+
+	s.fails(s.do(http.MethodPost, "/departments/9999/users", token, nil), apperr.DepartmentNotFound)
+	s.fails(s.do(http.MethodGet, "/api/v2/departments/9999/users?subdepartments=true", token, nil), apperr.DepartmentNotFound)
+
+	// An empty subtree selects no one rather than everyone.
+	employees, _, err := s.store.Repositories().Employees.List(context.Background(), models.EmployeeFilter{DepartmentIds: []int{}, RowsLimit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(employees) != 0 {
+		t.Errorf("employees of no department %+v", employees)
+	}
+}
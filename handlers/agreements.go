@@ -1,80 +1,53 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v4"
-	"io"
-	"log"
 	"net/http"
-	"sed/db"
+	"sed/apperr"
 	"sed/models"
+	"sed/repository"
 	"strconv"
 	"time"
 )
 
-func GetAgreements(c *gin.Context) {
+func (h *Handler) GetAgreements(c *gin.Context) {
 	var (
-		agreements []models.Agreement
-		response   = models.Response{
+		agreementFilter models.AgreementFilter
+		response        = models.Response{
 			Code:    http.StatusOK,
 			Message: http.StatusText(http.StatusOK),
 			Time:    time.Now(),
 		}
 	)
 
-	userId := c.GetInt("user-id")
-
-	rows, err := db.Pool.Query(
-		c,
-		`select a.id, l.id, d.id, d.name, l.name, l.entry_date, a.viewed, a.agreed, coalesce(a.agreed_at, now())
-from agreements a
-         left join letters l on a.letter_id = l.id
-         left join employees e on a.department_id = e.department_id
-         left join departments d on a.department_id = d.id
-         left join role_group rg on e.role_id = rg.id
-where rg.role in ('ADMIN', 'DEP_HEAD')
-  and e.id = $1
-order by a.id desc;`,
-		userId,
-	)
-	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	if h.decodeQuery(c, &response, &agreementFilter) && h.listAgreements(c, &response, agreementFilter) {
+		respond(c, &response)
 	}
+}
 
-	for rows.Next() {
-		agreement := models.Agreement{}
-
-		err = rows.Scan(
-			&agreement.Id,
-			&agreement.Letter.Id,
-			&agreement.Department.Id,
-			&agreement.Department.Name,
-			&agreement.Letter.Name,
-			&agreement.Letter.EntryDate,
-			&agreement.Viewed,
-			&agreement.Agreed,
-			&agreement.AgreedAt,
-		)
-		if err != nil {
-			response.Code = http.StatusInternalServerError
-			response.Message = err.Error()
-			c.JSON(http.StatusOK, &response)
-			return
-		}
+// listAgreements sets the payload to the agreements in the caller's inbox
+// selected by agreementFilter.
+func (h *Handler) listAgreements(c *gin.Context, response *models.Response, agreementFilter models.AgreementFilter) bool {
+	err := h.validate.Struct(agreementFilter)
+	if err != nil {
+		h.fail(c, response, apperr.Validation(err))
+		return false
+	}
 
-		agreements = append(agreements, agreement)
+	agreements, page, err := h.agreements.Inbox(c, c.GetInt("user-id"), agreementFilter)
+	if err != nil {
+		h.fail(c, response, invalidCursor(err))
+		return false
 	}
 
 	response.Payload = agreements
+	response.Page = &page
 
-	c.JSON(http.StatusOK, &response)
+	return true
 }
 
-func CreateAgreement(c *gin.Context) {
+func (h *Handler) CreateAgreement(c *gin.Context) {
 	var (
 		agreement models.Agreement
 		response  = models.Response{
@@ -84,51 +57,38 @@ func CreateAgreement(c *gin.Context) {
 		}
 	)
 
-	data, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Println("unable to read body data:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
+	if !h.decode(c, &response, &agreement) {
 		return
 	}
-	err = json.Unmarshal(data, &agreement)
-	if err != nil {
-		log.Println("error unmarshaling employee:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
+
+	if h.createAgreement(c, &response, agreement) {
+		respond(c, &response)
 	}
+}
 
-	rtn, err := db.Pool.Exec(
-		c,
-		`insert into agreements (department_id, letter_id, viewed, agreed_at)
-values ($1, $2, false, now());`,
-		agreement.DepartmentId,
-		agreement.LetterId,
-	)
+// createAgreement sends a letter to a department for agreement.
+func (h *Handler) createAgreement(c *gin.Context, response *models.Response, agreement models.Agreement) bool {
+	if !h.departmentActive(c, agreement.DepartmentId, response) {
+		return false
+	}
+
+	id, err := h.agreements.Create(c, agreement)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, notFound(err, apperr.LetterNotFound))
+		return false
 	}
 
-	if rtn.RowsAffected() < 1 {
-		response.Code = http.StatusBadRequest
-		response.Message = pgx.ErrNoRows.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	if !h.record(c, response, "create", models.AuditAgreement, id, nil, h.snapshot(c, models.AuditAgreement, id)) {
+		return false
 	}
 
-	c.JSON(http.StatusOK, &response)
+	return true
 }
 
-func GetAgreement(c *gin.Context) {
+// ViewAgreement marks the agreement viewed and answers with it.
+func (h *Handler) ViewAgreement(c *gin.Context) {
 	var (
-		agreement models.Agreement
-		response  = models.Response{
+		response = models.Response{
 			Code:    http.StatusOK,
 			Message: http.StatusText(http.StatusOK),
 			Time:    time.Now(),
@@ -137,68 +97,61 @@ func GetAgreement(c *gin.Context) {
 
 	id, _ := strconv.Atoi(c.Param("id"))
 
-	rtn, err := db.Pool.Exec(
-		c,
-		`update agreements
-set viewed = true
-where id = $1;`,
-		id,
-	)
-	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
+	if !h.agreementVisible(c, &response, id) {
 		return
 	}
 
-	if rtn.RowsAffected() < 1 {
-		response.Code = http.StatusInternalServerError
-		response.Message = pgx.ErrNoRows.Error()
-		c.JSON(http.StatusOK, &response)
+	err := h.agreements.MarkViewed(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.AgreementNotFound))
 		return
 	}
 
-	err = db.Pool.QueryRow(
-		c,
-		`select a.id,
-       l.id,
-       d.id,
-       d.name,
-       l.name,
-       l.entry_date,
-       a.viewed,
-       a.agreed_at,
-       a.agreed
-from agreements a
-         left join letters l on a.letter_id = l.id
-         left join departments d on a.department_id = d.id
-         left join described_letters dl on d.id = dl.department_id and dl.letter_id = l.id
-where a.id = $1;`,
-		id,
-	).Scan(
-		&agreement.Id,
-		&agreement.Letter.Id,
-		&agreement.Department.Id,
-		&agreement.Department.Name,
-		&agreement.Letter.Name,
-		&agreement.Letter.EntryDate,
-		&agreement.Viewed,
-		&agreement.AgreedAt,
-		&agreement.Agreed,
-	)
+	if h.getAgreement(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// agreementVisible tells whether the employee may read agreement id: the
+// heads whose inbox holds it and admins may.
+func (h *Handler) agreementVisible(c *gin.Context, response *models.Response, id int) bool {
+	employee, err := h.employees.Get(c, c.GetInt("user-id"))
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, err)
+		return false
+	}
+
+	if employee.Role.Role == "ADMIN" {
+		return true
+	}
+
+	err = h.agreements.Holds(c, id, employee.Id)
+	switch {
+	case errors.Is(err, repository.ErrNotAllowed):
+		h.fail(c, response, apperr.Wrap(apperr.Forbidden, err))
+		return false
+	case err != nil:
+		h.fail(c, response, notFound(err, apperr.AgreementNotFound))
+		return false
+	}
+
+	return true
+}
+
+// getAgreement sets the payload to the agreement with id.
+func (h *Handler) getAgreement(c *gin.Context, response *models.Response, id int) bool {
+	agreement, err := h.agreements.Get(c, id)
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.AgreementNotFound))
+		return false
 	}
 
 	response.Payload = agreement
 
-	c.JSON(http.StatusOK, &response)
+	return true
 }
 
-func AgreeAgreement(c *gin.Context) {
+func (h *Handler) AgreeAgreement(c *gin.Context) {
 	var (
 		response = models.Response{
 			Code:    http.StatusOK,
@@ -210,27 +163,39 @@ func AgreeAgreement(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	agree, _ := strconv.ParseBool(c.Param("agree"))
 
-	rtn, err := db.Pool.Exec(
-		c,
-		`update agreements
-set agreed = $1
-where id = $2;`,
-		agree,
-		id,
-	)
-	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	if h.decideAgreement(c, &response, id, agree) {
+		respond(c, &response)
+	}
+}
+
+// decideAgreement records the decision of the department on agreement id,
+// which only the heads whose inbox holds it make, once.
+func (h *Handler) decideAgreement(c *gin.Context, response *models.Response, id int, agree bool) bool {
+	before := h.snapshot(c, models.AuditAgreement, id)
+
+	err := h.agreements.Decide(c, id, c.GetInt("user-id"), agree)
+	switch {
+	case errors.Is(err, repository.ErrNotAllowed):
+		h.fail(c, response, apperr.Wrap(apperr.Forbidden, err))
+		return false
+	case errors.Is(err, repository.ErrConflict):
+		h.fail(c, response, apperr.Wrap(apperr.AgreementDecided, err))
+		return false
+	case err != nil:
+		h.fail(c, response, notFound(err, apperr.AgreementNotFound))
+		return false
 	}
 
-	if rtn.RowsAffected() < 1 {
-		response.Code = http.StatusInternalServerError
-		response.Message = pgx.ErrNoRows.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	after := h.snapshot(c, models.AuditAgreement, id)
+	if !h.record(c, response, "decide", models.AuditAgreement, id, before, after) {
+		return false
+	}
+
+	h.metrics.AgreementDecided(agree)
+
+	if agreement, ok := after.(models.Agreement); ok && agreement.DestructionActId != 0 {
+		return h.settleAct(c, response, agreement.DestructionActId)
 	}
 
-	c.JSON(http.StatusOK, &response)
+	return true
 }
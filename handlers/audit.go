@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"reflect"
+	"sed/apperr"
+	"sed/models"
+	"sed/repository"
+	"time"
+)
+
+// auditOmitted are fields never written to the audit log.
+var auditOmitted = map[string]bool{"password": true, "token": true}
+
+// auditDigested are fields written to the audit log as the SHA-256 of
+// their value, under the name with a _sha256 suffix. The log cannot be
+// changed, so letter bodies, which destruction acts have to remove, are
+// never kept in it.
+var auditDigested = map[string]bool{"content": true}
+
+// snapshot returns entity id as it is stored, for recording its state
+// before and after a change, or nil when it cannot be read.
+func (h *Handler) snapshot(c *gin.Context, entity string, id int) interface{} {
+	var (
+		v   interface{}
+		err error
+	)
+
+	switch entity {
+	case models.AuditLetter:
+		v, err = h.letters.Get(c, id)
+	case models.AuditEmployee:
+		v, err = h.employees.Get(c, id)
+	case models.AuditDepartment:
+		v, err = h.departments.Get(c, id)
+	case models.AuditAgreement:
+		v, err = h.agreements.Get(c, id)
+	case models.AuditDestructionAct:
+		v, err = h.retention.Act(c, id)
+	case models.AuditCase:
+		v, err = h.cases.Get(c, id)
+	}
+	if err != nil {
+		return nil
+	}
+
+	return v
+}
+
+// record appends a change of entity id by the caller to the audit log.
+// before and after are values marshalling to JSON objects, nil when the
+// entity did not exist; only the fields that differ are kept. A change
+// that could not be recorded fails the request, so that the client does
+// not take an unaudited change for a completed one.
+func (h *Handler) record(c *gin.Context, response *models.Response, action, entity string, id int, before, after interface{}) bool {
+	err := h.audit.Append(c, models.AuditEntry{
+		ActorId:   c.GetInt("user-id"),
+		Action:    action,
+		Entity:    entity,
+		EntityId:  id,
+		Changes:   auditChanges(before, after),
+		IP:        c.ClientIP(),
+		RequestId: c.GetString(requestIdKey),
+	})
+	if err != nil {
+		logger(c).Error().Err(err).Str("action", action).Str("entity", entity).Int("entity_id", id).Msg("writing the audit log")
+		h.fail(c, response, err)
+		return false
+	}
+
+	return true
+}
+
+// auditChanges compares the JSON fields of before and after. Nested
+// objects are left out, their ids are fields of their own.
+func auditChanges(before, after interface{}) map[string]models.AuditChange {
+	fields := func(v interface{}) map[string]interface{} {
+		object := map[string]interface{}{}
+		if v != nil {
+			data, _ := json.Marshal(v)
+			_ = json.Unmarshal(data, &object)
+		}
+
+		for name, value := range object {
+			if _, nested := value.(map[string]interface{}); nested || auditOmitted[name] {
+				delete(object, name)
+			}
+
+			if text, ok := value.(string); ok && auditDigested[name] {
+				delete(object, name)
+				object[name+"_sha256"] = auditDigest(text)
+			}
+		}
+		return object
+	}
+
+	old, current := fields(before), fields(after)
+
+	changes := map[string]models.AuditChange{}
+	for name, value := range current {
+		if !reflect.DeepEqual(old[name], value) {
+			changes[name] = models.AuditChange{Before: old[name], After: value}
+		}
+	}
+	for name, value := range old {
+		if _, ok := current[name]; !ok {
+			changes[name] = models.AuditChange{Before: value}
+		}
+	}
+
+	return changes
+}
+
+// auditDigest returns the hex SHA-256 of text, or "" for an empty text.
+func auditDigest(text string) string {
+	if text == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// ListAudit lists the audit log to admins.
+func (h *Handler) ListAudit(c *gin.Context) {
+	var (
+		auditFilter = models.AuditFilter{RowsLimit: DefaultRowsLimit}
+		response    = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.requireAdmin(c, &response) && h.decodeQuery(c, &response, &auditFilter) && h.paged(c, &response, auditFilter.RowsLimit) && h.listAudit(c, &response, auditFilter) {
+		respond(c, &response)
+	}
+}
+
+// listAudit sets the payload to the audit entries matching auditFilter.
+func (h *Handler) listAudit(c *gin.Context, response *models.Response, auditFilter models.AuditFilter) bool {
+	err := h.validate.Struct(auditFilter)
+	if err != nil {
+		h.fail(c, response, apperr.Validation(err))
+		return false
+	}
+
+	entries, page, err := h.audit.List(c, auditFilter)
+	if err != nil {
+		h.fail(c, response, invalidCursor(err))
+		return false
+	}
+
+	response.Payload = entries
+	response.Page = &page
+
+	return true
+}
+
+// VerifyAudit walks the audit log from the first entry and reports to
+// admins whether every entry matches its hash and follows the one before.
+func (h *Handler) VerifyAudit(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+		verification = models.AuditVerification{Valid: true}
+		filter       = models.AuditFilter{Sort: "id", RowsLimit: MaxRowsLimit}
+		previous     = ""
+	)
+
+	if !h.requireAdmin(c, &response) {
+		return
+	}
+
+	for {
+		entries, page, err := h.audit.List(c, filter)
+		if err != nil {
+			h.fail(c, &response, err)
+			return
+		}
+
+		for _, entry := range entries {
+			verification.Entries++
+			if verification.Valid && !repository.AuditChained(previous, entry) {
+				verification.Valid = false
+				verification.BrokenId = entry.Id
+			}
+			previous = entry.Hash
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	response.Payload = verification
+
+	respond(c, &response)
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"sed/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListCases lists the cases of the nomenclature matching the filter.
+func (h *Handler) ListCases(c *gin.Context) {
+	var (
+		caseFilter = models.CaseFilter{RowsLimit: DefaultRowsLimit}
+		response   = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.decodeQuery(c, &response, &caseFilter) || !h.paged(c, &response, caseFilter.RowsLimit) {
+		return
+	}
+
+	err := h.validate.Struct(caseFilter)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	cases, page, err := h.cases.List(c, caseFilter)
+	if err != nil {
+		h.fail(c, &response, invalidCursor(err))
+		return
+	}
+
+	response.Payload = cases
+	response.Page = &page
+
+	respond(c, &response)
+}
+
+// PostCase adds a case to the nomenclature of a department for a year.
+// Admins only.
+func (h *Handler) PostCase(c *gin.Context) {
+	var (
+		nomenclatureCase models.Case
+		response         = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &nomenclatureCase) {
+		return
+	}
+	nomenclatureCase.Index = strings.TrimSpace(nomenclatureCase.Index)
+	nomenclatureCase.Title = strings.TrimSpace(nomenclatureCase.Title)
+
+	err := h.validate.Struct(nomenclatureCase)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	if !h.departmentActive(c, nomenclatureCase.DepartmentId, &response) {
+		return
+	}
+
+	id, err := h.cases.Create(c, nomenclatureCase)
+	if errors.Is(err, repository.ErrDuplicate) {
+		h.fail(c, &response, apperr.Wrap(apperr.CaseIndexTaken, err))
+		return
+	}
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	if !h.record(c, &response, "create", models.AuditCase, id, nil, h.snapshot(c, models.AuditCase, id)) {
+		return
+	}
+
+	if h.getCase(c, &response, id) {
+		created(c, &response, "/api/v2/cases/"+strconv.Itoa(id))
+		respond(c, &response)
+	}
+}
+
+func (h *Handler) GetCase(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.getCase(c, &response, pathId(c)) {
+		respond(c, &response)
+	}
+}
+
+// getCase sets the payload to the case with id.
+func (h *Handler) getCase(c *gin.Context, response *models.Response, id int) bool {
+	nomenclatureCase, err := h.cases.Get(c, id)
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.CaseNotFound))
+		return false
+	}
+
+	response.Payload = nomenclatureCase
+
+	return true
+}
+
+// CloseCase closes a case: no letter is filed into or out of it until it
+// is reopened. Admins only.
+func (h *Handler) CloseCase(c *gin.Context) {
+	h.setCaseClosed(c, true)
+}
+
+// OpenCase reopens a closed case. Admins only.
+func (h *Handler) OpenCase(c *gin.Context) {
+	h.setCaseClosed(c, false)
+}
+
+func (h *Handler) setCaseClosed(c *gin.Context, closed bool) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	id := pathId(c)
+
+	if !h.requireAdmin(c, &response) {
+		return
+	}
+
+	before := h.snapshot(c, models.AuditCase, id)
+
+	err := h.cases.SetClosed(c, id, closed)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.CaseNotFound))
+		return
+	}
+
+	action := "open"
+	if closed {
+		action = "close"
+	}
+	if !h.record(c, &response, action, models.AuditCase, id, before, h.snapshot(c, models.AuditCase, id)) {
+		return
+	}
+
+	if h.getCase(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// PutLetterCase files the letter into a case of the nomenclature of the
+// year it was registered in, moving it out of the case it was in, and
+// answers with the letter. Admins and the staff of the department of the
+// case only.
+func (h *Handler) PutLetterCase(c *gin.Context) {
+	var (
+		filing   models.LetterFiling
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	id := pathId(c)
+
+	if !h.decode(c, &response, &filing) {
+		return
+	}
+
+	err := h.validate.Struct(filing)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	before, err := h.letters.Get(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.LetterNotFound))
+		return
+	}
+
+	if before.DestroyedAt != nil {
+		h.fail(c, &response, apperr.New(apperr.LetterDestroyed))
+		return
+	}
+
+	nomenclatureCase, err := h.cases.Get(c, filing.CaseId)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.CaseNotFound))
+		return
+	}
+
+	employee, err := h.employees.Get(c, c.GetInt("user-id"))
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	if employee.Role.Role != "ADMIN" && employee.DepartmentId != nomenclatureCase.DepartmentId {
+		h.fail(c, &response, apperr.New(apperr.Forbidden))
+		return
+	}
+
+	if nomenclatureCase.Year != before.EntryDate.Year() {
+		h.fail(c, &response, apperr.New(apperr.CaseYearMismatch))
+		return
+	}
+
+	err = h.cases.File(c, id, filing.CaseId)
+	if errors.Is(err, repository.ErrCaseClosed) {
+		h.fail(c, &response, apperr.Wrap(apperr.CaseClosed, err))
+		return
+	}
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.LetterNotFound))
+		return
+	}
+
+	if !h.record(c, &response, "file", models.AuditLetter, id, before, h.snapshot(c, models.AuditLetter, id)) {
+		return
+	}
+
+	if h.getDocument(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// ExportCaseInventory lists the nomenclature of a department for a year,
+// in index order, as JSON or as a CSV inventory of cases selected by the
+// format parameter.
+func (h *Handler) ExportCaseInventory(c *gin.Context) {
+	var (
+		inventory models.CaseInventory
+		response  = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	format := strings.ToLower(c.Param("format"))
+	if format != "json" && format != "csv" {
+		h.fail(c, &response, apperr.New(apperr.UnsupportedFormat).WithMessage("unsupported format, expected json or csv"))
+		return
+	}
+
+	if !h.decodeQuery(c, &response, &inventory) {
+		return
+	}
+
+	err := h.validate.Struct(inventory)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	_, err = h.departments.Get(c, inventory.DepartmentId)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.DepartmentNotFound))
+		return
+	}
+
+	cases, _, err := h.cases.List(c, models.CaseFilter{
+		DepartmentId: inventory.DepartmentId,
+		Year:         inventory.Year,
+		Sort:         "index",
+	})
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	if format == "json" {
+		response.Payload = cases
+		respond(c, &response)
+		return
+	}
+
+	data, err := caseInventoryCsv(cases)
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="cases-%d-%d.csv"`, inventory.DepartmentId, inventory.Year))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+}
+
+// caseInventoryCsv writes one row per case with the number and entry dates
+// of its letters.
+func caseInventoryCsv(cases []models.Case) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	err := w.Write([]string{"index", "title", "letters", "first_entry_date", "last_entry_date", "retention_years", "article", "closed_at"})
+	if err != nil {
+		return nil, err
+	}
+
+	date := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	}
+
+	for _, nomenclatureCase := range cases {
+		retention := "permanent"
+		if nomenclatureCase.RetentionYears > 0 {
+			retention = strconv.Itoa(nomenclatureCase.RetentionYears)
+		}
+
+		err = w.Write([]string{
+			nomenclatureCase.Index,
+			nomenclatureCase.Title,
+			strconv.Itoa(nomenclatureCase.Letters),
+			date(nomenclatureCase.FirstEntryDate),
+			date(nomenclatureCase.LastEntryDate),
+			retention,
+			nomenclatureCase.Article,
+			date(nomenclatureCase.ClosedAt),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
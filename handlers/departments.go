@@ -1,66 +1,95 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v4"
-	"io"
-	"log"
 	"net/http"
-	"sed/db"
+	"sed/apperr"
 	"sed/models"
+	"sed/repository"
+	"sort"
 	"strconv"
 	"time"
 )
 
-func GetDepartments(c *gin.Context) {
+func (h *Handler) GetDepartments(c *gin.Context) {
 	var (
-		departments []models.Department
-		response    = models.Response{
+		departmentFilter models.DepartmentFilter
+		response         = models.Response{
 			Code:    http.StatusOK,
 			Message: http.StatusText(http.StatusOK),
 			Time:    time.Now(),
 		}
 	)
 
-	rows, err := db.Pool.Query(
-		c,
-		`select id, name, internal_number, phone
-from departments
-order by id desc;`,
-	)
+	if h.decodeQuery(c, &response, &departmentFilter) && h.listDepartments(c, &response, departmentFilter) {
+		respond(c, &response)
+	}
+}
+
+// listDepartments sets the payload to the departments selected by
+// departmentFilter.
+func (h *Handler) listDepartments(c *gin.Context, response *models.Response, departmentFilter models.DepartmentFilter) bool {
+	err := h.validate.Struct(departmentFilter)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, apperr.Validation(err))
+		return false
+	}
+
+	departments, page, err := h.departments.List(c, departmentFilter)
+	if err != nil {
+		h.fail(c, response, invalidCursor(err))
+		return false
 	}
 
-	for rows.Next() {
-		department := models.Department{}
+	response.Payload = departments
+	response.Page = &page
+
+	return true
+}
 
-		err = rows.Scan(
-			&department.Id,
-			&department.Name,
-			&department.InternalNumber,
-			&department.Phone,
-		)
-		if err != nil {
-			response.Code = http.StatusInternalServerError
-			response.Message = err.Error()
-			c.JSON(http.StatusOK, &response)
-			return
+// CreateDepartment creates a department. Admins only.
+func (h *Handler) CreateDepartment(c *gin.Context) {
+	var (
+		department models.Department
+		response   = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
 		}
+	)
 
-		departments = append(departments, department)
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &department) {
+		return
 	}
 
-	response.Payload = departments
+	if _, ok := h.createDepartment(c, &response, department); ok {
+		respond(c, &response)
+	}
+}
 
-	c.JSON(http.StatusOK, &response)
+// createDepartment creates department and returns its id.
+func (h *Handler) createDepartment(c *gin.Context, response *models.Response, department models.Department) (int, bool) {
+	if department.ParentId != 0 && !h.departmentActive(c, department.ParentId, response) {
+		return 0, false
+	}
+
+	id, err := h.departments.Create(c, department)
+	if err != nil {
+		h.fail(c, response, err)
+		return 0, false
+	}
+
+	if !h.record(c, response, "create", models.AuditDepartment, id, nil, h.snapshot(c, models.AuditDepartment, id)) {
+		return 0, false
+	}
+
+	return id, true
 }
 
-func CreateDepartment(c *gin.Context) {
+// EditDepartment changes the name, internal number and phone of a
+// department. Admins only.
+func (h *Handler) EditDepartment(c *gin.Context) {
 	var (
 		department models.Department
 		response   = models.Response{
@@ -70,49 +99,125 @@ func CreateDepartment(c *gin.Context) {
 		}
 	)
 
-	data, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Println("unable to read body data:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &department) {
 		return
 	}
-	err = json.Unmarshal(data, &department)
+
+	if h.editDepartment(c, &response, department) {
+		respond(c, &response)
+	}
+}
+
+// editDepartment changes the name, internal number and phone of an active
+// department unless it changed since the version the client read. On a
+// conflict the payload is the current department.
+func (h *Handler) editDepartment(c *gin.Context, response *models.Response, department models.Department) bool {
+	department.Version = expectedVersion(c, department.Version)
+	if department.Version == 0 {
+		h.fail(c, response, apperr.New(apperr.VersionRequired))
+		return false
+	}
+
+	before := h.snapshot(c, models.AuditDepartment, department.Id)
+
+	version, err := h.departments.Update(c, department)
+	if errors.Is(err, repository.ErrConflict) {
+		if h.getDepartment(c, response, department.Id) {
+			h.fail(c, response, apperr.Wrap(apperr.VersionConflict, err))
+		}
+		return false
+	}
 	if err != nil {
-		log.Println("error unmarshaling employee:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, notFound(err, apperr.DepartmentNotFound))
+		return false
 	}
 
-	rtn, err := db.Pool.Exec(
-		c,
-		`insert into departments (name, internal_number, phone)
-values ($1, $2, $3);`,
-		department.Name,
-		department.InternalNumber,
-		department.Phone,
+	if !h.record(c, response, "update", models.AuditDepartment, department.Id, before, h.snapshot(c, models.AuditDepartment, department.Id)) {
+		return false
+	}
+	setETag(c, version)
+
+	return true
+}
+
+func (h *Handler) GetDepartmentEmployees(c *gin.Context) {
+	var (
+		employeeFilter models.DepartmentEmployeeFilter
+		response       = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
 	)
+
+	if h.decodeQuery(c, &response, &employeeFilter) && h.listDepartmentEmployees(c, &response, pathId(c), employeeFilter) {
+		respond(c, &response)
+	}
+}
+
+// listDepartmentEmployees sets the payload to the employees of the
+// department with id selected by employeeFilter.
+func (h *Handler) listDepartmentEmployees(c *gin.Context, response *models.Response, id int, employeeFilter models.DepartmentEmployeeFilter) bool {
+	err := h.validate.Struct(employeeFilter)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, apperr.Validation(err))
+		return false
+	}
+
+	_, err = h.departments.Get(c, id)
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.DepartmentNotFound))
+		return false
 	}
 
-	if rtn.RowsAffected() < 1 {
-		response.Code = http.StatusBadRequest
-		response.Message = pgx.ErrNoRows.Error()
-		c.JSON(http.StatusOK, &response)
+	ids, err := h.departments.Subtree(c, id, employeeFilter.Subdepartments)
+	if err != nil {
+		h.fail(c, response, err)
+		return false
+	}
+
+	employees, page, err := h.employees.List(c, models.EmployeeFilter{
+		DepartmentIds: ids,
+		RowsLimit:     employeeFilter.RowsLimit,
+		RowsOffset:    employeeFilter.RowsOffset,
+		Cursor:        employeeFilter.Cursor,
+		Sort:          employeeFilter.Sort,
+	})
+	if err != nil {
+		h.fail(c, response, invalidCursor(err))
+		return false
+	}
+
+	response.Payload = employees
+	response.Page = &page
+
+	return true
+}
+
+func (h *Handler) GetDepartmentTree(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	archived, _ := strconv.ParseBool(c.Query("archived"))
+
+	departments, _, err := h.departments.List(c, models.DepartmentFilter{Archived: archived})
+	if err != nil {
+		h.fail(c, &response, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, &response)
+	response.Payload = departmentTree(departments)
+
+	respond(c, &response)
 }
 
-func EditDepartment(c *gin.Context) {
+// MoveDepartment places a department under another parent. Admins only.
+func (h *Handler) MoveDepartment(c *gin.Context) {
 	var (
 		department models.Department
 		response   = models.Response{
@@ -122,105 +227,263 @@ func EditDepartment(c *gin.Context) {
 		}
 	)
 
-	data, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Println("unable to read body data:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &department) {
 		return
 	}
-	err = json.Unmarshal(data, &department)
+
+	if h.moveDepartment(c, &response, department.Id, department.ParentId) {
+		respond(c, &response)
+	}
+}
+
+// moveDepartment places department id under parentId, or makes it a root
+// when parentId is 0.
+func (h *Handler) moveDepartment(c *gin.Context, response *models.Response, id, parentId int) bool {
+	if id == 0 {
+		h.fail(c, response, apperr.Field("id", "required"))
+		return false
+	}
+
+	if parentId != 0 && !h.departmentActive(c, parentId, response) {
+		return false
+	}
+
+	before := h.snapshot(c, models.AuditDepartment, id)
+
+	err := h.departments.Move(c, id, parentId)
+	if errors.Is(err, repository.ErrCycle) {
+		h.fail(c, response, apperr.Wrap(apperr.DepartmentCycle, err))
+		return false
+	}
 	if err != nil {
-		log.Println("error unmarshaling employee:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, notFound(err, apperr.DepartmentNotFound))
+		return false
+	}
+
+	if !h.record(c, response, "move", models.AuditDepartment, id, before, h.snapshot(c, models.AuditDepartment, id)) {
+		return false
 	}
 
-	rtn, err := db.Pool.Exec(
-		c,
-		`update departments
-set name            = $1,
-    internal_number = $2,
-    phone           = $3
-where id = $4;`,
-		department.Name,
-		department.InternalNumber,
-		department.Phone,
-		department.Id,
+	return true
+}
+
+// AssignDepartmentHeads sets the head and deputy of a department. Admins
+// only.
+func (h *Handler) AssignDepartmentHeads(c *gin.Context) {
+	var (
+		department models.Department
+		response   = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
 	)
-	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
+
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &department) {
 		return
 	}
 
-	if rtn.RowsAffected() < 1 {
-		response.Code = http.StatusBadRequest
-		response.Message = pgx.ErrNoRows.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	if h.assignDepartmentHeads(c, &response, department.Id, department.HeadId, department.DeputyId) {
+		respond(c, &response)
+	}
+}
+
+// assignDepartmentHeads sets the head and deputy of department id. Both
+// must be its staff; 0 clears the position.
+func (h *Handler) assignDepartmentHeads(c *gin.Context, response *models.Response, id, headId, deputyId int) bool {
+	if id == 0 {
+		h.fail(c, response, apperr.Field("id", "required"))
+		return false
+	}
+
+	if headId != 0 && headId == deputyId {
+		h.fail(c, response, apperr.Field("deputy_id", "nefield"))
+		return false
+	}
+
+	for _, employeeId := range []int{headId, deputyId} {
+		if employeeId == 0 {
+			continue
+		}
+
+		employee, err := h.employees.Get(c, employeeId)
+		if err != nil {
+			h.fail(c, response, notFound(err, apperr.EmployeeNotFound))
+			return false
+		}
+
+		if employee.DepartmentId != id {
+			h.fail(c, response, apperr.New(apperr.NotDepartmentStaff))
+			return false
+		}
+	}
+
+	before := h.snapshot(c, models.AuditDepartment, id)
+
+	err := h.departments.SetHeads(c, id, headId, deputyId)
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.DepartmentNotFound))
+		return false
 	}
 
-	c.JSON(http.StatusOK, &response)
+	if !h.record(c, response, "set_heads", models.AuditDepartment, id, before, h.snapshot(c, models.AuditDepartment, id)) {
+		return false
+	}
+
+	return true
 }
 
-func GetDepartmentEmployees(c *gin.Context) {
+// ArchiveDepartment archives an empty department. Admins only.
+func (h *Handler) ArchiveDepartment(c *gin.Context) {
 	var (
-		employees []models.Employee
-		response  = models.Response{
+		department models.Department
+		response   = models.Response{
 			Code:    http.StatusOK,
 			Message: http.StatusText(http.StatusOK),
 			Time:    time.Now(),
 		}
 	)
 
-	id, _ := strconv.Atoi(c.Param("id"))
-
-	rows, err := db.Pool.Query(
-		c,
-		`select e.id,
-       e.full_name,
-       rg.role,
-       e.email,
-       d.name
-from employees e
-         left join role_group rg on e.role_id = rg.id
-         left join departments d on e.department_id = d.id
-where d.id = $1;`,
-		id,
-	)
-	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &department) {
 		return
 	}
 
-	for rows.Next() {
-		employee := models.Employee{}
+	if h.archiveDepartment(c, &response, department.Id) {
+		respond(c, &response)
+	}
+}
 
-		err = rows.Scan(
-			&employee.Id,
-			&employee.FullName,
-			&employee.Role.Role,
-			&employee.Email,
-			&employee.Department.Name,
-		)
-		if err != nil {
-			response.Code = http.StatusInternalServerError
-			response.Message = err.Error()
-			c.JSON(http.StatusOK, &response)
-			return
+// archiveDepartment archives department id unless it still has employees
+// or active sub-departments.
+func (h *Handler) archiveDepartment(c *gin.Context, response *models.Response, id int) bool {
+	if id == 0 {
+		h.fail(c, response, apperr.Field("id", "required"))
+		return false
+	}
+
+	employees, children, err := h.departments.Members(c, id)
+	if err != nil {
+		h.fail(c, response, err)
+		return false
+	}
+
+	if employees > 0 || children > 0 {
+		h.fail(c, response, apperr.New(apperr.DepartmentNotEmpty))
+		return false
+	}
+
+	before := h.snapshot(c, models.AuditDepartment, id)
+
+	err = h.departments.Archive(c, id)
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.DepartmentNotFound))
+		return false
+	}
+
+	if !h.record(c, response, "archive", models.AuditDepartment, id, before, h.snapshot(c, models.AuditDepartment, id)) {
+		return false
+	}
+
+	return true
+}
+
+// MergeDepartment merges a department into another. Admins only.
+func (h *Handler) MergeDepartment(c *gin.Context) {
+	var (
+		merge    models.DepartmentMerge
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
 		}
+	)
 
-		employees = append(employees, employee)
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &merge) {
+		return
 	}
 
-	response.Payload = employees
+	if h.mergeDepartment(c, &response, merge) {
+		respond(c, &response)
+	}
+}
+
+// mergeDepartment merges the source department into the target.
+func (h *Handler) mergeDepartment(c *gin.Context, response *models.Response, merge models.DepartmentMerge) bool {
+	err := h.validate.Struct(merge)
+	if err != nil {
+		h.fail(c, response, apperr.Validation(err))
+		return false
+	}
+
+	if !h.departmentActive(c, merge.SourceId, response) || !h.departmentActive(c, merge.TargetId, response) {
+		return false
+	}
+
+	before := h.snapshot(c, models.AuditDepartment, merge.SourceId)
+
+	err = h.departments.Merge(c, merge.SourceId, merge.TargetId, c.GetInt("user-id"))
+	if errors.Is(err, repository.ErrCycle) {
+		h.fail(c, response, apperr.Wrap(apperr.DepartmentCycle, err))
+		return false
+	}
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.DepartmentNotFound))
+		return false
+	}
+
+	if !h.record(c, response, "merge", models.AuditDepartment, merge.SourceId, before, h.snapshot(c, models.AuditDepartment, merge.SourceId)) {
+		return false
+	}
+
+	return true
+}
+
+// departmentActive responds with an error and returns false when the
+// department does not exist or is archived.
+func (h *Handler) departmentActive(c *gin.Context, id int, response *models.Response) bool {
+	archived, err := h.departments.IsArchived(c, id)
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.DepartmentNotFound))
+		return false
+	}
+
+	if archived {
+		h.fail(c, response, apperr.New(apperr.DepartmentArchived))
+		return false
+	}
+
+	return true
+}
+
+// departmentTree nests a flat department list by parent_id, ordering
+// siblings by name. Departments whose parent is missing from the list are
+// returned as roots.
+func departmentTree(departments []models.Department) []models.Department {
+	known := make(map[int]bool, len(departments))
+	children := make(map[int][]models.Department, len(departments))
+	for _, department := range departments {
+		known[department.Id] = true
+	}
+
+	for _, department := range departments {
+		parentId := department.ParentId
+		if !known[parentId] {
+			parentId = 0
+		}
+		children[parentId] = append(children[parentId], department)
+	}
+
+	var build func(parentId int) []models.Department
+	build = func(parentId int) []models.Department {
+		nodes := children[parentId]
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].Name < nodes[j].Name
+		})
+		for i := range nodes {
+			nodes[i].Children = build(nodes[i].Id)
+		}
+		return nodes
+	}
 
-	c.JSON(http.StatusOK, &response)
+	return build(0)
 }
@@ -1,181 +1,102 @@
 package handlers
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v4"
-	"io"
-	"log"
 	"net/http"
-	"sed/db"
+	"sed/apperr"
 	"sed/models"
-	"strings"
+	"sed/repository"
+	"strconv"
 	"time"
 )
 
-func GetDocuments(c *gin.Context) {
+func (h *Handler) GetDocuments(c *gin.Context) {
 	var (
-		documentLetters []models.Letter
-		documentFilter  models.LetterFilter
-		response        = models.Response{
+		documentFilter models.LetterFilter
+		response       = models.Response{
 			Code:    http.StatusOK,
 			Message: http.StatusText(http.StatusOK),
 			Time:    time.Now(),
 		}
 	)
 
-	data, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Println("unable to read body data:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
+	if !h.decode(c, &response, &documentFilter) {
 		return
 	}
 
-	err = json.Unmarshal(data, &documentFilter)
-	if err != nil {
-		log.Println("error unmarshaling employee:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
-	}
-
-	err = Validate.Struct(documentFilter)
-	if err != nil {
-		response.Code = http.StatusBadRequest
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	if h.listDocuments(c, &response, documentFilter) {
+		respond(c, &response)
 	}
+}
 
-	rows, err := db.Pool.Query(
-		c,
-		`select l.id,
-       l.name,
-       l.sender,
-       dt.type,
-       l.registration_number,
-       coalesce(l.entry_date, now()),
-       coalesce(l.outgoing_number, ''),
-       coalesce(l.distribution_date, now())
-from letters l
-         left join document_type dt on l.document_type_id = dt.id
-where true 
-`+documentFilters(documentFilter)+`
-order by l.id desc
-offset $1 limit $2;`,
-		documentFilter.RowsOffset,
-		documentFilter.RowsLimit,
-	)
+// listDocuments sets the payload to the letters matching documentFilter.
+func (h *Handler) listDocuments(c *gin.Context, response *models.Response, documentFilter models.LetterFilter) bool {
+	err := h.validate.Struct(documentFilter)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, apperr.Validation(err))
+		return false
 	}
 
-	for rows.Next() {
-		letter := models.Letter{}
-		err = rows.Scan(
-			&letter.Id,
-			&letter.Name,
-			&letter.Sender,
-			&letter.DocumentType.Type,
-			&letter.RegistrationNumber,
-			&letter.EntryDate,
-			&letter.OutgoingNumber,
-			&letter.DistributionDate,
-		)
+	if documentFilter.DepartmentId != 0 {
+		documentFilter.DepartmentIds, err = h.departments.Subtree(c, documentFilter.DepartmentId, documentFilter.Subdepartments)
 		if err != nil {
-			response.Code = http.StatusInternalServerError
-			response.Message = err.Error()
-			c.JSON(http.StatusOK, &response)
-			return
+			h.fail(c, response, err)
+			return false
 		}
-
-		documentLetters = append(documentLetters, letter)
 	}
 
-	response.Payload = documentLetters
-
-	c.JSON(http.StatusOK, &response)
-}
-
-func documentFilters(filter models.LetterFilter) (query string) {
-
-	filter.Sender = strings.TrimSpace(filter.Sender)
-	if len(filter.Sender) > 0 {
-		query += fmt.Sprintf(" and l.sender like '%%%s%%' ", filter.Sender)
+	documentLetters, page, err := h.letters.List(c, documentFilter)
+	if err != nil {
+		h.fail(c, response, invalidCursor(err))
+		return false
 	}
 
-	filter.Name = strings.TrimSpace(filter.Name)
-	if len(filter.Name) > 0 {
-		query += fmt.Sprintf(" and lower(l.name) like n'%%%s%%' ", strings.ToLower(filter.Name))
+	for i := range documentLetters {
+		labelDocumentType(c, &documentLetters[i].DocumentType)
+		documentLetters[i].DocumentType.Labels = nil
 	}
 
-	return
+	response.Payload = documentLetters
+	response.Page = &page
+
+	return true
 }
 
-func GetDocument(c *gin.Context) {
+func (h *Handler) GetDocument(c *gin.Context) {
 	var (
-		documentLetter models.Letter
-		response       = models.Response{
+		response = models.Response{
 			Code:    http.StatusOK,
 			Message: http.StatusText(http.StatusOK),
 			Time:    time.Now(),
 		}
 	)
 
-	id := c.Param("id")
-
-	err := db.Pool.QueryRow(
-		c,
-		`select l.id,
-       l.name,
-       l.sender,
-       dt.type,
-       l.registration_number,
-       l.entry_date,
-       l.outgoing_number,
-       coalesce(l.distribution_date, now()),
-       l.content
-from letters l
-         left join document_type dt on l.document_type_id = dt.id
-where l.id = $1;`,
-		id,
-	).Scan(
-		&documentLetter.Id,
-		&documentLetter.Name,
-		&documentLetter.Sender,
-		&documentLetter.DocumentType.Type,
-		&documentLetter.RegistrationNumber,
-		&documentLetter.EntryDate,
-		&documentLetter.OutgoingNumber,
-		&documentLetter.DistributionDate,
-		&documentLetter.Content,
-	)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			c.JSON(http.StatusOK, &response)
-			return
-		}
+	id, _ := strconv.Atoi(c.Param("id"))
 
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	if h.getDocument(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// getDocument sets the payload to the letter with id.
+func (h *Handler) getDocument(c *gin.Context, response *models.Response, id int) bool {
+	documentLetter, err := h.letters.Get(c, id)
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.LetterNotFound))
+		return false
 	}
 
+	labelDocumentType(c, &documentLetter.DocumentType)
+	documentLetter.DocumentType.Labels = nil
+
 	response.Payload = documentLetter
+	setETag(c, documentLetter.Version)
 
-	c.JSON(http.StatusOK, &response)
+	return true
 }
 
-func CreateDocument(c *gin.Context) {
+func (h *Handler) CreateDocument(c *gin.Context) {
 	var (
 		documentLetter models.Letter
 		response       = models.Response{
@@ -185,56 +106,40 @@ func CreateDocument(c *gin.Context) {
 		}
 	)
 
-	data, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Println("unable to read body data:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
+	if !h.decode(c, &response, &documentLetter) {
 		return
 	}
 
-	err = json.Unmarshal(data, &documentLetter)
-	if err != nil {
-		log.Println("error unmarshaling employee:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
+	if h.createDocument(c, &response, documentLetter) {
+		respond(c, &response)
 	}
+}
 
-	err = Validate.Struct(documentLetter)
+// createDocument registers documentLetter and sets the payload to its id.
+func (h *Handler) createDocument(c *gin.Context, response *models.Response, documentLetter models.Letter) bool {
+	err := h.validate.Struct(documentLetter)
 	if err != nil {
-		response.Code = http.StatusBadRequest
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, apperr.Validation(err))
+		return false
 	}
 
-	id := 0
-
-	err = db.Pool.QueryRow(
-		c,
-		`insert into letters (name, sender, document_type_id, registration_number, entry_date, outgoing_number, content)
-values ($1, $2, $3, now(), now(), now(), $4) returning id;`,
-		documentLetter.Name,
-		documentLetter.Sender,
-		documentLetter.DocumentTypeId,
-		documentLetter.Content,
-	).Scan(&id)
+	id, err := h.letters.Create(c, documentLetter)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, err)
+		return false
+	}
+
+	h.metrics.LetterRegistered()
+	if !h.record(c, response, "create", models.AuditLetter, id, nil, h.snapshot(c, models.AuditLetter, id)) {
+		return false
 	}
 
 	response.Payload = id
 
-	c.JSON(http.StatusOK, &response)
+	return true
 }
 
-func EditDocument(c *gin.Context) {
+func (h *Handler) EditDocument(c *gin.Context) {
 	var (
 		documentLetter models.Letter
 		response       = models.Response{
@@ -244,108 +149,87 @@ func EditDocument(c *gin.Context) {
 		}
 	)
 
-	data, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Println("unable to read body data:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
+	if !h.decode(c, &response, &documentLetter) {
 		return
 	}
 
-	err = json.Unmarshal(data, &documentLetter)
-	if err != nil {
-		log.Println("error unmarshaling employee:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
+	if h.editDocument(c, &response, documentLetter) {
+		respond(c, &response)
 	}
+}
 
-	err = Validate.Struct(documentLetter)
+// editDocument overwrites the letter with documentLetter unless it changed
+// since the version the client read or was destroyed. On a conflict the
+// payload is the current letter.
+func (h *Handler) editDocument(c *gin.Context, response *models.Response, documentLetter models.Letter) bool {
+	err := h.validate.Struct(documentLetter)
 	if err != nil {
-		response.Code = http.StatusBadRequest
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, apperr.Validation(err))
+		return false
 	}
 
 	if documentLetter.Id == 0 {
-		response.Code = http.StatusBadRequest
-		response.Message = "invalid document id"
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, apperr.Field("id", "required"))
+		return false
 	}
 
-	rtn, err := db.Pool.Exec(
-		c,
-		`update letters
-set name                = $1,
-    sender              = $2,
-    document_type_id    = $3,
-    content             = $4
-where id = $5;`,
-		documentLetter.Name,
-		documentLetter.Sender,
-		documentLetter.DocumentTypeId,
-		documentLetter.Content,
-		documentLetter.Id,
-	)
+	documentLetter.Version = expectedVersion(c, documentLetter.Version)
+	if documentLetter.Version == 0 {
+		h.fail(c, response, apperr.New(apperr.VersionRequired))
+		return false
+	}
+
+	before := h.snapshot(c, models.AuditLetter, documentLetter.Id)
+
+	version, err := h.letters.Update(c, documentLetter)
+	if errors.Is(err, repository.ErrConflict) {
+		if h.getDocument(c, response, documentLetter.Id) {
+			code := apperr.VersionConflict
+			if response.Payload.(models.Letter).DestroyedAt != nil {
+				code = apperr.LetterDestroyed
+			}
+			h.fail(c, response, apperr.Wrap(code, err))
+		}
+		return false
+	}
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, notFound(err, apperr.LetterNotFound))
+		return false
 	}
 
-	if rtn.RowsAffected() < 1 {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	if !h.record(c, response, "update", models.AuditLetter, documentLetter.Id, before, h.snapshot(c, models.AuditLetter, documentLetter.Id)) {
+		return false
 	}
+	setETag(c, version)
 
-	c.JSON(http.StatusOK, &response)
+	return true
 }
 
-func GetLetterTypes(c *gin.Context) {
+func (h *Handler) GetLetterTypes(c *gin.Context) {
 	var (
-		documentTypes []models.DocumentType
-		response      = models.Response{
+		response = models.Response{
 			Code:    http.StatusOK,
 			Message: http.StatusText(http.StatusOK),
 			Time:    time.Now(),
 		}
 	)
 
-	rows, err := db.Pool.Query(
-		c,
-		`select id, type
-from document_type;`,
-	)
+	documentTypes, err := h.letters.DocumentTypes(c)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
+		h.fail(c, &response, err)
 		return
 	}
 
-	for rows.Next() {
-		documentType := models.DocumentType{}
-		err = rows.Scan(
-			&documentType.Id,
-			&documentType.Type,
-		)
-
-		documentTypes = append(documentTypes, documentType)
+	for i := range documentTypes {
+		labelDocumentType(c, &documentTypes[i])
 	}
 
 	response.Payload = documentTypes
 
-	c.JSON(http.StatusOK, &response)
+	respond(c, &response)
 }
 
-func DescribeLetter(c *gin.Context) {
+func (h *Handler) DescribeLetter(c *gin.Context) {
 	var (
 		describedLetter models.DescribedLetter
 		response        = models.Response{
@@ -355,45 +239,30 @@ func DescribeLetter(c *gin.Context) {
 		}
 	)
 
-	data, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Println("unable to read body data:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
+	if !h.decode(c, &response, &describedLetter) {
 		return
 	}
 
-	err = json.Unmarshal(data, &describedLetter)
-	if err != nil {
-		log.Println("error unmarshaling employee:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
+	if h.describeLetter(c, &response, describedLetter) {
+		respond(c, &response)
 	}
+}
 
-	rtn, err := db.Pool.Exec(
-		c,
-		`insert into described_letters (letter_id, department_id, executive_employee)
-values ($1, $2, $3);`,
-		describedLetter.LetterId,
-		describedLetter.DepartmentId,
-		describedLetter.ExecutiveEmployee,
-	)
+// describeLetter assigns a letter to a department and executive.
+func (h *Handler) describeLetter(c *gin.Context, response *models.Response, describedLetter models.DescribedLetter) bool {
+	if !h.departmentActive(c, describedLetter.DepartmentId, response) {
+		return false
+	}
+
+	err := h.letters.Describe(c, describedLetter)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, notFound(err, apperr.LetterNotFound))
+		return false
 	}
 
-	if rtn.RowsAffected() < 1 {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	if !h.record(c, response, "assign", models.AuditLetter, describedLetter.LetterId, nil, describedLetter) {
+		return false
 	}
 
-	c.JSON(http.StatusOK, &response)
+	return true
 }
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"sed/config"
+	"sed/i18n"
+	"sed/importer"
+	"sed/metrics"
+	"sed/repository"
+	"strings"
+)
+
+// Handler serves the HTTP API on top of the injected repositories.
+type Handler struct {
+	letters      repository.LetterRepository
+	employees    repository.EmployeeRepository
+	departments  repository.DepartmentRepository
+	agreements   repository.AgreementRepository
+	imports      importer.Store
+	health       repository.HealthRepository
+	idempotency  repository.IdempotencyRepository
+	audit        repository.AuditRepository
+	retention    repository.RetentionRepository
+	cases        repository.CaseRepository
+	validate     *validator.Validate
+	translations *i18n.Translations
+	auth         config.Auth
+	storage      config.Storage
+	http         config.HTTP
+	metrics      *metrics.Metrics
+}
+
+func New(repositories repository.Repositories, validate *validator.Validate, settings config.Config, metrics *metrics.Metrics) *Handler {
+	return &Handler{
+		letters:      repositories.Letters,
+		employees:    repositories.Employees,
+		departments:  repositories.Departments,
+		agreements:   repositories.Agreements,
+		imports:      repositories.Imports,
+		health:       repositories.Health,
+		idempotency:  repositories.Idempotency,
+		audit:        repositories.Audit,
+		retention:    repositories.Retention,
+		cases:        repositories.Cases,
+		validate:     validate,
+		translations: i18n.NewTranslations(validate),
+		auth:         settings.Auth,
+		storage:      settings.Storage,
+		http:         settings.HTTP,
+		metrics:      metrics,
+	}
+}
+
+// NewValidator returns a validator that reports fields by their JSON names,
+// as clients know them.
+func NewValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return validate
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sed/apperr"
+	"sed/db"
+	"sed/models"
+	"time"
+)
+
+// readinessTimeout bounds the database checks of Readyz, so that a hanging
+// database fails the probe instead of stalling it.
+const readinessTimeout = 2 * time.Second
+
+// Healthz reports that the process is alive. It checks nothing else, so
+// that an unavailable database does not get the instance restarted.
+func Healthz(c *gin.Context) {
+	response := models.Response{
+		Code:    http.StatusOK,
+		Message: http.StatusText(http.StatusOK),
+		Time:    time.Now(),
+	}
+
+	respond(c, &response)
+}
+
+// Readyz reports whether the instance can serve traffic: the database
+// answers and every embedded migration is applied.
+func (h *Handler) Readyz(c *gin.Context) {
+	var (
+		readiness models.Readiness
+		response  = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	ctx, cancel := context.WithTimeout(c, readinessTimeout)
+	defer cancel()
+
+	err := h.health.Ping(ctx)
+	if err == nil {
+		readiness.Database = "ok"
+
+		var migrations []db.Migration
+		migrations, err = h.health.Migrations(ctx)
+		for _, migration := range migrations {
+			if migration.AppliedAt == nil {
+				readiness.Migrations.Pending = append(readiness.Migrations.Pending, migration.Version)
+			} else {
+				readiness.Migrations.Applied++
+			}
+		}
+	} else {
+		readiness.Database = "unavailable"
+	}
+
+	response.Payload = readiness
+
+	if err != nil {
+		logger(c).Error().Err(err).Msg("readiness check failed")
+		h.fail(c, &response, apperr.New(apperr.NotReady))
+		return
+	}
+	if len(readiness.Migrations.Pending) > 0 {
+		h.fail(c, &response, apperr.New(apperr.NotReady))
+		return
+	}
+
+	respond(c, &response)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"io"
+	"sed/apperr"
+	"sed/models"
+	"time"
+)
+
+// IdempotencyKeyHeader lets clients retry a request without running it
+// twice: retries with the same key and body get the first response again.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// ReplayedHeader is set on responses replayed for an Idempotency-Key.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// replayedHeaders are the response headers stored with the body.
+var replayedHeaders = []string{"Location", "ETag"}
+
+// Idempotent makes retries of a request sending IdempotencyKeyHeader
+// replay the stored response for the configured TTL instead of running
+// the handler again. A key sent with a different method, path, query or
+// body is rejected, as is a retry while the first request is still
+// running. Responses with a 5xx status and handlers that panic are not
+// stored, so that retries can succeed. Of the payload only the id is
+// stored and replayed, so that no letter content outlives the letter.
+// Keys are scoped to the employee, so Idempotent goes after Authorization.
+func (h *Handler) Idempotent(c *gin.Context) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+
+	response := models.Response{
+		Time: time.Now(),
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Abort()
+		h.fail(c, &response, apperr.Wrap(apperr.MalformedRequest, err))
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	hash.Write(body)
+
+	request := models.IdempotentRequest{
+		EmployeeId:  c.GetInt("user-id"),
+		Key:         key,
+		RequestHash: hex.EncodeToString(hash.Sum(nil)),
+		ExpiresAt:   time.Now().Add(h.http.IdempotencyTTL),
+	}
+
+	stored, found, err := h.idempotency.Begin(c, request)
+	if err != nil {
+		c.Abort()
+		h.fail(c, &response, err)
+		return
+	}
+
+	if found {
+		c.Abort()
+
+		switch {
+		case stored.RequestHash != request.RequestHash:
+			h.fail(c, &response, apperr.New(apperr.IdempotencyReused))
+		case stored.Status == 0:
+			h.fail(c, &response, apperr.New(apperr.IdempotencyInUse))
+		default:
+			for name, value := range stored.Headers {
+				c.Header(name, value)
+			}
+			c.Header(ReplayedHeader, "true")
+			c.Data(stored.Status, "application/json; charset=utf-8", stored.Body)
+		}
+		return
+	}
+
+	// The client may be gone by now; the outcome is stored regardless.
+	ctx := context.Background()
+
+	// Unless a response is stored, the key is released, also when the
+	// handler panics.
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+
+		err := h.idempotency.Release(ctx, request.EmployeeId, key)
+		if err != nil {
+			logger(c).Error().Err(err).Str("idempotency_key", key).Msg("releasing idempotency key")
+		}
+	}()
+
+	recorder := &bodyRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+
+	c.Next()
+
+	if c.Writer.Status() >= 500 {
+		return
+	}
+
+	request.Status = c.Writer.Status()
+	request.Headers = map[string]string{}
+	for _, name := range replayedHeaders {
+		if value := c.Writer.Header().Get(name); value != "" {
+			request.Headers[name] = value
+		}
+	}
+	request.Body = replayBody(recorder.body.Bytes())
+
+	err = h.idempotency.Complete(ctx, request)
+	if err != nil {
+		logger(c).Error().Err(err).Str("idempotency_key", key).Msg("storing idempotent response")
+		return
+	}
+	completed = true
+}
+
+// replayBody strips the payload of a response body down to the id: an id
+// payload is kept, an object is reduced to its id field and anything else
+// is dropped.
+func replayBody(body []byte) []byte {
+	var response struct {
+		models.Response
+		Payload json.RawMessage `json:"payload,omitempty"`
+	}
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return nil
+	}
+
+	var (
+		object struct {
+			Id json.RawMessage `json:"id"`
+		}
+		id int
+	)
+	switch {
+	case len(response.Payload) == 0:
+	case json.Unmarshal(response.Payload, &object) == nil && len(object.Id) > 0:
+		response.Payload, _ = json.Marshal(object)
+	case json.Unmarshal(response.Payload, &id) == nil && string(response.Payload) != "null":
+	default:
+		response.Payload = nil
+	}
+
+	body, err = json.Marshal(response)
+	if err != nil {
+		return nil
+	}
+
+	return body
+}
+
+// bodyRecorder keeps a copy of the body written through it.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *bodyRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *bodyRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sed/apperr"
+	"sed/importer"
+	"sed/models"
+	"strconv"
+	"time"
+)
+
+// ImportStaff bulk imports departments and employees from the multipart
+// files "departments" and "employees". With dry_run=true the files are only
+// validated; otherwise they are imported atomically or not at all.
+func (h *Handler) ImportStaff(c *gin.Context) {
+	var (
+		batch    importer.Batch
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) {
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.storage.MaxUploadSize)
+
+	for _, field := range []string{importer.SheetDepartments, importer.SheetEmployees} {
+		header, err := c.FormFile(field)
+		if errors.Is(err, http.ErrMissingFile) {
+			continue
+		}
+		if err != nil {
+			h.fail(c, &response, apperr.Wrap(apperr.ImportFileInvalid, err).WithFields(models.FieldError{Field: field, Rule: "file"}))
+			return
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			h.fail(c, &response, err)
+			return
+		}
+
+		if field == importer.SheetDepartments {
+			batch.Departments, err = importer.ReadDepartments(file, header.Filename)
+		} else {
+			batch.Employees, err = importer.ReadEmployees(file, header.Filename)
+		}
+		_ = file.Close()
+		if errors.Is(err, importer.ErrUnsupportedFormat) {
+			h.fail(c, &response, apperr.Wrap(apperr.UnsupportedFormat, err).WithFields(models.FieldError{Field: field, Rule: "format"}))
+			return
+		}
+		if err != nil {
+			// Parser errors quote the file; they are logged, not sent back.
+			logger(c).Warn().Err(err).Str("field", field).Msg("reading the import file")
+			h.fail(c, &response, apperr.Wrap(apperr.ImportFileInvalid, err).WithFields(models.FieldError{Field: field, Rule: "file"}))
+			return
+		}
+	}
+
+	if len(batch.Departments) == 0 && len(batch.Employees) == 0 {
+		h.fail(c, &response, apperr.New(apperr.ImportFileInvalid).WithMessage("nothing to import"))
+		return
+	}
+
+	report, err := importer.Run(c, h.imports, batch, dryRun)
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	response.Payload = report
+
+	if len(report.Errors) > 0 {
+		h.fail(c, &response, apperr.New(apperr.ImportRejected))
+		return
+	}
+
+	if !dryRun && !h.record(c, &response, "import", models.AuditImport, 0, nil, report) {
+		return
+	}
+
+	respond(c, &response)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"sed/i18n"
+	"sed/models"
+)
+
+const languageKey = "language"
+
+// Localize picks the response language from the Accept-Language header.
+// Authorization replaces it with the user's preferred language, if any.
+func Localize(c *gin.Context) {
+	c.Set(languageKey, i18n.Match(c.GetHeader("Accept-Language")))
+
+	c.Next()
+}
+
+func language(c *gin.Context) string {
+	if language := c.GetString(languageKey); language != "" {
+		return language
+	}
+	return i18n.Default
+}
+
+// labelDocumentType fills the label of documentType in the request language.
+func labelDocumentType(c *gin.Context, documentType *models.DocumentType) {
+	documentType.Label = i18n.Label(language(c), documentType.Labels, documentType.Type)
+}
+
+// labelRoleGroup fills the label of roleGroup in the request language.
+func labelRoleGroup(c *gin.Context, roleGroup *models.RoleGroup) {
+	roleGroup.Label = i18n.Label(language(c), roleGroup.Labels, roleGroup.Role)
+}
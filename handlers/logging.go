@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"runtime/debug"
+	"sed/models"
+	"time"
+)
+
+// RequestIdHeader carries the request id. A valid id sent by the client,
+// such as one assigned by a proxy, is kept; otherwise one is generated.
+const RequestIdHeader = "X-Request-ID"
+
+const (
+	requestIdKey = "request-id"
+	errorCodeKey = "error-code"
+)
+
+// RequestLog assigns every request an id, echoes it in RequestIdHeader and
+// writes one log line per request. Handlers log through the logger stored
+// in the request context, which carries the id and, when the request is
+// traced, the trace and span ids.
+func RequestLog(logger zerolog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIdHeader)
+		if !validRequestId(id) {
+			id = newRequestId()
+		}
+		c.Set(requestIdKey, id)
+		c.Header(RequestIdHeader, id)
+
+		logContext := logger.With().Str("request_id", id)
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			logContext = logContext.Str("trace_id", span.TraceID().String()).Str("span_id", span.SpanID().String())
+		}
+		requestLogger := logContext.Logger()
+		c.Request = c.Request.WithContext(requestLogger.WithContext(c.Request.Context()))
+
+		c.Next()
+
+		status := c.Writer.Status()
+
+		event := requestLogger.Info()
+		if status >= http.StatusInternalServerError {
+			event = requestLogger.Error()
+		}
+
+		event = event.
+			Str("method", c.Request.Method).
+			Str("route", c.FullPath()).
+			Str("path", c.Request.URL.Path).
+			Int("status", status).
+			Dur("latency", time.Since(start)).
+			Str("client_ip", c.ClientIP())
+
+		if userId := c.GetInt("user-id"); userId != 0 {
+			event = event.Int("user_id", userId)
+		}
+		if code := c.GetString(errorCodeKey); code != "" {
+			event = event.Str("error_code", code)
+		}
+
+		event.Msg("request")
+	}
+}
+
+// logger returns the request logger set up by RequestLog.
+func logger(c *gin.Context) *zerolog.Logger {
+	return zerolog.Ctx(c.Request.Context())
+}
+
+// validRequestId accepts ids of up to 128 printable ASCII characters, so
+// that clients cannot inject anything into the logs.
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestId() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Recover answers a panicking request with INTERNAL and logs the panic
+// with its stack under the request id.
+func (h *Handler) Recover(c *gin.Context, recovered interface{}) {
+	response := models.Response{
+		Time: time.Now(),
+	}
+
+	c.Abort()
+	h.fail(c, &response, fmt.Errorf("panic: %v\n%s", recovered, debug.Stack()))
+}
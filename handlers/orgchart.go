@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportOrgChart renders active departments and their employees as nested
+// JSON, Graphviz DOT or a flat CSV, selected by the format parameter.
+// Without parent links every department is a root, so the chart degrades to a flat list.
+func (h *Handler) ExportOrgChart(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	format := strings.ToLower(c.Param("format"))
+	if format != "json" && format != "dot" && format != "csv" {
+		h.fail(c, &response, apperr.New(apperr.UnsupportedFormat).WithMessage("unsupported format, expected json, dot or csv"))
+		return
+	}
+
+	departments, _, err := h.departments.List(c, models.DepartmentFilter{})
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	employees, err := h.employees.All(c)
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	byDepartment := make(map[int][]models.Employee)
+	for _, employee := range employees {
+		byDepartment[employee.DepartmentId] = append(byDepartment[employee.DepartmentId], employee)
+	}
+
+	for i := range departments {
+		departments[i].Employees = byDepartment[departments[i].Id]
+	}
+
+	tree := departmentTree(departments)
+
+	switch format {
+	case "dot":
+		c.Header("Content-Disposition", `attachment; filename="orgchart.dot"`)
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", orgChartDot(tree))
+	case "csv":
+		data, err := orgChartCsv(departments, employees)
+		if err != nil {
+			h.fail(c, &response, err)
+			return
+		}
+
+		c.Header("Content-Disposition", `attachment; filename="orgchart.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+	default:
+		response.Payload = tree
+		respond(c, &response)
+	}
+}
+
+func orgChartDot(tree []models.Department) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("digraph orgchart {\n")
+	buf.WriteString("  rankdir=TB;\n")
+	buf.WriteString("  node [shape=box];\n")
+
+	var write func(departments []models.Department)
+	write = func(departments []models.Department) {
+		for _, department := range departments {
+			label := department.Name
+			if department.Head != nil {
+				label += "\n" + department.Head.FullName
+			}
+			fmt.Fprintf(&buf, "  d%d [label=%s, style=bold];\n", department.Id, dotQuote(label))
+
+			for _, child := range department.Children {
+				fmt.Fprintf(&buf, "  d%d -> d%d;\n", department.Id, child.Id)
+			}
+
+			for _, employee := range department.Employees {
+				label := employee.FullName
+				if employee.Position != "" {
+					label += "\n" + employee.Position
+				}
+				fmt.Fprintf(&buf, "  e%d [label=%s, shape=ellipse];\n", employee.Id, dotQuote(label))
+				fmt.Fprintf(&buf, "  d%d -> e%d;\n", department.Id, employee.Id)
+			}
+
+			write(department.Children)
+		}
+	}
+	write(tree)
+
+	buf.WriteString("}\n")
+
+	return buf.Bytes()
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// orgChartCsv writes one row per employee with the full path of their department.
+func orgChartCsv(departments []models.Department, employees []models.Employee) ([]byte, error) {
+	byId := make(map[int]models.Department, len(departments))
+	for _, department := range departments {
+		byId[department.Id] = department
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	err := w.Write([]string{"id", "full_name", "email", "position", "role", "department_id", "department_path", "department_role"})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, employee := range employees {
+		department, ok := byId[employee.DepartmentId]
+
+		departmentId, departmentRole := "", ""
+		if ok {
+			departmentId = strconv.Itoa(department.Id)
+			switch employee.Id {
+			case department.HeadId:
+				departmentRole = "head"
+			case department.DeputyId:
+				departmentRole = "deputy"
+			}
+		}
+
+		err = w.Write([]string{
+			strconv.Itoa(employee.Id),
+			employee.FullName,
+			employee.Email,
+			employee.Position,
+			employee.Role.Role,
+			departmentId,
+			departmentPath(byId, employee.DepartmentId),
+			departmentRole,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+// departmentPath joins department names from the root down to id.
+func departmentPath(byId map[int]models.Department, id int) string {
+	var (
+		names []string
+		seen  = make(map[int]bool)
+	)
+
+	for id != 0 && !seen[id] {
+		department, ok := byId[id]
+		if !ok {
+			break
+		}
+
+		seen[id] = true
+		names = append([]string{department.Name}, names...)
+		id = department.ParentId
+	}
+
+	return strings.Join(names, " / ")
+}
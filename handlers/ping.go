@@ -14,5 +14,5 @@ func Ping(c *gin.Context) {
 		Time:    time.Now(),
 	}
 
-	c.JSON(http.StatusOK, &response)
+	respond(c, &response)
 }
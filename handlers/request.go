@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"io"
+	"sed/apperr"
+	"sed/models"
+	"strconv"
+)
+
+// decode reads the JSON body into v. Fields missing from the body keep the
+// values v already has, which PATCH handlers rely on. On failure it
+// responds with an error and returns false.
+func (h *Handler) decode(c *gin.Context, response *models.Response, v interface{}) bool {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		h.fail(c, response, err)
+		return false
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		h.fail(c, response, apperr.Wrap(apperr.MalformedRequest, err))
+		return false
+	}
+
+	return true
+}
+
+// decodeQuery reads the query string into v by its form tags. On failure
+// it responds with an error and returns false.
+func (h *Handler) decodeQuery(c *gin.Context, response *models.Response, v interface{}) bool {
+	err := c.ShouldBindQuery(v)
+	if err != nil {
+		h.fail(c, response, apperr.Wrap(apperr.MalformedQuery, err))
+		return false
+	}
+
+	return true
+}
+
+// pathId returns the id path parameter. Anything but a number yields 0,
+// which no row has.
+func pathId(c *gin.Context) int {
+	id, _ := strconv.Atoi(c.Param("id"))
+	return id
+}
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"sed/repository"
+	"strconv"
+	"time"
+)
+
+// settledActions are the audit actions of acts leaving the pending status.
+var settledActions = map[string]string{
+	models.ActApproved: "approve",
+	models.ActRejected: "reject",
+}
+
+// ListRetentionRules lists the retention period of every document type
+// that has one.
+func (h *Handler) ListRetentionRules(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	rules, err := h.retention.Rules(c)
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	for i := range rules {
+		labelDocumentType(c, &rules[i].DocumentType)
+		rules[i].DocumentType.Labels = nil
+	}
+
+	response.Payload = rules
+
+	respond(c, &response)
+}
+
+// retentionRule returns the rule of the document type, or false if it has
+// none.
+func (h *Handler) retentionRule(c *gin.Context, documentTypeId int) (models.RetentionRule, bool, error) {
+	rules, err := h.retention.Rules(c)
+	if err != nil {
+		return models.RetentionRule{}, false, err
+	}
+
+	for _, rule := range rules {
+		if rule.DocumentTypeId == documentTypeId {
+			rule.DocumentType = models.DocumentType{}
+			return rule, true, nil
+		}
+	}
+
+	return models.RetentionRule{}, false, nil
+}
+
+// PutRetentionRule sets the retention period of the document type. Admins
+// only.
+func (h *Handler) PutRetentionRule(c *gin.Context) {
+	var (
+		rule     models.RetentionRule
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &rule) {
+		return
+	}
+	rule.DocumentTypeId = pathId(c)
+
+	err := h.validate.Struct(rule)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	documentTypes, err := h.letters.DocumentTypes(c)
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	known := false
+	for _, documentType := range documentTypes {
+		known = known || documentType.Id == rule.DocumentTypeId
+	}
+	if !known {
+		h.fail(c, &response, apperr.New(apperr.DocumentTypeNotFound))
+		return
+	}
+
+	before, found, err := h.retentionRule(c, rule.DocumentTypeId)
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	rule.Id, err = h.retention.SetRule(c, rule)
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	recorded := false
+	if found {
+		recorded = h.record(c, &response, "update", models.AuditRetentionRule, rule.Id, before, rule)
+	} else {
+		recorded = h.record(c, &response, "create", models.AuditRetentionRule, rule.Id, nil, rule)
+	}
+	if !recorded {
+		return
+	}
+
+	response.Payload = rule
+
+	respond(c, &response)
+}
+
+// DeleteRetentionRule removes the retention period of the document type,
+// whose letters are then kept indefinitely. Admins only.
+func (h *Handler) DeleteRetentionRule(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) {
+		return
+	}
+
+	before, found, err := h.retentionRule(c, pathId(c))
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+	if !found {
+		h.fail(c, &response, apperr.New(apperr.RetentionRuleNotFound))
+		return
+	}
+
+	err = h.retention.DeleteRule(c, before.DocumentTypeId)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.RetentionRuleNotFound))
+		return
+	}
+
+	if !h.record(c, &response, "delete", models.AuditRetentionRule, before.Id, before, nil) {
+		return
+	}
+
+	respond(c, &response)
+}
+
+// ListOverdueLetters lists to admins the letters past their retention
+// period that no destruction act covers yet.
+func (h *Handler) ListOverdueLetters(c *gin.Context) {
+	var (
+		retentionFilter = models.RetentionFilter{RowsLimit: DefaultRowsLimit}
+		response        = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) || !h.decodeQuery(c, &response, &retentionFilter) || !h.paged(c, &response, retentionFilter.RowsLimit) {
+		return
+	}
+
+	err := h.validate.Struct(retentionFilter)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	letters, page, err := h.retention.Overdue(c, retentionFilter)
+	if err != nil {
+		h.fail(c, &response, invalidCursor(err))
+		return
+	}
+
+	for i := range letters {
+		labelDocumentType(c, &letters[i].DocumentType)
+		letters[i].DocumentType.Labels = nil
+	}
+
+	response.Payload = letters
+	response.Page = &page
+
+	respond(c, &response)
+}
+
+// ListDestructionActs lists the destruction acts to admins.
+func (h *Handler) ListDestructionActs(c *gin.Context) {
+	var (
+		actFilter = models.DestructionActFilter{RowsLimit: DefaultRowsLimit}
+		response  = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) || !h.decodeQuery(c, &response, &actFilter) || !h.paged(c, &response, actFilter.RowsLimit) {
+		return
+	}
+
+	err := h.validate.Struct(actFilter)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	acts, page, err := h.retention.Acts(c, actFilter)
+	if err != nil {
+		h.fail(c, &response, invalidCursor(err))
+		return
+	}
+
+	response.Payload = acts
+	response.Page = &page
+
+	respond(c, &response)
+}
+
+// PostDestructionAct drafts an act destroying overdue letters, to be
+// approved by the department. Admins only.
+func (h *Handler) PostDestructionAct(c *gin.Context) {
+	var (
+		act      models.DestructionAct
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &act) {
+		return
+	}
+
+	err := h.validate.Struct(act)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	if !h.departmentActive(c, act.DepartmentId, &response) {
+		return
+	}
+
+	act.CreatedBy = c.GetInt("user-id")
+
+	id, err := h.retention.CreateAct(c, act)
+	if errors.Is(err, repository.ErrNotDue) {
+		h.fail(c, &response, apperr.Wrap(apperr.LetterNotDue, err))
+		return
+	}
+	if err != nil {
+		h.fail(c, &response, err)
+		return
+	}
+
+	if !h.record(c, &response, "create", models.AuditDestructionAct, id, nil, h.snapshot(c, models.AuditDestructionAct, id)) {
+		return
+	}
+
+	if h.getDestructionAct(c, &response, id) {
+		created(c, &response, "/api/v2/destruction-acts/"+strconv.Itoa(id))
+		respond(c, &response)
+	}
+}
+
+func (h *Handler) GetDestructionAct(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.requireAdmin(c, &response) && h.getDestructionAct(c, &response, pathId(c)) {
+		respond(c, &response)
+	}
+}
+
+// getDestructionAct sets the payload to the act with id.
+func (h *Handler) getDestructionAct(c *gin.Context, response *models.Response, id int) bool {
+	act, err := h.retention.Act(c, id)
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.DestructionActNotFound))
+		return false
+	}
+
+	response.Payload = act
+
+	return true
+}
+
+// actStep fails response for the errors of a step of act id.
+func (h *Handler) actStep(c *gin.Context, response *models.Response, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrActStatus):
+		h.fail(c, response, apperr.Wrap(apperr.DestructionActStatus, err))
+	default:
+		h.fail(c, response, notFound(err, apperr.DestructionActNotFound))
+	}
+	return false
+}
+
+// SubmitDestructionAct sends a draft act for approval: the department gets
+// an agreement on each of its letters. Admins only.
+func (h *Handler) SubmitDestructionAct(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	id := pathId(c)
+
+	if !h.requireAdmin(c, &response) {
+		return
+	}
+
+	act, err := h.retention.Act(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.DestructionActNotFound))
+		return
+	}
+
+	if !h.departmentActive(c, act.DepartmentId, &response) {
+		return
+	}
+
+	if !h.actStep(c, &response, h.retention.SubmitAct(c, id)) {
+		return
+	}
+
+	if !h.record(c, &response, "submit", models.AuditDestructionAct, id, act, h.snapshot(c, models.AuditDestructionAct, id)) {
+		return
+	}
+
+	if h.getDestructionAct(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// settleAct approves or rejects the pending act id once its agreements
+// are decided. Decisions on acts already settled change nothing. The
+// decision has already been stored, so a failure to settle is logged
+// rather than reported; a settlement that cannot be audited fails the
+// request.
+func (h *Handler) settleAct(c *gin.Context, response *models.Response, id int) bool {
+	before := h.snapshot(c, models.AuditDestructionAct, id)
+
+	status, err := h.retention.SettleAct(c, id)
+	if errors.Is(err, repository.ErrActStatus) {
+		return true
+	}
+	if err != nil {
+		logger(c).Error().Err(err).Int("destruction_act_id", id).Msg("settling the destruction act")
+		return true
+	}
+
+	if action, settled := settledActions[status]; settled {
+		return h.record(c, response, action, models.AuditDestructionAct, id, before, h.snapshot(c, models.AuditDestructionAct, id))
+	}
+
+	return true
+}
+
+// ExecuteDestructionAct destroys the letters of an approved act: their
+// content goes and their registration data stays as a stub. Admins only.
+func (h *Handler) ExecuteDestructionAct(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	id := pathId(c)
+
+	if !h.requireAdmin(c, &response) {
+		return
+	}
+
+	act, err := h.retention.Act(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.DestructionActNotFound))
+		return
+	}
+
+	var letters []models.Letter
+	for _, letterId := range act.LetterIds {
+		letter, err := h.letters.Get(c, letterId)
+		if err == nil {
+			letters = append(letters, letter)
+		}
+	}
+
+	if !h.actStep(c, &response, h.retention.ExecuteAct(c, id)) {
+		return
+	}
+
+	if !h.record(c, &response, "execute", models.AuditDestructionAct, id, act, h.snapshot(c, models.AuditDestructionAct, id)) {
+		return
+	}
+	for _, before := range letters {
+		if !h.record(c, &response, "destroy", models.AuditLetter, before.Id, before, h.snapshot(c, models.AuditLetter, before.Id)) {
+			return
+		}
+	}
+
+	if h.getDestructionAct(c, &response, id) {
+		respond(c, &response)
+	}
+}
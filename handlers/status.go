@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"sed/apperr"
+	"sed/i18n"
+	"sed/models"
+	"sed/repository"
+	"strconv"
+)
+
+// LegacyStatusHeader lets a client opt into the old behaviour of answering
+// every request with HTTP 200 and carrying the real code only in the
+// envelope.
+const LegacyStatusHeader = "X-Legacy-Status"
+
+const legacyStatusKey = "legacy-status"
+
+// LegacyStatus marks requests that must be answered with HTTP 200. With
+// always set every request is marked, otherwise only those sending a true
+// LegacyStatusHeader.
+func LegacyStatus(always bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		legacy, _ := strconv.ParseBool(c.GetHeader(LegacyStatusHeader))
+		if always || legacy {
+			c.Set(legacyStatusKey, true)
+		}
+
+		c.Next()
+	}
+}
+
+// respond writes response with its code as the HTTP status, or with 200
+// for legacy clients. The message is translated into the request language.
+func respond(c *gin.Context, response *models.Response) {
+	status := response.Code
+	if c.GetBool(legacyStatusKey) {
+		status = http.StatusOK
+	}
+
+	response.Message = i18n.T(language(c), response.Message)
+	response.RequestId = c.GetString(requestIdKey)
+
+	c.JSON(status, response)
+}
+
+// fail fills response from err and writes it. Errors outside the apperr
+// catalog are logged and reported to the client as INTERNAL with the
+// request id as the correlation id only.
+func (h *Handler) fail(c *gin.Context, response *models.Response, err error) {
+	e := apperr.From(err)
+
+	if e.Code == apperr.Internal {
+		response.CorrelationId = c.GetString(requestIdKey)
+		logger(c).Error().Err(err).Str("route", c.FullPath()).Msg("internal error")
+	}
+
+	c.Set(errorCodeKey, string(e.Code))
+
+	response.Code = e.Status
+	response.Message = e.Message
+	response.Error = string(e.Code)
+	response.Details = h.details(c, e)
+
+	respond(c, response)
+}
+
+// details returns the field errors of e with messages in the request
+// language.
+func (h *Handler) details(c *gin.Context, e *apperr.Error) []models.FieldError {
+	var failed validator.ValidationErrors
+	errors.As(e, &failed)
+
+	details := append([]models.FieldError(nil), e.Fields...)
+	for i := range details {
+		switch {
+		case details[i].Message != "":
+		case i < len(failed):
+			details[i].Message = h.translations.Field(language(c), failed[i])
+		default:
+			details[i].Message = h.translations.Rule(language(c), details[i].Field, details[i].Rule, details[i].Param)
+		}
+	}
+
+	return details
+}
+
+// notFound turns repository.ErrNotFound into the catalog error for code and
+// passes any other error through.
+func notFound(err error, code apperr.Code) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return apperr.Wrap(code, err)
+	}
+	return err
+}
+
+// invalidCursor turns repository.ErrInvalidCursor into INVALID_CURSOR and
+// passes any other error through.
+func invalidCursor(err error) error {
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return apperr.Wrap(apperr.InvalidCursor, err)
+	}
+	return err
+}
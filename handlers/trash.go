@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"sed/repository"
+	"time"
+)
+
+// DeleteLetter moves the letter to the trash. It disappears from lists,
+// searches and agreement inboxes until an admin restores it. Admins only.
+func (h *Handler) DeleteLetter(c *gin.Context) {
+	var (
+		deletion models.LetterDeletion
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.requireAdmin(c, &response) && h.decode(c, &response, &deletion) && h.deleteLetter(c, &response, pathId(c), deletion) {
+		respond(c, &response)
+	}
+}
+
+// deleteLetter moves the letter with id to the trash for deletion.Reason.
+func (h *Handler) deleteLetter(c *gin.Context, response *models.Response, id int, deletion models.LetterDeletion) bool {
+	err := h.validate.Struct(deletion)
+	if err != nil {
+		h.fail(c, response, apperr.Validation(err))
+		return false
+	}
+
+	before := h.snapshot(c, models.AuditLetter, id)
+
+	err = h.letters.Delete(c, id, c.GetInt("user-id"), deletion.Reason)
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.LetterNotFound))
+		return false
+	}
+
+	deleted, err := h.letters.Deleted(c, id)
+	if err != nil {
+		h.fail(c, response, err)
+		return false
+	}
+
+	if !h.record(c, response, "delete", models.AuditLetter, id, before, deleted) {
+		return false
+	}
+
+	return true
+}
+
+// ListTrash lists the letters in the trash to admins.
+func (h *Handler) ListTrash(c *gin.Context) {
+	var (
+		trashFilter = models.TrashFilter{RowsLimit: DefaultRowsLimit}
+		response    = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.requireAdmin(c, &response) && h.decodeQuery(c, &response, &trashFilter) && h.paged(c, &response, trashFilter.RowsLimit) && h.listTrash(c, &response, trashFilter) {
+		respond(c, &response)
+	}
+}
+
+// listTrash sets the payload to the letters in the trash.
+func (h *Handler) listTrash(c *gin.Context, response *models.Response, trashFilter models.TrashFilter) bool {
+	err := h.validate.Struct(trashFilter)
+	if err != nil {
+		h.fail(c, response, apperr.Validation(err))
+		return false
+	}
+
+	letters, page, err := h.letters.Trash(c, trashFilter)
+	if err != nil {
+		h.fail(c, response, invalidCursor(err))
+		return false
+	}
+
+	for i := range letters {
+		labelDocumentType(c, &letters[i].DocumentType)
+		letters[i].DocumentType.Labels = nil
+	}
+
+	response.Payload = letters
+	response.Page = &page
+
+	return true
+}
+
+// RestoreLetter takes the letter out of the trash and answers with it.
+// Admins only.
+func (h *Handler) RestoreLetter(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	id := pathId(c)
+
+	if !h.requireAdmin(c, &response) {
+		return
+	}
+
+	deleted, err := h.letters.Deleted(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.LetterNotFound))
+		return
+	}
+
+	err = h.letters.Restore(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.LetterNotFound))
+		return
+	}
+
+	if !h.record(c, &response, "restore", models.AuditLetter, id, deleted, h.snapshot(c, models.AuditLetter, id)) {
+		return
+	}
+
+	if h.getDocument(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// PurgeLetter removes a letter from the trash for good once it has been
+// there for the configured retention, unless a destruction act lists it.
+// Admins only. The audit log keeps the letter as it was.
+func (h *Handler) PurgeLetter(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	id := pathId(c)
+
+	if !h.requireAdmin(c, &response) {
+		return
+	}
+
+	deleted, err := h.letters.Deleted(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.LetterNotFound))
+		return
+	}
+
+	if time.Since(*deleted.DeletedAt) < h.storage.TrashRetention {
+		h.fail(c, &response, apperr.New(apperr.LetterRetained))
+		return
+	}
+
+	err = h.letters.Purge(c, id)
+	if errors.Is(err, repository.ErrInAct) {
+		h.fail(c, &response, apperr.Wrap(apperr.LetterInAct, err))
+		return
+	}
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.LetterNotFound))
+		return
+	}
+
+	if !h.record(c, &response, "purge", models.AuditLetter, id, deleted, nil) {
+		return
+	}
+
+	respond(c, &response)
+}
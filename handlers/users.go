@@ -1,31 +1,20 @@
 package handlers
 
 import (
-	"context"
-	"encoding/json"
-	"errors"
 	"github.com/JAbduvohidov/jwt"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
-	"github.com/jackc/pgx/v4"
 	"golang.org/x/crypto/bcrypt"
-	"io"
-	"log"
 	"net/http"
-	"sed/db"
+	"sed/apperr"
 	"sed/models"
 	"strconv"
 	"strings"
 	"time"
 )
 
-var Validate *validator.Validate
-
-func Authorization(c *gin.Context) {
+func (h *Handler) Authorization(c *gin.Context) {
 	response := models.Response{
-		Code:    http.StatusUnauthorized,
-		Message: http.StatusText(http.StatusUnauthorized),
-		Time:    time.Now(),
+		Time: time.Now(),
 	}
 
 	header := c.GetHeader("Authorization")
@@ -33,51 +22,66 @@ func Authorization(c *gin.Context) {
 	parts := strings.Split(header, " ")
 
 	if len(parts) != 2 {
-		response.Message = "invalid authorization header"
-		c.AbortWithStatusJSON(http.StatusOK, response)
+		c.Abort()
+		h.fail(c, &response, apperr.New(apperr.Unauthorized))
 		return
 	}
 
 	authorizationType, token := parts[0], parts[1]
 
 	if authorizationType != "Bearer" {
-		response.Message = "invalid authorization type"
-		c.AbortWithStatusJSON(http.StatusOK, response)
+		c.Abort()
+		h.fail(c, &response, apperr.New(apperr.Unauthorized))
 		return
 	}
 
 	if len(token) < 30 {
-		response.Message = "invalid token"
-		c.AbortWithStatusJSON(http.StatusOK, response)
+		c.Abort()
+		h.fail(c, &response, apperr.New(apperr.Unauthorized))
 		return
 	}
 
-	id := 0
-	err := db.Pool.QueryRow(
-		c,
-		`select id
-from employees
-where token = $1;`,
-		token,
-	).Scan(&id)
-	if err != nil {
-		response.Message = err.Error()
-		c.AbortWithStatusJSON(http.StatusOK, response)
+	if !h.validToken(token) {
+		c.Abort()
+		h.fail(c, &response, apperr.New(apperr.Unauthorized))
 		return
 	}
 
-	if id == 0 {
-		response.Message = "user not found or invalid token"
-		c.AbortWithStatusJSON(http.StatusOK, response)
+	employee, err := h.employees.ByToken(c, token)
+	if err != nil {
+		c.Abort()
+		h.fail(c, &response, notFound(err, apperr.Unauthorized))
 		return
 	}
 
-	c.Set("user-id", id)
+	if employee.Language != "" {
+		c.Set(languageKey, employee.Language)
+	}
+
+	c.Set("user-id", employee.Id)
 
 	c.Next()
 }
 
-func Login(c *gin.Context) {
+// validToken reports whether token was signed with the configured secret
+// and has not expired yet.
+func (h *Handler) validToken(token string) bool {
+	ok, err := jwt.Verify(token, jwt.Secret(h.auth.JWTSecret))
+	if err != nil || !ok {
+		return false
+	}
+
+	var payload models.Token
+	err = jwt.Decode(token, &payload)
+	if err != nil {
+		return false
+	}
+
+	ok, err = jwt.IsNotExpired(payload, time.Now())
+	return err == nil && ok
+}
+
+func (h *Handler) Login(c *gin.Context) {
 	var (
 		employee models.Employee
 		response = models.Response{
@@ -87,116 +91,57 @@ func Login(c *gin.Context) {
 		}
 	)
 
-	data, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Println("unable to read body data:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
-	}
-
-	err = json.Unmarshal(data, &employee)
-	if err != nil {
-		log.Println("error unmarshaling employee:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
+	if !h.decode(c, &response, &employee) {
 		return
 	}
 
-	err = Validate.Struct(employee)
+	err := h.validate.Struct(employee)
 	if err != nil {
-		response.Code = http.StatusBadRequest
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
+		h.fail(c, &response, apperr.Validation(err))
 		return
 	}
 
-	id := 0
-	passwordHash := ""
-	role := ""
-	name := ""
-	email := ""
-	err = db.Pool.QueryRow(
-		context.Background(),
-		`select e.id, e.password, rg.role, e.full_name, e.email
-from employees e
-         left join role_group rg on e.role_id = rg.id
-where email = $1;`,
-		employee.Email,
-	).Scan(
-		&id,
-		&passwordHash,
-		&role,
-		&name,
-		&email,
-	)
+	stored, err := h.employees.ByEmail(c, employee.Email)
 	if err != nil {
-		if errors.Is(pgx.ErrNoRows, err) {
-			response.Code = http.StatusUnauthorized
-			response.Message = "invalid user"
-			c.JSON(http.StatusOK, &response)
-			return
-		}
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
+		h.fail(c, &response, notFound(err, apperr.InvalidCredentials))
 		return
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(employee.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte(employee.Password))
 	if err != nil {
-		response.Code = http.StatusUnauthorized
-		response.Message = "invalid user password"
-		c.JSON(http.StatusOK, &response)
+		h.fail(c, &response, apperr.New(apperr.InvalidCredentials))
 		return
 	}
 
 	token, err := jwt.Encode(models.Token{
-		Id:    id,
+		Id:    stored.Id,
 		Email: employee.Email,
-	}, jwt.Secret("secret"))
+		Exp:   time.Now().Add(h.auth.TokenTTL).Unix(),
+	}, jwt.Secret(h.auth.JWTSecret))
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
+		h.fail(c, &response, err)
 		return
 	}
 
-	_, err = db.Pool.Exec(
-		context.Background(),
-		`update employees set token = $1 where email = $2;`,
-		token,
-		employee.Email,
-	)
+	err = h.employees.SetToken(c, stored.Id, token)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
+		h.fail(c, &response, err)
 		return
 	}
 
-	response.Payload = struct {
-		Token string `json:"token"`
-		Role  string `json:"role"`
-		Id    int    `json:"id"`
-		Name  string `json:"name"`
-		Email string `json:"email"`
-	}{
+	response.Payload = models.Session{
 		Token: token,
-		Role:  role,
-		Id:    id,
-		Name:  name,
-		Email: email,
+		Role:  stored.Role.Role,
+		Id:    stored.Id,
+		Name:  stored.FullName,
+		Email: stored.Email,
 	}
 
-	c.JSON(http.StatusOK, &response)
+	respond(c, &response)
 }
 
-func GetUsers(c *gin.Context) {
+func (h *Handler) GetUsers(c *gin.Context) {
 	var (
-		employees      []models.Employee
 		employeeFilter = models.EmployeeFilter{}
 		response       = models.Response{
 			Code:    http.StatusOK,
@@ -205,79 +150,38 @@ func GetUsers(c *gin.Context) {
 		}
 	)
 
-	data, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Println("unable to read body data:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
+	if !h.decode(c, &response, &employeeFilter) {
 		return
 	}
 
-	err = json.Unmarshal(data, &employeeFilter)
-	if err != nil {
-		log.Println("error unmarshaling employee:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
+	if h.listUsers(c, &response, employeeFilter) {
+		respond(c, &response)
 	}
+}
 
-	err = Validate.Struct(employeeFilter)
+// listUsers sets the payload to the employees matching employeeFilter.
+func (h *Handler) listUsers(c *gin.Context, response *models.Response, employeeFilter models.EmployeeFilter) bool {
+	err := h.validate.Struct(employeeFilter)
 	if err != nil {
-		response.Code = http.StatusBadRequest
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, apperr.Validation(err))
+		return false
 	}
 
-	rows, err := db.Pool.Query(
-		c,
-		`select e.id, e.full_name, rg.role, e.email, d.name
-	from employees e
-	left join role_group rg on e.role_id = rg.id
-	left join departments d on e.department_id = d.id
-	order by e.id desc
-	offset $1 limit $2; `,
-		employeeFilter.RowsOffset,
-		employeeFilter.RowsLimit,
-	)
+	employees, page, err := h.employees.List(c, employeeFilter)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
-	}
-
-	for rows.Next() {
-		employee := models.Employee{}
-		err = rows.Scan(
-			&employee.Id,
-			&employee.FullName,
-			&employee.Role.Role,
-			&employee.Email,
-			&employee.Department.Name,
-		)
-
-		if err != nil {
-			response.Code = http.StatusInternalServerError
-			response.Message = err.Error()
-			c.JSON(http.StatusOK, &response)
-			return
-		}
-
-		employees = append(employees, employee)
+		h.fail(c, response, invalidCursor(err))
+		return false
 	}
 
 	response.Payload = employees
+	response.Page = &page
 
-	c.JSON(http.StatusOK, &response)
+	return true
 }
 
-func EditUser(c *gin.Context) {
+func (h *Handler) EditUser(c *gin.Context) {
 	var (
 		externalEmployee models.Employee
-		internalEmployee models.Employee
 		response         = models.Response{
 			Code:    http.StatusOK,
 			Message: http.StatusText(http.StatusOK),
@@ -285,98 +189,68 @@ func EditUser(c *gin.Context) {
 		}
 	)
 
+	if !h.decode(c, &response, &externalEmployee) {
+		return
+	}
+
+	if h.requireAdmin(c, &response) && h.editUser(c, &response, externalEmployee) {
+		respond(c, &response)
+	}
+}
+
+// editUser overwrites the employee with externalEmployee, or the caller
+// when it has no id. Callers check that the caller is an admin.
+func (h *Handler) editUser(c *gin.Context, response *models.Response, externalEmployee models.Employee) bool {
 	userId := c.GetInt("user-id")
 
-	data, err := io.ReadAll(c.Request.Body)
+	err := h.validate.Struct(externalEmployee)
 	if err != nil {
-		log.Println("unable to read body data:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, apperr.Validation(err))
+		return false
 	}
-	err = json.Unmarshal(data, &externalEmployee)
-	if err != nil {
-		log.Println("error unmarshaling externalEmployee:", err)
-		response.Code = http.StatusInternalServerError
-		response.Message = http.StatusText(http.StatusInternalServerError)
-		c.JSON(http.StatusOK, &response)
-		return
+
+	if externalEmployee.Id == 0 {
+		externalEmployee.Id = userId
 	}
 
-	err = Validate.Struct(externalEmployee)
-	if err != nil {
-		response.Code = http.StatusBadRequest
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	if externalEmployee.DepartmentId != 0 && !h.departmentActive(c, externalEmployee.DepartmentId, response) {
+		return false
 	}
 
-	err = db.Pool.QueryRow(
-		c,
-		`select e.id,
-       e.full_name,
-       rg.role,
-       e.email,
-       d.name
-from employees e
-         left join role_group rg on e.role_id = rg.id
-         left join departments d on e.department_id = d.id
-where e.id = $1;`,
-		userId,
-	).Scan(
-		&internalEmployee.Id,
-		&internalEmployee.FullName,
-		&internalEmployee.Role.Role,
-		&internalEmployee.Email,
-		&internalEmployee.Department.Name,
-	)
+	before := h.snapshot(c, models.AuditEmployee, externalEmployee.Id)
+
+	err = h.employees.Update(c, externalEmployee, userId)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, notFound(err, apperr.EmployeeNotFound))
+		return false
 	}
 
-	if internalEmployee.Role.Role != "ADMIN" {
-		response.Code = http.StatusBadRequest
-		response.Message = "no access to this page"
-		c.JSON(http.StatusOK, &response)
-		return
+	if !h.record(c, response, "update", models.AuditEmployee, externalEmployee.Id, before, h.snapshot(c, models.AuditEmployee, externalEmployee.Id)) {
+		return false
 	}
 
-	rtn, err := db.Pool.Exec(
-		c,
-		`update employees
-set full_name     = $1,
-    role_id       = $2,
-    department_id = $3
-where id = $4;`,
-		externalEmployee.FullName,
-		externalEmployee.RoleId,
-		externalEmployee.DepartmentId,
-		userId,
-	)
+	return true
+}
+
+// requireAdmin responds with FORBIDDEN and returns false unless the caller
+// has the ADMIN role.
+func (h *Handler) requireAdmin(c *gin.Context, response *models.Response) bool {
+	employee, err := h.employees.Get(c, c.GetInt("user-id"))
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, err)
+		return false
 	}
 
-	if rtn.RowsAffected() < 1 {
-		response.Code = http.StatusBadRequest
-		response.Message = pgx.ErrNoRows.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+	if employee.Role.Role != "ADMIN" {
+		h.fail(c, response, apperr.New(apperr.Forbidden))
+		return false
 	}
 
-	c.JSON(http.StatusOK, &response)
+	return true
 }
 
-func GetProfile(c *gin.Context) {
+func (h *Handler) GetProfile(c *gin.Context) {
 	var (
-		employee = models.Employee{}
 		response = models.Response{
 			Code:    http.StatusOK,
 			Message: http.StatusText(http.StatusOK),
@@ -385,29 +259,9 @@ func GetProfile(c *gin.Context) {
 	)
 
 	userId := c.GetInt("user-id")
-	err := db.Pool.QueryRow(
-		c,
-		`select e.id,
-       e.full_name,
-       rg.role,
-       e.email,
-       d.name
-from employees e
-         left join role_group rg on e.role_id = rg.id
-         left join departments d on e.department_id = d.id
-where e.id = $1;`,
-		userId,
-	).Scan(
-		&employee.Id,
-		&employee.FullName,
-		&employee.Role.Role,
-		&employee.Email,
-		&employee.Department.Name,
-	)
+	employee, err := h.employees.Get(c, userId)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
+		h.fail(c, &response, err)
 		return
 	}
 
@@ -415,51 +269,29 @@ where e.id = $1;`,
 
 	if employee.Id == paramUserId {
 		response.Payload = employee
-		c.JSON(http.StatusOK, &response)
+		respond(c, &response)
 		return
 	}
 
 	if employee.Role.Role != "ADMIN" {
-		response.Code = http.StatusBadRequest
-		response.Message = "no access to this page"
-		c.JSON(http.StatusOK, &response)
+		h.fail(c, &response, apperr.New(apperr.Forbidden))
 		return
 	}
 
-	err = db.Pool.QueryRow(
-		c,
-		`select e.id,
-       e.full_name,
-       rg.role,
-       e.email,
-       d.name
-from employees e
-         left join role_group rg on e.role_id = rg.id
-         left join departments d on e.department_id = d.id
-where e.id = $1;`,
-		paramUserId,
-	).Scan(
-		&employee.Id,
-		&employee.FullName,
-		&employee.Role.Role,
-		&employee.Email,
-		&employee.Department.Name,
-	)
+	employee, err = h.employees.Get(c, paramUserId)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
+		h.fail(c, &response, notFound(err, apperr.EmployeeNotFound))
 		return
 	}
 
 	response.Payload = employee
 
-	c.JSON(http.StatusOK, &response)
+	respond(c, &response)
 }
 
-func GetRoles(c *gin.Context) {
+func (h *Handler) GetRoles(c *gin.Context) {
 	var (
-		roleGroups []models.RoleGroup
+		roleFilter models.RoleFilter
 		response   = models.Response{
 			Code:    http.StatusOK,
 			Message: http.StatusText(http.StatusOK),
@@ -467,36 +299,59 @@ func GetRoles(c *gin.Context) {
 		}
 	)
 
-	rows, err := db.Pool.Query(
-		c,
-		`select id, role
-from role_group;`,
-	)
+	if h.decodeQuery(c, &response, &roleFilter) && h.listRoles(c, &response, roleFilter) {
+		respond(c, &response)
+	}
+}
+
+// listRoles sets the payload to the role groups selected by roleFilter.
+func (h *Handler) listRoles(c *gin.Context, response *models.Response, roleFilter models.RoleFilter) bool {
+	err := h.validate.Struct(roleFilter)
 	if err != nil {
-		response.Code = http.StatusInternalServerError
-		response.Message = err.Error()
-		c.JSON(http.StatusOK, &response)
-		return
+		h.fail(c, response, apperr.Validation(err))
+		return false
+	}
+
+	roleGroups, page, err := h.employees.Roles(c, roleFilter)
+	if err != nil {
+		h.fail(c, response, invalidCursor(err))
+		return false
 	}
 
-	for rows.Next() {
-		roleGroup := models.RoleGroup{}
-		err = rows.Scan(
-			&roleGroup.Id,
-			&roleGroup.Role,
-		)
+	for i := range roleGroups {
+		labelRoleGroup(c, &roleGroups[i])
+	}
+
+	response.Payload = roleGroups
+	response.Page = &page
+
+	return true
+}
 
-		if err != nil {
-			response.Code = http.StatusInternalServerError
-			response.Message = err.Error()
-			c.JSON(http.StatusOK, &response)
-			return
+// GetEmployeeTransfers lists the department transfers of an employee to
+// the employee and to admins.
+func (h *Handler) GetEmployeeTransfers(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
 		}
+	)
+
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if id != c.GetInt("user-id") && !h.requireAdmin(c, &response) {
+		return
+	}
 
-		roleGroups = append(roleGroups, roleGroup)
+	transfers, err := h.employees.Transfers(c, id)
+	if err != nil {
+		h.fail(c, &response, err)
+		return
 	}
 
-	response.Payload = roleGroups
+	response.Payload = transfers
 
-	c.JSON(http.StatusOK, &response)
+	respond(c, &response)
 }
@@ -0,0 +1,518 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"strconv"
+	"time"
+)
+
+// The /api/v2 handlers below adapt resource-oriented requests to the same
+// logic as the legacy routes: reads are GETs with filters in the query
+// string, ids come from the path, PATCH bodies are merged onto the stored
+// resource and POSTs creating a resource answer 201. Routes that already
+// read only their path and query reuse the legacy handlers directly.
+
+// DefaultRowsLimit is the page size of v2 lists without rows_limit.
+const DefaultRowsLimit = 20
+
+// MaxRowsLimit is the largest page size of any list.
+const MaxRowsLimit = 500
+
+// paged fails with a validation error when rowsLimit is 0, which lists
+// every row through the legacy API only, or above MaxRowsLimit. Every v2
+// list goes through it.
+func (h *Handler) paged(c *gin.Context, response *models.Response, rowsLimit uint) bool {
+	switch {
+	case rowsLimit == 0:
+		h.fail(c, response, apperr.Field("rows_limit", "required"))
+		return false
+	case rowsLimit > MaxRowsLimit:
+		h.fail(c, response, apperr.Field("rows_limit", "max"))
+		return false
+	}
+
+	return true
+}
+
+// created turns response into a 201 pointing at location, if any.
+func created(c *gin.Context, response *models.Response, location string) {
+	response.Code = http.StatusCreated
+	response.Message = http.StatusText(http.StatusCreated)
+
+	if location != "" {
+		c.Header("Location", location)
+	}
+}
+
+func (h *Handler) ListLetters(c *gin.Context) {
+	var (
+		documentFilter = models.LetterFilter{RowsLimit: DefaultRowsLimit}
+		response       = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.decodeQuery(c, &response, &documentFilter) && h.paged(c, &response, documentFilter.RowsLimit) && h.listDocuments(c, &response, documentFilter) {
+		respond(c, &response)
+	}
+}
+
+func (h *Handler) PostLetter(c *gin.Context) {
+	var (
+		documentLetter models.Letter
+		response       = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.decode(c, &response, &documentLetter) || !h.createDocument(c, &response, documentLetter) {
+		return
+	}
+
+	id := response.Payload.(int)
+	if h.getDocument(c, &response, id) {
+		created(c, &response, "/api/v2/letters/"+strconv.Itoa(id))
+		respond(c, &response)
+	}
+}
+
+// PatchLetter changes the fields present in the body and answers with the
+// updated letter.
+func (h *Handler) PatchLetter(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	id := pathId(c)
+
+	documentLetter, err := h.letters.Get(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.LetterNotFound))
+		return
+	}
+
+	// The stored version is not a precondition: the client sends the one
+	// it read.
+	documentLetter.Version = 0
+	if !h.decode(c, &response, &documentLetter) {
+		return
+	}
+	documentLetter.Id = id
+
+	if h.editDocument(c, &response, documentLetter) && h.getDocument(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// PostLetterAssignment assigns the letter to a department and executive.
+func (h *Handler) PostLetterAssignment(c *gin.Context) {
+	var (
+		describedLetter models.DescribedLetter
+		response        = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.decode(c, &response, &describedLetter) {
+		return
+	}
+	describedLetter.LetterId = pathId(c)
+
+	if h.describeLetter(c, &response, describedLetter) {
+		created(c, &response, "")
+		respond(c, &response)
+	}
+}
+
+func (h *Handler) ListUsers(c *gin.Context) {
+	var (
+		employeeFilter = models.EmployeeFilter{RowsLimit: DefaultRowsLimit}
+		response       = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.decodeQuery(c, &response, &employeeFilter) && h.paged(c, &response, employeeFilter.RowsLimit) && h.listUsers(c, &response, employeeFilter) {
+		respond(c, &response)
+	}
+}
+
+// PatchUser changes the fields present in the body and answers with the
+// updated employee. Admins only.
+func (h *Handler) PatchUser(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) {
+		return
+	}
+
+	id := pathId(c)
+
+	employee, err := h.employees.Get(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.EmployeeNotFound))
+		return
+	}
+
+	if !h.decode(c, &response, &employee) {
+		return
+	}
+	employee.Id = id
+
+	if !h.editUser(c, &response, employee) {
+		return
+	}
+
+	employee, err = h.employees.Get(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.EmployeeNotFound))
+		return
+	}
+
+	response.Payload = employee
+
+	respond(c, &response)
+}
+
+func (h *Handler) ListRoles(c *gin.Context) {
+	var (
+		roleFilter = models.RoleFilter{RowsLimit: DefaultRowsLimit}
+		response   = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.decodeQuery(c, &response, &roleFilter) && h.paged(c, &response, roleFilter.RowsLimit) && h.listRoles(c, &response, roleFilter) {
+		respond(c, &response)
+	}
+}
+
+func (h *Handler) ListDepartments(c *gin.Context) {
+	var (
+		departmentFilter = models.DepartmentFilter{RowsLimit: DefaultRowsLimit}
+		response         = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.decodeQuery(c, &response, &departmentFilter) && h.paged(c, &response, departmentFilter.RowsLimit) && h.listDepartments(c, &response, departmentFilter) {
+		respond(c, &response)
+	}
+}
+
+func (h *Handler) GetDepartment(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.getDepartment(c, &response, pathId(c)) {
+		respond(c, &response)
+	}
+}
+
+// getDepartment sets the payload to the department with id.
+func (h *Handler) getDepartment(c *gin.Context, response *models.Response, id int) bool {
+	department, err := h.departments.Get(c, id)
+	if err != nil {
+		h.fail(c, response, notFound(err, apperr.DepartmentNotFound))
+		return false
+	}
+
+	response.Payload = department
+	setETag(c, department.Version)
+
+	return true
+}
+
+// PostDepartment creates a department and answers with it. Admins only.
+func (h *Handler) PostDepartment(c *gin.Context) {
+	var (
+		department models.Department
+		response   = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &department) {
+		return
+	}
+
+	id, ok := h.createDepartment(c, &response, department)
+	if ok && h.getDepartment(c, &response, id) {
+		created(c, &response, "/api/v2/departments/"+strconv.Itoa(id))
+		respond(c, &response)
+	}
+}
+
+// PatchDepartment changes the name, internal number and phone present in
+// the body and answers with the updated department. The parent and heads
+// have resources of their own. Admins only.
+func (h *Handler) PatchDepartment(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	id := pathId(c)
+
+	if !h.requireAdmin(c, &response) {
+		return
+	}
+
+	department, err := h.departments.Get(c, id)
+	if err != nil {
+		h.fail(c, &response, notFound(err, apperr.DepartmentNotFound))
+		return
+	}
+
+	// The stored version is not a precondition: the client sends the one
+	// it read.
+	department.Version = 0
+	if !h.decode(c, &response, &department) {
+		return
+	}
+	department.Id = id
+
+	if h.editDepartment(c, &response, department) && h.getDepartment(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// DeleteDepartment archives the department. Admins only.
+func (h *Handler) DeleteDepartment(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.requireAdmin(c, &response) && h.archiveDepartment(c, &response, pathId(c)) {
+		respond(c, &response)
+	}
+}
+
+// PutDepartmentParent places the department under another parent. Admins
+// only.
+func (h *Handler) PutDepartmentParent(c *gin.Context) {
+	var (
+		parent   models.DepartmentParent
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &parent) {
+		return
+	}
+
+	err := h.validate.Struct(parent)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	id := pathId(c)
+	if h.moveDepartment(c, &response, id, parent.ParentId) && h.getDepartment(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// PutDepartmentHeads sets the head and deputy of the department. Admins
+// only.
+func (h *Handler) PutDepartmentHeads(c *gin.Context) {
+	var (
+		heads    models.DepartmentHeads
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &heads) {
+		return
+	}
+
+	err := h.validate.Struct(heads)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	id := pathId(c)
+	if h.assignDepartmentHeads(c, &response, id, heads.HeadId, heads.DeputyId) && h.getDepartment(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// PostDepartmentMerge merges the department into target_id. Admins only.
+func (h *Handler) PostDepartmentMerge(c *gin.Context) {
+	var (
+		target   models.DepartmentMergeTarget
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.requireAdmin(c, &response) || !h.decode(c, &response, &target) {
+		return
+	}
+
+	err := h.validate.Struct(target)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	if h.mergeDepartment(c, &response, models.DepartmentMerge{SourceId: pathId(c), TargetId: target.TargetId}) {
+		respond(c, &response)
+	}
+}
+
+func (h *Handler) ListDepartmentUsers(c *gin.Context) {
+	var (
+		employeeFilter = models.DepartmentEmployeeFilter{RowsLimit: DefaultRowsLimit}
+		response       = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.decodeQuery(c, &response, &employeeFilter) && h.paged(c, &response, employeeFilter.RowsLimit) && h.listDepartmentEmployees(c, &response, pathId(c), employeeFilter) {
+		respond(c, &response)
+	}
+}
+
+func (h *Handler) ListAgreements(c *gin.Context) {
+	var (
+		agreementFilter = models.AgreementFilter{RowsLimit: DefaultRowsLimit}
+		response        = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.decodeQuery(c, &response, &agreementFilter) && h.paged(c, &response, agreementFilter.RowsLimit) && h.listAgreements(c, &response, agreementFilter) {
+		respond(c, &response)
+	}
+}
+
+func (h *Handler) PostAgreement(c *gin.Context) {
+	var (
+		agreement models.Agreement
+		response  = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if h.decode(c, &response, &agreement) && h.createAgreement(c, &response, agreement) {
+		created(c, &response, "")
+		respond(c, &response)
+	}
+}
+
+// GetAgreement answers with the agreement without marking it viewed.
+func (h *Handler) GetAgreement(c *gin.Context) {
+	var (
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	id := pathId(c)
+
+	if h.agreementVisible(c, &response, id) && h.getAgreement(c, &response, id) {
+		respond(c, &response)
+	}
+}
+
+// PatchAgreement marks the agreement viewed and records the decision in
+// the body, whichever it holds, and answers with the agreement.
+func (h *Handler) PatchAgreement(c *gin.Context) {
+	var (
+		decision models.AgreementDecision
+		response = models.Response{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+			Time:    time.Now(),
+		}
+	)
+
+	if !h.decode(c, &response, &decision) {
+		return
+	}
+
+	err := h.validate.Struct(decision)
+	if err != nil {
+		h.fail(c, &response, apperr.Validation(err))
+		return
+	}
+
+	id := pathId(c)
+
+	if decision.Viewed != nil {
+		if !h.agreementVisible(c, &response, id) {
+			return
+		}
+
+		err = h.agreements.MarkViewed(c, id)
+		if err != nil {
+			h.fail(c, &response, notFound(err, apperr.AgreementNotFound))
+			return
+		}
+	}
+
+	if decision.Agreed != nil && !h.decideAgreement(c, &response, id, *decision.Agreed) {
+		return
+	}
+
+	if h.getAgreement(c, &response, id) {
+		respond(c, &response)
+	}
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"strings"
+)
+
+// Letters and departments carry a version that every change bumps. Reads
+// send it as a strong ETag; edits send it back as If-Match or as the
+// version field and fail with VERSION_CONFLICT, carrying the current state,
+// when someone else changed the resource in between.
+
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+// expectedVersion returns the version an edit was based on: the one in
+// If-Match, which takes precedence, or version from the body. 0 means the
+// client sent neither.
+func expectedVersion(c *gin.Context, version int) int {
+	match := strings.Trim(strings.TrimPrefix(c.GetHeader("If-Match"), "W/"), `"`)
+	if n, err := strconv.Atoi(match); err == nil && n > 0 {
+		return n
+	}
+	return version
+}
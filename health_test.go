@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sed/apperr"
+	"sed/db"
+	"sed/models"
+	"sed/repository"
+	"testing"
+)
+
+// brokenHealth fails the database checks of the embedded HealthRepository.
+type brokenHealth struct {
+	repository.HealthRepository
+	down    bool
+	pending bool
+}
+
+func (h brokenHealth) Ping(ctx context.Context) error {
+	if h.down {
+		return errors.New("dial tcp 127.0.0.1:5432: connect: connection refused")
+	}
+	return h.HealthRepository.Ping(ctx)
+}
+
+func (h brokenHealth) Migrations(ctx context.Context) ([]db.Migration, error) {
+	migrations, err := h.HealthRepository.Migrations(ctx)
+	if h.pending && err == nil {
+		migrations[len(migrations)-1].AppliedAt = nil
+	}
+	return migrations, err
+}
+
+func TestHealthz(t *testing.T) {
+	s := newTestServer(t)
+
+	s.ok(s.do(http.MethodGet, "/healthz", "", nil), nil)
+}
+
+func TestReadyz(t *testing.T) {
+	migrations, err := db.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrations[len(migrations)-1].Version
+
+	tests := []struct {
+		name     string
+		health   brokenHealth
+		database string
+		applied  int
+		pending  []int
+	}{
+		{"ready", brokenHealth{}, "ok", len(migrations), nil},
+		{"database down", brokenHealth{down: true}, "unavailable", 0, nil},
+		{"pending migration", brokenHealth{pending: true}, "ok", len(migrations) - 1, []int{latest}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+
+			repositories := s.store.Repositories()
+			tt.health.HealthRepository = repositories.Health
+			repositories.Health = tt.health
+
+			// Probes report the real status even when legacy clients are
+			// answered with 200 everywhere else.
+			settings := testSettings()
+			settings.HTTP.LegacyStatus = true
+			s.router = newTestRouter(repositories, settings)
+
+			response := s.do(http.MethodGet, "/readyz", "", nil)
+			if tt.database == "ok" && tt.pending == nil {
+				s.ok(response, nil)
+			} else {
+				s.fails(response, apperr.NotReady)
+			}
+
+			var readiness models.Readiness
+			err := json.Unmarshal(response.Payload, &readiness)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if readiness.Database != tt.database || readiness.Migrations.Applied != tt.applied || len(readiness.Migrations.Pending) != len(tt.pending) {
+				t.Errorf("unexpected readiness %+v", readiness)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Package i18n localizes API messages into Uzbek, Russian and English.
+// Messages are keyed by their English text, so code keeps writing English
+// and the response is translated on the way out.
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	Uzbek   = "uz"
+	Russian = "ru"
+	English = "en"
+
+	// Default is used when neither the user nor the client picked a
+	// supported language.
+	Default = English
+)
+
+// Languages lists the supported languages.
+var Languages = []string{Uzbek, Russian, English}
+
+// Supported reports whether language is one of Languages.
+func Supported(language string) bool {
+	for _, supported := range Languages {
+		if supported == language {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the supported language the client prefers most according
+// to an Accept-Language header, or Default.
+func Match(acceptLanguage string) string {
+	type candidate struct {
+		language string
+		weight   float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+
+		language := strings.ToLower(strings.TrimSpace(fields[0]))
+		if i := strings.IndexAny(language, "-_"); i >= 0 {
+			language = language[:i]
+		}
+		if !Supported(language) {
+			continue
+		}
+
+		weight := 1.0
+		for _, parameter := range fields[1:] {
+			parameter = strings.TrimSpace(parameter)
+			if strings.HasPrefix(parameter, "q=") {
+				weight, _ = strconv.ParseFloat(parameter[2:], 64)
+			}
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		candidates = append(candidates, candidate{language, weight})
+	}
+
+	if len(candidates) == 0 {
+		return Default
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].weight > candidates[j].weight
+	})
+
+	return candidates[0].language
+}
+
+// T translates an English message into language. Messages without a
+// translation are returned unchanged.
+func T(language, message string) string {
+	if translated, ok := messages[language][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Label picks the label for language from labels, falling back to the
+// English label and then to fallback.
+func Label(language string, labels map[string]string, fallback string) string {
+	if label := labels[language]; label != "" {
+		return label
+	}
+	if label := labels[English]; label != "" {
+		return label
+	}
+	return fallback
+}
+
+var messages = map[string]map[string]string{
+	Uzbek: {
+		"internal server error":           "serverda ichki xatolik yuz berdi",
+		"request body is not valid JSON":  "so'rov tanasi yaroqli JSON emas",
+		"query string is not valid":       "so'rov satri parametrlari noto'g'ri",
+		"cursor is not valid":             "sahifa kursori noto'g'ri",
+		"request validation failed":       "so'rov tekshiruvdan o'tmadi",
+		"missing or invalid bearer token": "avtorizatsiya tokeni yo'q yoki noto'g'ri",
+		"invalid email or password":       "email yoki parol noto'g'ri",
+		"no access to this page":          "ushbu sahifaga kirish huquqi yo'q",
+		"letter not found":                "xat topilmadi",
+		"employee not found":              "xodim topilmadi",
+		"department not found":            "bo'lim topilmadi",
+		"agreement not found":             "kelishuv topilmadi",
+		"department is archived":          "bo'lim arxivlangan",
+		"department still has employees or active sub-departments, merge it instead": "bo'limda hali xodimlar yoki faol quyi bo'limlar bor, uning o'rniga birlashtiring",
+		"department cannot be placed inside its own subtree":                         "bo'limni o'zining quyi bo'limlari ichiga joylashtirib bo'lmaydi",
+		"employee does not belong to the department":                                 "xodim ushbu bo'limga tegishli emas",
+		"unsupported format":                                                         "qo'llab-quvvatlanmaydigan format",
+		"unsupported format, expected json, dot or csv":                              "qo'llab-quvvatlanmaydigan format, json, dot yoki csv kutilgan",
+		"import file could not be read":                                              "import faylini o'qib bo'lmadi",
+		"import has validation errors":                                               "importda tekshiruv xatolari bor",
+		"nothing to import":                                                          "import qilinadigan ma'lumot yo'q",
+		"service is not ready":                                                       "xizmat hali tayyor emas",
+		"version or If-Match is required":                                            "versiya yoki If-Match talab qilinadi",
+		"version is out of date":                                                     "versiya eskirgan",
+		"idempotency key reused with another request":                                "idempotentlik kaliti boshqa so'rov uchun qayta ishlatilgan",
+		"idempotency key is still in use":                                            "ushbu idempotentlik kalitli so'rov hali bajarilmoqda",
+		"letter is kept in the trash until its retention period ends":                "xat saqlash muddati tugaguncha savatda turadi",
+		"letter was destroyed under a destruction act":                               "xat yo'q qilish dalolatnomasi bo'yicha yo'q qilingan",
+		"letter is not past its retention period or is already in a destruction act": "xatning saqlash muddati tugamagan yoki u allaqachon yo'q qilish dalolatnomasiga kiritilgan",
+		"document type not found":                                                    "hujjat turi topilmadi",
+		"retention rule not found":                                                   "saqlash qoidasi topilmadi",
+		"destruction act not found":                                                  "yo'q qilish dalolatnomasi topilmadi",
+		"destruction act is not in the status this step requires":                    "yo'q qilish dalolatnomasi bu amal uchun zarur holatda emas",
+		"case not found":                                                             "ish topilmadi",
+		"case is closed":                                                             "ish yopilgan",
+		"department already has a case with this index in this year":                 "bo'limda bu yil uchun shu indeksli ish allaqachon mavjud",
+		"letter was registered in another year than the case":                        "xat ishdan boshqa yilda ro'yxatga olingan",
+		"agreement is already decided":                                               "kelishuv bo'yicha qaror allaqachon qabul qilingan",
+		"letter is in a destruction act":                                             "xat yo'q qilish dalolatnomasiga kiritilgan",
+	},
+	Russian: {
+		"internal server error":           "внутренняя ошибка сервера",
+		"request body is not valid JSON":  "тело запроса не является корректным JSON",
+		"query string is not valid":       "некорректные параметры строки запроса",
+		"cursor is not valid":             "некорректный курсор страницы",
+		"request validation failed":       "запрос не прошёл проверку",
+		"missing or invalid bearer token": "токен авторизации отсутствует или недействителен",
+		"invalid email or password":       "неверный email или пароль",
+		"no access to this page":          "нет доступа к этой странице",
+		"letter not found":                "письмо не найдено",
+		"employee not found":              "сотрудник не найден",
+		"department not found":            "отдел не найден",
+		"agreement not found":             "согласование не найдено",
+		"department is archived":          "отдел находится в архиве",
+		"department still has employees or active sub-departments, merge it instead": "в отделе ещё есть сотрудники или активные подотделы, объедините его вместо архивации",
+		"department cannot be placed inside its own subtree":                         "отдел нельзя поместить внутрь собственного поддерева",
+		"employee does not belong to the department":                                 "сотрудник не относится к этому отделу",
+		"unsupported format":                                                         "неподдерживаемый формат",
+		"unsupported format, expected json, dot or csv":                              "неподдерживаемый формат, ожидается json, dot или csv",
+		"import file could not be read":                                              "не удалось прочитать файл импорта",
+		"import has validation errors":                                               "импорт содержит ошибки проверки",
+		"nothing to import":                                                          "нет данных для импорта",
+		"service is not ready":                                                       "сервис ещё не готов",
+		"version or If-Match is required":                                            "требуется версия или заголовок If-Match",
+		"version is out of date":                                                     "версия устарела",
+		"idempotency key reused with another request":                                "ключ идемпотентности уже использован для другого запроса",
+		"idempotency key is still in use":                                            "запрос с этим ключом идемпотентности ещё выполняется",
+		"letter is kept in the trash until its retention period ends":                "письмо хранится в корзине до окончания срока хранения",
+		"letter was destroyed under a destruction act":                               "письмо уничтожено по акту об уничтожении",
+		"letter is not past its retention period or is already in a destruction act": "срок хранения письма не истёк или оно уже включено в акт об уничтожении",
+		"document type not found":                                                    "тип документа не найден",
+		"retention rule not found":                                                   "правило хранения не найдено",
+		"destruction act not found":                                                  "акт об уничтожении не найден",
+		"destruction act is not in the status this step requires":                    "акт об уничтожении не находится в статусе, нужном для этого действия",
+		"case not found":                                                             "дело не найдено",
+		"case is closed":                                                             "дело закрыто",
+		"department already has a case with this index in this year":                 "у подразделения уже есть дело с этим индексом в этом году",
+		"letter was registered in another year than the case":                        "письмо зарегистрировано в другом году, чем заведено дело",
+		"agreement is already decided":                                               "решение по согласованию уже принято",
+		"letter is in a destruction act":                                             "письмо включено в акт об уничтожении",
+	},
+}
@@ -0,0 +1,119 @@
+package i18n
+
+import (
+	"fmt"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ru"
+	"github.com/go-playground/locales/uz"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	rutranslations "github.com/go-playground/validator/v10/translations/ru"
+	"reflect"
+)
+
+// Translations translates validation errors of one validator.
+type Translations struct {
+	translators map[string]ut.Translator
+}
+
+// NewTranslations registers translations for every supported language on
+// validate. It panics if the built-in translations conflict, which is a
+// programming error.
+func NewTranslations(validate *validator.Validate) *Translations {
+	universal := ut.New(en.New(), en.New(), ru.New(), uz.New())
+
+	t := &Translations{translators: make(map[string]ut.Translator, len(Languages))}
+	for _, language := range Languages {
+		t.translators[language], _ = universal.GetTranslator(language)
+	}
+
+	err := entranslations.RegisterDefaultTranslations(validate, t.translators[English])
+	if err == nil {
+		err = rutranslations.RegisterDefaultTranslations(validate, t.translators[Russian])
+	}
+	if err == nil {
+		err = registerUzbek(validate, t.translators[Uzbek])
+	}
+	if err != nil {
+		panic(fmt.Sprintf("i18n: registering validator translations: %v", err))
+	}
+
+	return t
+}
+
+// Field translates a validation error into language.
+func (t *Translations) Field(language string, field validator.FieldError) string {
+	return field.Translate(t.translator(language))
+}
+
+// Rule translates a failed rule reported outside the validator, such as a
+// required id checked by hand. It returns "" when the rule has no plain
+// translation.
+func (t *Translations) Rule(language, field, rule, param string) string {
+	translated, err := t.translator(language).T(rule, field, param)
+	if err != nil {
+		return ""
+	}
+	return translated
+}
+
+func (t *Translations) translator(language string) ut.Translator {
+	if translator, ok := t.translators[language]; ok {
+		return translator
+	}
+	return t.translators[Default]
+}
+
+// registerUzbek covers the rules used by the models; the validator ships
+// no Uzbek translations.
+func registerUzbek(validate *validator.Validate, translator ut.Translator) error {
+	plain := map[string]string{
+		"required": "{0} majburiy maydon",
+		"number":   "{0} son bo'lishi kerak",
+		"email":    "{0} to'g'ri email manzil bo'lishi kerak",
+		"nefield":  "{0} {1} ga teng bo'lmasligi kerak",
+		"oneof":    "{0} quyidagilardan biri bo'lishi kerak: [{1}]",
+	}
+
+	for tag, text := range plain {
+		tag, text := tag, text
+		err := validate.RegisterTranslation(tag, translator, func(translator ut.Translator) error {
+			return translator.Add(tag, text, false)
+		}, func(translator ut.Translator, field validator.FieldError) string {
+			translated, _ := translator.T(tag, field.Field(), field.Param())
+			return translated
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	bounds := map[string][2]string{
+		"min": {"{0} kamida {1} ta belgidan iborat bo'lishi kerak", "{0} {1} dan kichik bo'lmasligi kerak"},
+		"max": {"{0} ko'pi bilan {1} ta belgidan iborat bo'lishi kerak", "{0} {1} dan katta bo'lmasligi kerak"},
+	}
+
+	for tag, texts := range bounds {
+		tag, texts := tag, texts
+		err := validate.RegisterTranslation(tag, translator, func(translator ut.Translator) error {
+			err := translator.Add(tag+"-string", texts[0], false)
+			if err != nil {
+				return err
+			}
+			return translator.Add(tag+"-number", texts[1], false)
+		}, func(translator ut.Translator, field validator.FieldError) string {
+			key := tag + "-number"
+			if field.Kind() == reflect.String {
+				key = tag + "-string"
+			}
+			translated, _ := translator.T(key, field.Field(), field.Param())
+			return translated
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"sed/apperr"
+	"sed/handlers"
+	"sed/metrics"
+	"sed/models"
+	"sed/repository/memory"
+	"testing"
+	"time"
+)
+
+// idempotent sends body as JSON with token and key as the Idempotency-Key.
+func (s *testServer) idempotent(method, path, token, key string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(method, path, bytes.NewReader(data))
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set(handlers.IdempotencyKeyHeader, key)
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestIdempotentLetter(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	letter := models.Letter{
+		Name:           "Budget proposal",
+		Sender:         "Ministry of Finance",
+		DocumentTypeId: s.documentTypeId(token),
+		Content:        letterContent,
+	}
+
+	first := s.idempotent(http.MethodPost, "/letter", token, "letter-1", letter)
+	retry := s.idempotent(http.MethodPost, "/letter", token, "letter-1", letter)
+	if first.Code != http.StatusOK || retry.Code != http.StatusOK {
+		t.Fatalf("status %d, retry %d: %s", first.Code, retry.Code, retry.Body.String())
+	}
+	if retry.Body.String() != first.Body.String() || retry.Header().Get(handlers.ReplayedHeader) != "true" {
+		t.Errorf("retry was not replayed: %s", retry.Body.String())
+	}
+	if first.Header().Get(handlers.ReplayedHeader) != "" {
+		t.Error("first response marked as replayed")
+	}
+
+	var letters []models.Letter
+	s.ok(s.do(http.MethodPost, "/letters", token, models.LetterFilter{RowsLimit: 10}), &letters)
+	if len(letters) != 1 {
+		t.Errorf("%d letters after a retry", len(letters))
+	}
+
+	letter.Name = "Budget report"
+	s.fails(s.request(http.MethodPost, "/letter", map[string]string{"Authorization": "Bearer " + token, handlers.IdempotencyKeyHeader: "letter-1"}, letter), apperr.IdempotencyReused)
+
+	// Keys belong to the employee sending them.
+	s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+	if other := s.idempotent(http.MethodPost, "/letter", s.login("clerk@example.com"), "letter-1", letter); other.Body.String() == first.Body.String() {
+		t.Error("response replayed to another employee")
+	}
+
+	// v2 replays the status and Location too, and of the letter only its
+	// id: the content is not stored.
+	created := s.idempotent(http.MethodPost, "/api/v2/letters", token, "letter-2", letter)
+	replayed := s.idempotent(http.MethodPost, "/api/v2/letters", token, "letter-2", letter)
+	if replayed.Code != http.StatusCreated || replayed.Header().Get("Location") != created.Header().Get("Location") {
+		t.Errorf("replayed %d to %q", replayed.Code, replayed.Header().Get("Location"))
+	}
+	var stored struct {
+		Payload models.Letter `json:"payload"`
+	}
+	if err := json.Unmarshal(replayed.Body.Bytes(), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Payload.Id == 0 || stored.Payload.Name != "" || stored.Payload.Content != "" {
+		t.Errorf("replayed payload %+v", stored.Payload)
+	}
+}
+
+func TestIdempotentAgreement(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	s.ok(s.do(http.MethodPut, "/department/heads", token,
+		models.Department{Id: finance, HeadId: s.addEmployee("head@example.com", "DEP_HEAD", finance)}), nil)
+	agreement := models.Agreement{LetterId: s.letter(token, "Budget proposal"), DepartmentId: finance}
+
+	for i := 0; i < 2; i++ {
+		if recorder := s.idempotent(http.MethodPost, "/letters/agreement", token, "agreement-1", agreement); recorder.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", recorder.Code, recorder.Body.String())
+		}
+	}
+
+	var agreements []models.Agreement
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements", s.login("head@example.com"), nil), &agreements)
+	if len(agreements) != 1 {
+		t.Errorf("%d agreements after a retry", len(agreements))
+	}
+}
+
+func TestIdempotencyKeyLifetime(t *testing.T) {
+	store := memory.New()
+	settings := testSettings()
+	settings.HTTP.IdempotencyTTL = time.Millisecond
+	s := &testServer{t: t, store: store, router: newTestRouter(store.Repositories(), settings)}
+	token := s.admin()
+	letter := models.Letter{
+		Name:           "Budget proposal",
+		Sender:         "Ministry of Finance",
+		DocumentTypeId: s.documentTypeId(token),
+		Content:        letterContent,
+	}
+
+	first := s.idempotent(http.MethodPost, "/letter", token, "letter-1", letter)
+	time.Sleep(2 * time.Millisecond)
+	if again := s.idempotent(http.MethodPost, "/letter", token, "letter-1", letter); again.Body.String() == first.Body.String() {
+		t.Error("expired key replayed")
+	}
+
+	// A request still running holds its key.
+	clerk := s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+	data, _ := json.Marshal(letter)
+	hash := sha256.Sum256(append([]byte("POST /letter\n"), data...))
+	_, _, err := store.Repositories().Idempotency.Begin(context.Background(), models.IdempotentRequest{
+		EmployeeId:  clerk,
+		Key:         "running",
+		RequestHash: hex.EncodeToString(hash[:]),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.fails(s.request(http.MethodPost, "/letter", map[string]string{"Authorization": "Bearer " + s.login("clerk@example.com"), handlers.IdempotencyKeyHeader: "running"}, letter), apperr.IdempotencyInUse)
+}
+
+func TestIdempotentPanic(t *testing.T) {
+	store := memory.New()
+	settings := testSettings()
+	m := metrics.New(store.Repositories().Stats)
+	h := handlers.New(store.Repositories(), handlers.NewValidator(), settings, m)
+	router := newRouter(h, m, settings)
+	s := &testServer{t: t, store: store, router: router}
+	token := s.admin()
+
+	calls := 0
+	router.POST("/api/v2/flaky", h.Authorization, h.Idempotent, func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		c.JSON(http.StatusOK, models.Response{Code: http.StatusOK})
+	})
+
+	// A panic leaves the key free for the retry.
+	if first := s.idempotent(http.MethodPost, "/api/v2/flaky?page=1", token, "flaky-1", nil); first.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d: %s", first.Code, first.Body.String())
+	}
+	if retry := s.idempotent(http.MethodPost, "/api/v2/flaky?page=1", token, "flaky-1", nil); retry.Code != http.StatusOK || calls != 2 {
+		t.Fatalf("retry status %d after %d calls: %s", retry.Code, calls, retry.Body.String())
+	}
+
+	// The query is part of the request.
+	s.fails(s.request(http.MethodPost, "/api/v2/flaky?page=2", map[string]string{"Authorization": "Bearer " + token, handlers.IdempotencyKeyHeader: "flaky-1"}, nil), apperr.IdempotencyReused)
+}
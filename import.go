@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sed/db"
+	"sed/importer"
+	"sed/repository/postgres"
+)
+
+// runImport implements "sed import", the command line counterpart of POST /import.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	departments := flags.String("departments", "", "CSV or XLSX file with departments")
+	employees := flags.String("employees", "", "CSV or XLSX file with employees")
+	dryRun := flags.Bool("dry-run", false, "validate the files without importing")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *departments == "" && *employees == "" {
+		fmt.Fprintln(os.Stderr, "nothing to import, pass -departments and/or -employees")
+		return 2
+	}
+
+	var batch importer.Batch
+
+	if *departments != "" {
+		file, err := os.Open(*departments)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		batch.Departments, err = importer.ReadDepartments(file, *departments)
+		_ = file.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if *employees != "" {
+		file, err := os.Open(*employees)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		batch.Employees, err = importer.ReadEmployees(file, *employees)
+		_ = file.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	ctx := context.Background()
+
+	settings, err := loadDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	pool, err := db.Connect(ctx, settings)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer pool.Close()
+
+	report, err := importer.Run(ctx, postgres.New(pool).Imports, batch, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+
+	if len(report.Errors) > 0 {
+		return 1
+	}
+
+	return 0
+}
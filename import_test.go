@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sed/apperr"
+	"strings"
+	"testing"
+)
+
+// upload posts content as the multipart file field named filename with
+// token.
+func (s *testServer) upload(path, token, field, filename, content string) (envelope, string) {
+	s.t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(field, filename)
+	if err == nil {
+		_, err = part.Write([]byte(content))
+	}
+	if err == nil {
+		err = form.Close()
+	}
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, path, &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+
+	var response envelope
+	err = json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		s.t.Fatalf("POST %s: %v: %s", path, err, recorder.Body.String())
+	}
+
+	return response, recorder.Body.String()
+}
+
+func TestImportUnreadableFile(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	// The parser error quotes the file, so it is logged and the client only
+	// learns which file failed.
+	response, body := s.upload("/api/v2/imports", token, "departments", "departments.csv", "name,parent\n\"Secret \"budget\" plans,\n")
+	s.fails(response, apperr.ImportFileInvalid)
+	if len(response.Details) != 1 || response.Details[0].Field != "departments" || response.Details[0].Rule != "file" {
+		t.Errorf("details %+v", response.Details)
+	}
+	if strings.Contains(body, "Secret") || strings.Contains(body, "quote") {
+		t.Errorf("parser error sent to the client: %s", body)
+	}
+
+	response, _ = s.upload("/import", token, "employees", "employees.txt", "email\n")
+	s.fails(response, apperr.UnsupportedFormat)
+	if len(response.Details) != 1 || response.Details[0].Field != "employees" || response.Details[0].Rule != "format" {
+		t.Errorf("details %+v", response.Details)
+	}
+}
@@ -0,0 +1,366 @@
+// Package importer loads departments and employees in bulk from CSV or XLSX files.
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"net/mail"
+	"path/filepath"
+	"sed/models"
+	"strings"
+)
+
+const (
+	SheetDepartments = "departments"
+	SheetEmployees   = "employees"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported file format, expected .csv or .xlsx")
+
+type Department struct {
+	Row            int
+	Name           string
+	InternalNumber string
+	Phone          string
+	Parent         string
+}
+
+type Employee struct {
+	Row        int
+	FullName   string
+	Email      string
+	Password   string
+	Role       string
+	Department string
+	Position   string
+}
+
+// Store is the storage the importer validates against and writes to.
+type Store interface {
+	// Departments maps lower-cased names of active departments to their ids.
+	Departments(ctx context.Context) (map[string]int, error)
+	// Roles maps upper-cased role names to their ids.
+	Roles(ctx context.Context) (map[string]int, error)
+	// TakenEmails reports which of the lower-cased emails already belong to an employee.
+	TakenEmails(ctx context.Context, emails []string) (map[string]bool, error)
+	// Import stores the batch atomically, resolving department and role
+	// names. Parents precede their children and passwords are already hashed.
+	Import(ctx context.Context, batch Batch) error
+}
+
+// Batch is the parsed content of one import, applied as a whole or not at all.
+type Batch struct {
+	Departments []Department
+	Employees   []Employee
+}
+
+// ReadDepartments parses a departments sheet with the columns
+// name, internal_number, phone and parent.
+func ReadDepartments(r io.Reader, filename string) ([]Department, error) {
+	records, err := readRecords(r, filename, SheetDepartments)
+	if err != nil {
+		return nil, err
+	}
+
+	var departments []Department
+	for _, record := range records {
+		departments = append(departments, Department{
+			Row:            record.row,
+			Name:           record.get("name"),
+			InternalNumber: record.get("internal_number"),
+			Phone:          record.get("phone"),
+			Parent:         record.get("parent"),
+		})
+	}
+
+	return departments, nil
+}
+
+// ReadEmployees parses an employees sheet with the columns
+// full_name, email, password, role, department and position.
+func ReadEmployees(r io.Reader, filename string) ([]Employee, error) {
+	records, err := readRecords(r, filename, SheetEmployees)
+	if err != nil {
+		return nil, err
+	}
+
+	var employees []Employee
+	for _, record := range records {
+		employees = append(employees, Employee{
+			Row:        record.row,
+			FullName:   record.get("full_name"),
+			Email:      strings.ToLower(record.get("email")),
+			Password:   record.get("password"),
+			Role:       strings.ToUpper(record.get("role")),
+			Department: record.get("department"),
+			Position:   record.get("position"),
+		})
+	}
+
+	return employees, nil
+}
+
+// Validate checks the batch against itself and the current database and
+// returns one entry per problem found. It never modifies data.
+func Validate(ctx context.Context, store Store, batch Batch) ([]models.ImportError, error) {
+	var problems []models.ImportError
+
+	departments, err := store.Departments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := store.Roles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := make(map[string]bool, len(batch.Departments))
+	for _, department := range batch.Departments {
+		key := strings.ToLower(department.Name)
+		switch {
+		case department.Name == "":
+			problems = append(problems, problem(SheetDepartments, department.Row, "name", "name is required"))
+		case departments[key] != 0 || imported[key]:
+			problems = append(problems, problem(SheetDepartments, department.Row, "name", fmt.Sprintf("duplicate department %q", department.Name)))
+		}
+		imported[key] = true
+	}
+
+	for _, department := range batch.Departments {
+		parent := strings.ToLower(department.Parent)
+		if parent == "" {
+			continue
+		}
+
+		if departments[parent] == 0 && !imported[parent] {
+			problems = append(problems, problem(SheetDepartments, department.Row, "parent", fmt.Sprintf("missing department %q", department.Parent)))
+		}
+	}
+
+	if cycle := parentCycle(batch.Departments); cycle != nil {
+		problems = append(problems, problem(SheetDepartments, cycle.Row, "parent", fmt.Sprintf("department %q is its own ancestor", cycle.Name)))
+	}
+
+	emails := make([]string, 0, len(batch.Employees))
+	for _, employee := range batch.Employees {
+		emails = append(emails, employee.Email)
+	}
+
+	taken, err := store.TakenEmails(ctx, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(batch.Employees))
+	for _, employee := range batch.Employees {
+		if employee.FullName == "" {
+			problems = append(problems, problem(SheetEmployees, employee.Row, "full_name", "full name is required"))
+		}
+
+		_, err = mail.ParseAddress(employee.Email)
+		switch {
+		case employee.Email == "":
+			problems = append(problems, problem(SheetEmployees, employee.Row, "email", "email is required"))
+		case err != nil:
+			problems = append(problems, problem(SheetEmployees, employee.Row, "email", fmt.Sprintf("invalid email %q", employee.Email)))
+		case taken[employee.Email] || seen[employee.Email]:
+			problems = append(problems, problem(SheetEmployees, employee.Row, "email", fmt.Sprintf("duplicate email %q", employee.Email)))
+		}
+		seen[employee.Email] = true
+
+		if roles[employee.Role] == 0 {
+			problems = append(problems, problem(SheetEmployees, employee.Row, "role", fmt.Sprintf("unknown role %q", employee.Role)))
+		}
+
+		department := strings.ToLower(employee.Department)
+		switch {
+		case department == "":
+			problems = append(problems, problem(SheetEmployees, employee.Row, "department", "department is required"))
+		case departments[department] == 0 && !imported[department]:
+			problems = append(problems, problem(SheetEmployees, employee.Row, "department", fmt.Sprintf("missing department %q", employee.Department)))
+		}
+	}
+
+	return problems, nil
+}
+
+// Run validates the batch and, unless dryRun is set or validation fails,
+// imports it in a single transaction.
+func Run(ctx context.Context, store Store, batch Batch, dryRun bool) (report models.ImportReport, err error) {
+	report = models.ImportReport{
+		DryRun:      dryRun,
+		Departments: len(batch.Departments),
+		Employees:   len(batch.Employees),
+	}
+
+	report.Errors, err = Validate(ctx, store, batch)
+	if err != nil || dryRun || len(report.Errors) > 0 {
+		return report, err
+	}
+
+	return report, apply(ctx, store, batch)
+}
+
+func apply(ctx context.Context, store Store, batch Batch) error {
+	departments, err := store.Departments(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Parents may appear after their children in the file, so order the
+	// departments in passes until every parent precedes its children.
+	var (
+		ordered  = make([]Department, 0, len(batch.Departments))
+		resolved = make(map[string]bool, len(batch.Departments))
+		pending  = batch.Departments
+	)
+	for len(pending) > 0 {
+		var next []Department
+		for _, department := range pending {
+			parent := strings.ToLower(department.Parent)
+			if parent != "" && departments[parent] == 0 && !resolved[parent] {
+				next = append(next, department)
+				continue
+			}
+
+			ordered = append(ordered, department)
+			resolved[strings.ToLower(department.Name)] = true
+		}
+
+		if len(next) == len(pending) {
+			return fmt.Errorf("%s row %d: unresolved parent %q", SheetDepartments, next[0].Row, next[0].Parent)
+		}
+		pending = next
+	}
+
+	employees := make([]Employee, 0, len(batch.Employees))
+	for _, employee := range batch.Employees {
+		if employee.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(employee.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return fmt.Errorf("%s row %d: %w", SheetEmployees, employee.Row, err)
+			}
+			employee.Password = string(hash)
+		}
+
+		employees = append(employees, employee)
+	}
+
+	return store.Import(ctx, Batch{Departments: ordered, Employees: employees})
+}
+
+// parentCycle returns a department whose parent chain within the batch loops back to itself.
+func parentCycle(departments []Department) *Department {
+	parents := make(map[string]string, len(departments))
+	for _, department := range departments {
+		parents[strings.ToLower(department.Name)] = strings.ToLower(department.Parent)
+	}
+
+	for i, department := range departments {
+		name := strings.ToLower(department.Name)
+		current := parents[name]
+		for steps := 0; current != "" && steps <= len(departments); steps++ {
+			if current == name {
+				return &departments[i]
+			}
+			current = parents[current]
+		}
+	}
+
+	return nil
+}
+
+func problem(sheet string, row int, field, message string) models.ImportError {
+	return models.ImportError{
+		Sheet:   sheet,
+		Row:     row,
+		Field:   field,
+		Message: message,
+	}
+}
+
+type record struct {
+	row    int
+	header map[string]int
+	values []string
+}
+
+func (r record) get(column string) string {
+	i, ok := r.header[column]
+	if !ok || i >= len(r.values) {
+		return ""
+	}
+	return strings.TrimSpace(r.values[i])
+}
+
+// readRecords returns the data rows of a CSV file or of an XLSX sheet named
+// after sheet, falling back to the first sheet. Rows are numbered as in the
+// file, so the header is row 1.
+func readRecords(r io.Reader, filename, sheet string) ([]record, error) {
+	var (
+		rows [][]string
+		err  error
+	)
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		rows, err = reader.ReadAll()
+	case ".xlsx":
+		rows, err = readSheet(r, sheet)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	header := make(map[string]int, len(rows[0]))
+	for i, column := range rows[0] {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		header[strings.ReplaceAll(column, " ", "_")] = i
+	}
+
+	var records []record
+	for i, values := range rows[1:] {
+		if strings.TrimSpace(strings.Join(values, "")) == "" {
+			continue
+		}
+		records = append(records, record{row: i + 2, header: header, values: values})
+	}
+
+	return records, nil
+}
+
+func readSheet(r io.Reader, sheet string) ([][]string, error) {
+	file, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sheets := file.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, nil
+	}
+
+	name := sheets[0]
+	for _, s := range sheets {
+		if strings.EqualFold(s, sheet) {
+			name = s
+			break
+		}
+	}
+
+	return file.GetRows(name)
+}
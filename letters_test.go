@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"sed/repository"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const letterContent = "Please review the attached budget proposal."
+
+func TestLetterCrud(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	documentTypeId := s.documentTypeId(token)
+
+	id := s.letter(token, "Budget proposal")
+	if id == 0 {
+		t.Fatal("letter id is 0")
+	}
+
+	var letter models.Letter
+	s.ok(s.do(http.MethodPost, "/letter/"+strconv.Itoa(id), token, nil), &letter)
+	if letter.Id != id || letter.Name != "Budget proposal" || letter.Content != letterContent {
+		t.Errorf("unexpected letter %+v", letter)
+	}
+	if letter.RegistrationNumber == "" || letter.EntryDate.IsZero() || letter.DocumentType.Type == "" {
+		t.Errorf("letter is missing generated fields %+v", letter)
+	}
+
+	s.ok(s.do(http.MethodPut, "/letter", token, models.Letter{
+		Id:             id,
+		Name:           "Revised budget proposal",
+		Sender:         "Ministry of Economy",
+		DocumentTypeId: documentTypeId,
+		Content:        letterContent + " Revised.",
+		Version:        letter.Version,
+	}), nil)
+
+	s.ok(s.do(http.MethodPost, "/letter/"+strconv.Itoa(id), token, nil), &letter)
+	if letter.Name != "Revised budget proposal" || letter.Sender != "Ministry of Economy" {
+		t.Errorf("letter was not updated %+v", letter)
+	}
+
+	s.fails(s.do(http.MethodPost, "/letter/"+strconv.Itoa(id+100), token, nil), apperr.LetterNotFound)
+
+	s.fails(s.do(http.MethodPut, "/letter", token, models.Letter{
+		Id:             id + 100,
+		Name:           "Unknown",
+		Sender:         "Nobody",
+		DocumentTypeId: documentTypeId,
+		Content:        letterContent,
+		Version:        1,
+	}), apperr.LetterNotFound)
+}
+
+func TestLetterList(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	for _, name := range []string{"Budget proposal", "Budget report", "Staff order"} {
+		s.letter(token, name)
+	}
+
+	tests := []struct {
+		name   string
+		filter models.LetterFilter
+		want   []string
+	}{
+		{"all", models.LetterFilter{RowsLimit: 10}, []string{"Staff order", "Budget report", "Budget proposal"}},
+		{"limit", models.LetterFilter{RowsLimit: 1}, []string{"Staff order"}},
+		{"offset", models.LetterFilter{RowsLimit: 10, RowsOffset: 2}, []string{"Budget proposal"}},
+		{"name", models.LetterFilter{RowsLimit: 10, Name: "budget"}, []string{"Budget report", "Budget proposal"}},
+		{"sender", models.LetterFilter{RowsLimit: 10, Sender: "Ministry"}, []string{"Staff order", "Budget report", "Budget proposal"}},
+		{"no match", models.LetterFilter{RowsLimit: 10, Sender: "Embassy"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+
+			var letters []models.Letter
+			s.ok(s.do(http.MethodPost, "/letters", token, tt.filter), &letters)
+
+			var names []string
+			for _, letter := range letters {
+				if letter.Content != "" {
+					t.Errorf("list returned content for %q", letter.Name)
+				}
+				names = append(names, letter.Name)
+			}
+
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestLetterValidation(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	documentTypeId := s.documentTypeId(token)
+
+	valid := models.Letter{
+		Name:           "Budget proposal",
+		Sender:         "Ministry of Finance",
+		DocumentTypeId: documentTypeId,
+		Content:        letterContent,
+	}
+
+	tests := []struct {
+		name   string
+		change func(letter *models.Letter)
+		field  string
+		rule   string
+	}{
+		{"missing name", func(letter *models.Letter) { letter.Name = "" }, "name", "required"},
+		{"short name", func(letter *models.Letter) { letter.Name = "B" }, "name", "min"},
+		{"missing sender", func(letter *models.Letter) { letter.Sender = "" }, "sender", "required"},
+		{"short sender", func(letter *models.Letter) { letter.Sender = "M" }, "sender", "min"},
+		{"missing document type", func(letter *models.Letter) { letter.DocumentTypeId = 0 }, "document_type_id", "required"},
+		{"missing content", func(letter *models.Letter) { letter.Content = "" }, "content", "required"},
+		{"short content", func(letter *models.Letter) { letter.Content = "Too short" }, "content", "min"},
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		for _, tt := range tests {
+			t.Run(method+" "+tt.name, func(t *testing.T) {
+				s := s.with(t)
+
+				letter := valid
+				letter.Id = 1
+				tt.change(&letter)
+
+				s.invalid(s.do(method, "/letter", token, letter), tt.field, tt.rule)
+			})
+		}
+	}
+
+	t.Run("PUT missing id", func(t *testing.T) {
+		s := s.with(t)
+
+		s.invalid(s.do(http.MethodPut, "/letter", token, valid), "id", "required")
+	})
+
+	t.Run("list without rows_limit", func(t *testing.T) {
+		s := s.with(t)
+
+		s.invalid(s.do(http.MethodPost, "/letters", token, models.LetterFilter{}), "rows_limit", "required")
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		s := s.with(t)
+
+		s.fails(s.do(http.MethodPost, "/letter", token, "not an object"), apperr.MalformedRequest)
+	})
+}
+
+func TestDescribeLetter(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	budget := s.department(token, models.Department{Name: "Budget", ParentId: finance})
+	legal := s.department(token, models.Department{Name: "Legal"})
+	executive := s.addEmployee("budget@example.com", "EMPLOYEE", budget)
+
+	proposal := s.letter(token, "Budget proposal")
+	s.letter(token, "Staff order")
+
+	s.ok(s.do(http.MethodPost, "/letters/describe", token, models.DescribedLetter{
+		LetterId:          proposal,
+		DepartmentId:      budget,
+		ExecutiveEmployee: executive,
+	}), nil)
+
+	tests := []struct {
+		name   string
+		filter models.LetterFilter
+		want   int
+	}{
+		{"described department", models.LetterFilter{RowsLimit: 10, DepartmentId: budget}, 1},
+		{"parent without subdepartments", models.LetterFilter{RowsLimit: 10, DepartmentId: finance}, 0},
+		{"parent with subdepartments", models.LetterFilter{RowsLimit: 10, DepartmentId: finance, Subdepartments: true}, 1},
+		{"other department", models.LetterFilter{RowsLimit: 10, DepartmentId: legal, Subdepartments: true}, 0},
+		{"no department", models.LetterFilter{RowsLimit: 10}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+
+			var letters []models.Letter
+			s.ok(s.do(http.MethodPost, "/letters", token, tt.filter), &letters)
+			if len(letters) != tt.want {
+				t.Errorf("got %d letters, want %d", len(letters), tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown department", func(t *testing.T) {
+		s := s.with(t)
+
+		s.fails(s.do(http.MethodPost, "/letters/describe", token, models.DescribedLetter{LetterId: proposal, DepartmentId: legal + 100}), apperr.DepartmentNotFound)
+	})
+
+	t.Run("unknown letter", func(t *testing.T) {
+		s := s.with(t)
+
+		s.fails(s.do(http.MethodPost, "/letters/describe", token, models.DescribedLetter{LetterId: proposal + 100, DepartmentId: legal}), apperr.LetterNotFound)
+	})
+}
+
+// brokenLetters fails every list with an error that must never reach clients.
+type brokenLetters struct {
+	repository.LetterRepository
+}
+
+func (brokenLetters) List(context.Context, models.LetterFilter) ([]models.Letter, models.Page, error) {
+	return nil, models.Page{}, errors.New(`ERROR: relation "letters" does not exist (SQLSTATE 42P01)`)
+}
+
+func TestInternalErrorsAreHidden(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	repositories := s.store.Repositories()
+	repositories.Letters = brokenLetters{repositories.Letters}
+	s.router = newTestRouter(repositories, testSettings())
+
+	response := s.do(http.MethodPost, "/letters", token, models.LetterFilter{RowsLimit: 10})
+	s.fails(response, apperr.Internal)
+
+	if response.CorrelationId == "" || response.CorrelationId != response.RequestId {
+		t.Errorf("internal error has correlation id %q for request %q", response.CorrelationId, response.RequestId)
+	}
+	if strings.Contains(response.Message, "relation") || len(response.Details) != 0 {
+		t.Errorf("internal error leaked %+v", response)
+	}
+}
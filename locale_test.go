@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"strconv"
+	"testing"
+)
+
+func TestLocalizedMessages(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		message        string
+		detail         string
+	}{
+		{"default", "", "request validation failed", "name is a required field"},
+		{"english", "en-US,en;q=0.9", "request validation failed", "name is a required field"},
+		{"russian", "ru-RU,ru;q=0.9,en;q=0.8", "запрос не прошёл проверку", "name обязательное поле"},
+		{"uzbek", "uz", "so'rov tekshiruvdan o'tmadi", "name majburiy maydon"},
+		{"weighted", "en;q=0.5,uz;q=0.8", "so'rov tekshiruvdan o'tmadi", "name majburiy maydon"},
+		{"unsupported", "de-DE", "request validation failed", "name is a required field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+
+			response := s.request(http.MethodPost, "/letter", map[string]string{
+				"Authorization":   "Bearer " + token,
+				"Accept-Language": tt.acceptLanguage,
+			}, models.Letter{
+				Sender:         "Ministry of Finance",
+				DocumentTypeId: s.documentTypeId(token),
+				Content:        letterContent,
+			})
+			s.invalid(response, "name", "required")
+
+			if response.Message != tt.message {
+				t.Errorf("message %q, want %q", response.Message, tt.message)
+			}
+			if response.Details[0].Message != tt.detail {
+				t.Errorf("detail %q, want %q", response.Details[0].Message, tt.detail)
+			}
+		})
+	}
+
+	t.Run("manual rule", func(t *testing.T) {
+		s := s.with(t)
+
+		response := s.request(http.MethodPut, "/department/move", map[string]string{"Authorization": "Bearer " + token, "Accept-Language": "ru"}, models.Department{})
+		s.invalid(response, "id", "required")
+		if response.Details[0].Message != "id обязательное поле" {
+			t.Errorf("unexpected detail %q", response.Details[0].Message)
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		s := s.with(t)
+
+		response := s.request(http.MethodPost, "/letters/types", map[string]string{"Accept-Language": "ru"}, nil)
+		s.fails(response, apperr.Unauthorized)
+		if response.Message != "токен авторизации отсутствует или недействителен" {
+			t.Errorf("unexpected message %q", response.Message)
+		}
+	})
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	id := s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+	clerk := s.login("clerk@example.com")
+
+	s.invalid(s.do(http.MethodPut, "/user", token, models.Employee{Id: id, Language: "de"}), "language", "oneof")
+	s.ok(s.do(http.MethodPut, "/user", token, models.Employee{Id: id, RoleId: s.store.RoleId("EMPLOYEE"), Language: "uz"}), nil)
+
+	var profile models.Employee
+	s.ok(s.do(http.MethodPost, "/users/"+strconv.Itoa(id), clerk, nil), &profile)
+	if profile.Language != "uz" {
+		t.Errorf("language %q was not stored", profile.Language)
+	}
+
+	response := s.request(http.MethodPost, "/letter/0", map[string]string{"Authorization": "Bearer " + clerk, "Accept-Language": "ru"}, nil)
+	s.fails(response, apperr.LetterNotFound)
+	if response.Message != "xat topilmadi" {
+		t.Errorf("preferred language was not used: %q", response.Message)
+	}
+
+	var documentTypes []models.DocumentType
+	s.ok(s.do(http.MethodPost, "/letters/types", clerk, nil), &documentTypes)
+	if documentTypes[0].Type != "Incoming letter" || documentTypes[0].Label != "Kiruvchi xat" || documentTypes[0].Labels["ru"] != "Входящее письмо" {
+		t.Errorf("unexpected document type %+v", documentTypes[0])
+	}
+
+	var roleGroups []models.RoleGroup
+	s.ok(s.request(http.MethodPost, "/roles", map[string]string{"Authorization": "Bearer " + token, "Accept-Language": "ru"}, nil), &roleGroups)
+	if roleGroups[0].Role != "ADMIN" || roleGroups[0].Label != "Администратор" {
+		t.Errorf("unexpected role group %+v", roleGroups[0])
+	}
+
+	letter := s.letter(token, "Budget proposal")
+
+	var letters []models.Letter
+	s.ok(s.do(http.MethodPost, "/letters", clerk, models.LetterFilter{RowsLimit: 10}), &letters)
+	if len(letters) != 1 || letters[0].Id != letter || letters[0].DocumentType.Label != "Kiruvchi xat" || letters[0].DocumentType.Labels != nil {
+		t.Errorf("unexpected letters %+v", letters)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	zlog "github.com/rs/zerolog/log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sed/apperr"
+	"sed/handlers"
+	"sed/models"
+	"sed/repository"
+	"testing"
+)
+
+// logLine holds the fields of a request log line checked by the tests.
+type logLine struct {
+	Level     string `json:"level"`
+	Message   string `json:"message"`
+	RequestId string `json:"request_id"`
+	Method    string `json:"method"`
+	Route     string `json:"route"`
+	Status    int    `json:"status"`
+	UserId    int    `json:"user_id"`
+	ErrorCode string `json:"error_code"`
+	Error     string `json:"error"`
+	TraceId   string `json:"trace_id"`
+	SpanId    string `json:"span_id"`
+}
+
+// captureLogs rebuilds the router of s on repositories with its logs going
+// to a buffer and returns a function that parses the lines logged since the
+// last call.
+func (s *testServer) captureLogs(repositories repository.Repositories) func() []logLine {
+	var buffer bytes.Buffer
+
+	logger := zlog.Logger
+	zlog.Logger = zerolog.New(&buffer)
+	s.router = newTestRouter(repositories, testSettings())
+	zlog.Logger = logger
+
+	return func() []logLine {
+		var lines []logLine
+		for _, data := range bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n")) {
+			var line logLine
+			err := json.Unmarshal(data, &line)
+			if err != nil {
+				s.t.Fatalf("log line is not JSON: %s", data)
+			}
+			lines = append(lines, line)
+		}
+		buffer.Reset()
+		return lines
+	}
+}
+
+func TestRequestLog(t *testing.T) {
+	s := newTestServer(t)
+	logs := s.captureLogs(s.store.Repositories())
+
+	response := s.request(http.MethodPost, "/letters/types", map[string]string{handlers.RequestIdHeader: "proxy-42"}, nil)
+	s.fails(response, apperr.Unauthorized)
+	if response.RequestId != "proxy-42" {
+		t.Errorf("request id %q was not echoed", response.RequestId)
+	}
+
+	lines := logs()
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1", len(lines))
+	}
+	if lines[0].RequestId != "proxy-42" || lines[0].Route != "/letters/types" || lines[0].Status != http.StatusUnauthorized || lines[0].ErrorCode != string(apperr.Unauthorized) {
+		t.Errorf("unexpected log line %+v", lines[0])
+	}
+
+	id := s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+	token := s.login("clerk@example.com")
+	logs()
+
+	response = s.request(http.MethodPost, "/letters/types", map[string]string{"Authorization": "Bearer " + token, handlers.RequestIdHeader: "bad id\n"}, nil)
+	s.ok(response, nil)
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(response.RequestId) {
+		t.Errorf("invalid request id was not replaced: %q", response.RequestId)
+	}
+
+	lines = logs()
+	if len(lines) != 1 || lines[0].RequestId != response.RequestId || lines[0].UserId != id || lines[0].ErrorCode != "" || lines[0].Level != "info" {
+		t.Errorf("unexpected log lines %+v", lines)
+	}
+}
+
+func TestRequestIdHeader(t *testing.T) {
+	s := newTestServer(t)
+
+	request := httptest.NewRequest(http.MethodPost, "/ping", nil)
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+
+	var response models.Response
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id := recorder.Header().Get(handlers.RequestIdHeader); id == "" || id != response.RequestId {
+		t.Errorf("header %q, envelope %q", id, response.RequestId)
+	}
+}
+
+func TestInternalErrorsAreLogged(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	repositories := s.store.Repositories()
+	repositories.Letters = brokenLetters{repositories.Letters}
+
+	logs := s.captureLogs(repositories)
+
+	response := s.do(http.MethodPost, "/letters", token, models.LetterFilter{RowsLimit: 10})
+	s.fails(response, apperr.Internal)
+
+	lines := logs()
+	if len(lines) != 2 || lines[0].Error == "" || lines[0].RequestId != response.CorrelationId || lines[1].Level != "error" || lines[1].ErrorCode != string(apperr.Internal) {
+		t.Errorf("unexpected log lines %+v", lines)
+	}
+}
@@ -1,72 +1,354 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
-	"log"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	stdlog "log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sed/config"
 	"sed/db"
 	"sed/handlers"
+	"sed/metrics"
+	"sed/openapi"
+	"sed/repository/postgres"
+	"sed/tracing"
+	"syscall"
 	"time"
 )
 
 func main() {
-	handlers.Validate = validator.New()
-	r := gin.Default()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "retention":
+			os.Exit(runRetention(os.Args[2:]))
+		}
+	}
+
+	settings, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal().Err(err).Msg("loading configuration")
+	}
+
+	err = settings.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("loading configuration")
+	}
+
+	setupLogging(settings.Log)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), settings.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("setting up tracing")
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := shutdownTracing(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("flushing traces")
+		}
+	}()
+
+	gin.SetMode(settings.HTTP.Mode)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := db.Connect(ctx, settings.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("connecting to the database")
+	}
+	defer pool.Close()
+
+	err = db.Migrate(ctx, pool)
+	if err != nil {
+		log.Fatal().Err(err).Msg("migrating the database")
+	}
+
+	repositories := postgres.New(pool)
+
+	m := metrics.New(repositories.Stats)
+	m.RegisterPool(pool)
+
+	server := &http.Server{
+		Addr:              settings.HTTP.Address,
+		Handler:           newRouter(handlers.New(repositories, handlers.NewValidator(), settings, m), m, settings),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	err = serve(ctx, server, settings.HTTP)
+	if err != nil {
+		log.Fatal().Err(err).Msg("serving")
+	}
+}
+
+// setupLogging writes JSON logs at settings.Level to stderr, including
+// those of packages using the standard logger.
+func setupLogging(settings config.Log) {
+	level, _ := zerolog.ParseLevel(settings.Level)
+
+	log.Logger = zerolog.New(os.Stderr).Level(level).With().Timestamp().Logger()
+
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(log.Logger)
+}
+
+// serve runs server until ctx is done, then stops accepting connections and
+// gives in-flight requests settings.ShutdownTimeout to finish.
+func serve(ctx context.Context, server *http.Server, settings config.HTTP) error {
+	errs := make(chan error, 1)
+	go func() {
+		if settings.TLS.Enabled() {
+			errs <- server.ListenAndServeTLS(settings.TLS.CertFile, settings.TLS.KeyFile)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+
+	log.Info().Str("address", settings.Address).Msg("listening")
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info().Dur("timeout", settings.ShutdownTimeout).Msg("shutting down, draining requests")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), settings.ShutdownTimeout)
+	defer cancel()
+
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+
+	return nil
+}
+
+// loadDatabase reads the database settings for the commands, which take
+// the configuration from the file in SED_CONFIG and the environment only.
+func loadDatabase() (config.Database, error) {
+	settings, err := config.Load(nil)
+	if err != nil {
+		return config.Database{}, err
+	}
+
+	return settings.Database, settings.Database.Validate()
+}
+
+// newRouter registers every route on a fresh engine.
+func newRouter(h *handlers.Handler, m *metrics.Metrics, settings config.Config) *gin.Engine {
+	r := gin.New()
+
+	// The list is validated with the configuration.
+	err := r.SetTrustedProxies(settings.HTTP.TrustedProxies)
+	if err != nil {
+		log.Fatal().Err(err).Msg("setting trusted proxies")
+	}
+
+	r.Use(tracing.Middleware(settings.Tracing.ServiceName), handlers.RequestLog(log.Logger), gin.CustomRecoveryWithWriter(nil, h.Recover), m.Middleware)
+
+	// Browsers never send credentials to a wildcard origin.
+	wildcard := false
+	for _, origin := range settings.HTTP.CORSOrigins {
+		wildcard = wildcard || origin == "*"
+	}
 
 	r.Use(cors.New(cors.Config{
 		AllowMethods:     []string{"*"},
 		AllowHeaders:     []string{"*"},
-		AllowCredentials: true,
-		AllowAllOrigins:  true,
-		ExposeHeaders:    []string{"Content-Type"},
+		AllowCredentials: !wildcard,
+		AllowOrigins:     settings.HTTP.CORSOrigins,
+		ExposeHeaders:    []string{"Content-Type", "ETag", handlers.ReplayedHeader},
 		MaxAge:           12 * time.Hour,
 	}))
 
-	err := db.Connect()
-	if err != nil {
-		log.Fatal(err)
+	// Probes are registered before the legacy status middleware, so that
+	// orchestrators always see the real status.
+	r.GET("/healthz", handlers.Healthz)
+
+	r.GET("/readyz", h.Readyz)
+
+	if settings.Metrics.Enabled {
+		r.GET("/metrics", m.Handler(settings.Metrics.Token))
 	}
 
+	r.GET("/openapi.json", openapi.Handler(openapi.Spec()))
+
+	r.GET("/docs/*file", openapi.UI())
+
+	r.Use(handlers.LegacyStatus(settings.HTTP.LegacyStatus), handlers.Localize)
+
 	r.POST("/ping", handlers.Ping)
 
-	r.POST("/login", handlers.Login)
+	r.POST("/login", h.Login)
+
+	r.POST("/letters", h.Authorization, h.GetDocuments)
+
+	r.POST("/letter/:id", h.Authorization, h.GetDocument)
+
+	r.POST("/letter", h.Authorization, h.Idempotent, h.CreateDocument)
+
+	r.PUT("/letter", h.Authorization, h.EditDocument)
+
+	r.POST("/letters/types", h.Authorization, h.GetLetterTypes)
+
+	r.POST("/users", h.Authorization, h.GetUsers)
+
+	r.POST("/users/:id", h.Authorization, h.GetProfile)
+
+	r.PUT("/user", h.Authorization, h.EditUser)
+
+	r.POST("/users/:id/transfers", h.Authorization, h.GetEmployeeTransfers)
+
+	r.POST("/roles", h.Authorization, h.GetRoles)
+
+	r.POST("/departments", h.Authorization, h.GetDepartments)
+
+	r.POST("/department", h.Authorization, h.CreateDepartment)
+
+	r.PUT("/department", h.Authorization, h.EditDepartment)
+
+	r.PUT("/department/move", h.Authorization, h.MoveDepartment)
+
+	r.PUT("/department/heads", h.Authorization, h.AssignDepartmentHeads)
+
+	r.PUT("/department/archive", h.Authorization, h.ArchiveDepartment)
+
+	r.PUT("/department/merge", h.Authorization, h.MergeDepartment)
+
+	r.POST("/departments/tree", h.Authorization, h.GetDepartmentTree)
+
+	r.POST("/departments/chart/:format", h.Authorization, h.ExportOrgChart)
+
+	r.POST("/departments/:id/users", h.Authorization, h.GetDepartmentEmployees)
+
+	r.POST("/import", h.Authorization, h.ImportStaff)
+
+	r.POST("/letters/describe", h.Authorization, h.DescribeLetter)
+
+	r.POST("/letters/agreements", h.Authorization, h.GetAgreements)
+
+	r.POST("/letters/agreement", h.Authorization, h.Idempotent, h.CreateAgreement)
+
+	r.POST("/letters/agreement/:id", h.Authorization, h.ViewAgreement)
+
+	r.POST("/letters/agreement/:id/:agree", h.Authorization, h.AgreeAgreement)
+
+	// The routes above are the legacy v1 API, kept until clients migrate.
+	v2 := r.Group("/api/v2")
+
+	v2.POST("/sessions", h.Login)
+
+	v2.GET("/letters", h.Authorization, h.ListLetters)
+
+	v2.POST("/letters", h.Authorization, h.Idempotent, h.PostLetter)
+
+	v2.GET("/letters/:id", h.Authorization, h.GetDocument)
+
+	v2.PATCH("/letters/:id", h.Authorization, h.PatchLetter)
+
+	v2.DELETE("/letters/:id", h.Authorization, h.DeleteLetter)
+
+	v2.POST("/letters/:id/assignments", h.Authorization, h.PostLetterAssignment)
+
+	v2.PUT("/letters/:id/case", h.Authorization, h.PutLetterCase)
+
+	v2.GET("/trash/letters", h.Authorization, h.ListTrash)
+
+	v2.POST("/trash/letters/:id/restore", h.Authorization, h.RestoreLetter)
+
+	v2.DELETE("/trash/letters/:id", h.Authorization, h.PurgeLetter)
+
+	v2.GET("/document-types", h.Authorization, h.GetLetterTypes)
+
+	v2.PUT("/document-types/:id/retention", h.Authorization, h.PutRetentionRule)
+
+	v2.DELETE("/document-types/:id/retention", h.Authorization, h.DeleteRetentionRule)
+
+	v2.GET("/retention-rules", h.Authorization, h.ListRetentionRules)
+
+	v2.GET("/retention/overdue", h.Authorization, h.ListOverdueLetters)
+
+	v2.GET("/destruction-acts", h.Authorization, h.ListDestructionActs)
+
+	v2.POST("/destruction-acts", h.Authorization, h.PostDestructionAct)
+
+	v2.GET("/destruction-acts/:id", h.Authorization, h.GetDestructionAct)
+
+	v2.POST("/destruction-acts/:id/submit", h.Authorization, h.SubmitDestructionAct)
+
+	v2.POST("/destruction-acts/:id/execute", h.Authorization, h.ExecuteDestructionAct)
+
+	v2.GET("/cases", h.Authorization, h.ListCases)
+
+	v2.POST("/cases", h.Authorization, h.PostCase)
+
+	v2.GET("/cases/inventory/:format", h.Authorization, h.ExportCaseInventory)
+
+	v2.GET("/cases/:id", h.Authorization, h.GetCase)
+
+	v2.POST("/cases/:id/close", h.Authorization, h.CloseCase)
+
+	v2.POST("/cases/:id/open", h.Authorization, h.OpenCase)
+
+	v2.GET("/users", h.Authorization, h.ListUsers)
+
+	v2.GET("/users/:id", h.Authorization, h.GetProfile)
+
+	v2.PATCH("/users/:id", h.Authorization, h.PatchUser)
+
+	v2.GET("/users/:id/transfers", h.Authorization, h.GetEmployeeTransfers)
+
+	v2.GET("/roles", h.Authorization, h.ListRoles)
 
-	r.POST("/letters", handlers.Authorization, handlers.GetDocuments)
+	v2.GET("/departments", h.Authorization, h.ListDepartments)
 
-	r.POST("/letter/:id", handlers.Authorization, handlers.GetDocument)
+	v2.POST("/departments", h.Authorization, h.PostDepartment)
 
-	r.POST("/letter", handlers.Authorization, handlers.CreateDocument)
+	v2.GET("/departments/tree", h.Authorization, h.GetDepartmentTree)
 
-	r.PUT("/letter", handlers.Authorization, handlers.EditDocument)
+	v2.GET("/departments/chart/:format", h.Authorization, h.ExportOrgChart)
 
-	r.POST("/letters/types", handlers.Authorization, handlers.GetLetterTypes)
+	v2.GET("/departments/:id", h.Authorization, h.GetDepartment)
 
-	r.POST("/users", handlers.Authorization, handlers.GetUsers)
+	v2.PATCH("/departments/:id", h.Authorization, h.PatchDepartment)
 
-	r.POST("/users/:id", handlers.Authorization, handlers.GetProfile)
+	v2.DELETE("/departments/:id", h.Authorization, h.DeleteDepartment)
 
-	r.PUT("/user", handlers.Authorization, handlers.EditUser)
+	v2.PUT("/departments/:id/parent", h.Authorization, h.PutDepartmentParent)
 
-	r.POST("/roles", handlers.Authorization, handlers.GetRoles)
+	v2.PUT("/departments/:id/heads", h.Authorization, h.PutDepartmentHeads)
 
-	r.POST("/departments", handlers.Authorization, handlers.GetDepartments)
+	v2.POST("/departments/:id/merge", h.Authorization, h.PostDepartmentMerge)
 
-	r.POST("/department", handlers.Authorization, handlers.CreateDepartment)
+	v2.GET("/departments/:id/users", h.Authorization, h.ListDepartmentUsers)
 
-	r.PUT("/department", handlers.Authorization, handlers.EditDepartment)
+	v2.POST("/imports", h.Authorization, h.ImportStaff)
 
-	r.POST("/departments/:id/users", handlers.Authorization, handlers.GetDepartmentEmployees)
+	v2.GET("/agreements", h.Authorization, h.ListAgreements)
 
-	r.POST("/letters/describe", handlers.Authorization, handlers.DescribeLetter)
+	v2.POST("/agreements", h.Authorization, h.Idempotent, h.PostAgreement)
 
-	r.POST("/letters/agreements", handlers.Authorization, handlers.GetAgreements)
+	v2.GET("/agreements/:id", h.Authorization, h.GetAgreement)
 
-	r.POST("/letters/agreement", handlers.Authorization, handlers.CreateAgreement)
+	v2.PATCH("/agreements/:id", h.Authorization, h.PatchAgreement)
 
-	r.POST("/letters/agreement/:id", handlers.Authorization, handlers.GetAgreement)
+	v2.GET("/audit", h.Authorization, h.ListAudit)
 
-	r.POST("/letters/agreement/:id/:agree", handlers.Authorization, handlers.AgreeAgreement)
+	v2.GET("/audit/verify", h.Authorization, h.VerifyAudit)
 
-	log.Fatalln(r.Run())
+	return r
 }
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	zlog "github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sed/apperr"
+	"sed/config"
+	"sed/handlers"
+	"sed/metrics"
+	"sed/models"
+	"sed/repository"
+	"sed/repository/memory"
+	"testing"
+)
+
+const testPassword = "secret-password"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	log.SetOutput(io.Discard)
+	zlog.Logger = zerolog.Nop()
+	os.Exit(m.Run())
+}
+
+// testServer is the router from newRouter backed by an in-memory store.
+type testServer struct {
+	t      *testing.T
+	store  *memory.Store
+	router *gin.Engine
+}
+
+// envelope is models.Response with the payload left undecoded.
+type envelope struct {
+	Code          int                 `json:"code"`
+	Message       string              `json:"message"`
+	Error         apperr.Code         `json:"error"`
+	Details       []models.FieldError `json:"details"`
+	CorrelationId string              `json:"correlation_id"`
+	RequestId     string              `json:"request_id"`
+	Payload       json.RawMessage     `json:"payload"`
+	Page          *models.Page        `json:"page"`
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	store := memory.New()
+
+	return &testServer{
+		t:      t,
+		store:  store,
+		router: newTestRouter(store.Repositories(), testSettings()),
+	}
+}
+
+// testSettings are the defaults with a JWT secret.
+func testSettings() config.Config {
+	settings := config.Default()
+	settings.Auth.JWTSecret = "test-secret-test-secret-test-secret"
+	return settings
+}
+
+func newTestRouter(repositories repository.Repositories, settings config.Config) *gin.Engine {
+	m := metrics.New(repositories.Stats)
+	return newRouter(handlers.New(repositories, handlers.NewValidator(), settings, m), m, settings)
+}
+
+// with returns a copy of s reporting to t, for use inside subtests.
+func (s *testServer) with(t *testing.T) *testServer {
+	copied := *s
+	copied.t = t
+	return &copied
+}
+
+// addEmployee stores an employee with testPassword and returns its id.
+func (s *testServer) addEmployee(email, role string, departmentId int) int {
+	s.t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	return s.store.AddEmployee(models.Employee{
+		FullName:     email,
+		Email:        email,
+		Password:     string(hash),
+		RoleId:       s.store.RoleId(role),
+		DepartmentId: departmentId,
+	})
+}
+
+// login signs in with testPassword and returns the bearer token.
+func (s *testServer) login(email string) string {
+	s.t.Helper()
+
+	var payload struct {
+		Token string `json:"token"`
+	}
+	s.ok(s.do(http.MethodPost, "/login", "", models.Employee{Email: email, Password: testPassword}), &payload)
+
+	return payload.Token
+}
+
+// admin creates an ADMIN employee and returns its token.
+func (s *testServer) admin() string {
+	s.t.Helper()
+
+	s.addEmployee("admin@example.com", "ADMIN", 0)
+	return s.login("admin@example.com")
+}
+
+// do sends body as JSON with token as the bearer credentials and decodes
+// the response envelope.
+func (s *testServer) do(method, path, token string, body interface{}) envelope {
+	s.t.Helper()
+
+	headers := map[string]string{}
+	if token != "" {
+		headers["Authorization"] = "Bearer " + token
+	}
+
+	return s.request(method, path, headers, body)
+}
+
+// request is do with raw headers. The HTTP status must match
+// envelope.Code.
+func (s *testServer) request(method, path string, headers map[string]string, body interface{}) envelope {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request := httptest.NewRequest(method, path, reader)
+	for key, value := range headers {
+		if value != "" {
+			request.Header.Set(key, value)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+
+	var response envelope
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v: %s", method, path, err, recorder.Body.String())
+	}
+
+	if recorder.Code != response.Code {
+		s.t.Fatalf("%s %s: status %d, envelope code %d", method, path, recorder.Code, response.Code)
+	}
+
+	return response
+}
+
+// ok fails the test unless response is a success and decodes its payload
+// into v when v is not nil.
+func (s *testServer) ok(response envelope, v interface{}) {
+	s.t.Helper()
+
+	if response.Code != http.StatusOK {
+		s.t.Fatalf("code %d: %s", response.Code, response.Message)
+	}
+
+	if v == nil {
+		return
+	}
+
+	err := json.Unmarshal(response.Payload, v)
+	if err != nil {
+		s.t.Fatalf("decoding payload: %v: %s", err, response.Payload)
+	}
+}
+
+// fails fails the test unless response is the catalog error for code.
+func (s *testServer) fails(response envelope, code apperr.Code) {
+	s.t.Helper()
+
+	want := apperr.New(code)
+	if response.Error != code || response.Code != want.Status {
+		s.t.Fatalf("error %s (%d), want %s (%d): %s", response.Error, response.Code, code, want.Status, response.Message)
+	}
+}
+
+// invalid fails the test unless response is a validation error naming
+// field with rule.
+func (s *testServer) invalid(response envelope, field, rule string) {
+	s.t.Helper()
+
+	s.fails(response, apperr.ValidationFailed)
+	for _, detail := range response.Details {
+		if detail.Field == field && detail.Rule == rule {
+			return
+		}
+	}
+	s.t.Fatalf("details %+v do not name %s failing %s", response.Details, field, rule)
+}
+
+// department creates a department through the API and returns its id.
+func (s *testServer) department(token string, department models.Department) int {
+	s.t.Helper()
+
+	s.ok(s.do(http.MethodPost, "/department", token, department), nil)
+
+	var departments []models.Department
+	s.ok(s.do(http.MethodPost, "/departments", token, nil), &departments)
+
+	for _, stored := range departments {
+		if stored.Name == department.Name {
+			return stored.Id
+		}
+	}
+
+	s.t.Fatalf("department %q not found after create", department.Name)
+	return 0
+}
+
+// letter creates a valid letter through the API and returns its id.
+func (s *testServer) letter(token, name string) int {
+	s.t.Helper()
+
+	var id int
+	s.ok(s.do(http.MethodPost, "/letter", token, models.Letter{
+		Name:           name,
+		Sender:         "Ministry of Finance",
+		DocumentTypeId: s.documentTypeId(token),
+		Content:        "Please review the attached budget proposal.",
+	}), &id)
+
+	return id
+}
+
+func (s *testServer) documentTypeId(token string) int {
+	s.t.Helper()
+
+	var documentTypes []models.DocumentType
+	s.ok(s.do(http.MethodPost, "/letters/types", token, nil), &documentTypes)
+
+	if len(documentTypes) == 0 {
+		s.t.Fatal("no document types")
+	}
+
+	return documentTypes[0].Id
+}
+
+func TestPing(t *testing.T) {
+	s := newTestServer(t)
+
+	s.ok(s.do(http.MethodPost, "/ping", "", nil), nil)
+}
@@ -0,0 +1,202 @@
+// Package metrics exports Prometheus metrics about HTTP traffic, the
+// database pool and business events.
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"sed/repository"
+	"strconv"
+	"time"
+)
+
+const namespace = "sed"
+
+// statsTimeout bounds the queries behind the business gauges on a scrape.
+const statsTimeout = 5 * time.Second
+
+// Metrics owns a registry, so that every router built in tests gets
+// counters of its own.
+type Metrics struct {
+	registry          *prometheus.Registry
+	requests          *prometheus.CounterVec
+	duration          *prometheus.HistogramVec
+	lettersRegistered prometheus.Counter
+	agreementsDecided *prometheus.CounterVec
+}
+
+// New registers the HTTP and business metrics. stats backs the gauges
+// computed from stored state on every scrape.
+func New(stats repository.StatsRepository) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		lettersRegistered: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "letters_registered_total",
+			Help:      "Letters registered since the start.",
+		}),
+		agreementsDecided: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "agreements_decided_total",
+			Help:      "Agreement decisions since the start, by decision.",
+		}, []string{"decision"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.lettersRegistered,
+		m.agreementsDecided,
+		&statsCollector{stats: stats},
+	)
+
+	return m
+}
+
+// RegisterPool exports the statistics of pool.
+func (m *Metrics) RegisterPool(pool *pgxpool.Pool) {
+	m.registry.MustRegister(&poolCollector{pool: pool})
+}
+
+// Middleware counts and times requests. Routes are reported by their
+// pattern, so that ids in paths do not create a series each.
+func (m *Metrics) Middleware(c *gin.Context) {
+	start := time.Now()
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	m.requests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	m.duration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the metrics to clients presenting token as their bearer
+// credentials.
+func (m *Metrics) Handler(token string) gin.HandlerFunc {
+	serve := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	expected := []byte("Bearer " + token)
+
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		serve.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+func (m *Metrics) LetterRegistered() {
+	m.lettersRegistered.Inc()
+}
+
+func (m *Metrics) AgreementDecided(agree bool) {
+	decision := "rejected"
+	if agree {
+		decision = "agreed"
+	}
+	m.agreementsDecided.WithLabelValues(decision).Inc()
+}
+
+var (
+	pendingAgreementsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "agreements_pending"),
+		"Agreements waiting for a decision.", nil, nil)
+	overdueAssignmentsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "assignments_overdue"),
+		"Assignments past their due date whose letter is not distributed.", nil, nil)
+)
+
+type statsCollector struct {
+	stats repository.StatsRepository
+}
+
+func (c *statsCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- pendingAgreementsDesc
+	descs <- overdueAssignmentsDesc
+}
+
+func (c *statsCollector) Collect(metrics chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	stats, err := c.stats.Stats(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("collecting business metrics")
+		metrics <- prometheus.NewInvalidMetric(pendingAgreementsDesc, err)
+		return
+	}
+
+	metrics <- prometheus.MustNewConstMetric(pendingAgreementsDesc, prometheus.GaugeValue, float64(stats.PendingAgreements))
+	metrics <- prometheus.MustNewConstMetric(overdueAssignmentsDesc, prometheus.GaugeValue, float64(stats.OverdueAssignments))
+}
+
+var (
+	acquiredConnsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "acquired_conns"),
+		"Connections currently in use.", nil, nil)
+	idleConnsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "idle_conns"),
+		"Connections currently idle.", nil, nil)
+	totalConnsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "total_conns"),
+		"Open connections, including ones being established.", nil, nil)
+	maxConnsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "max_conns"),
+		"Maximum size of the pool.", nil, nil)
+	acquiresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "acquires_total"),
+		"Successful connection acquires.", nil, nil)
+	emptyAcquiresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "empty_acquires_total"),
+		"Acquires that had to wait for a connection.", nil, nil)
+	acquireWaitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "acquire_wait_seconds_total"),
+		"Time spent acquiring connections.", nil, nil)
+)
+
+type poolCollector struct {
+	pool *pgxpool.Pool
+}
+
+func (c *poolCollector) Describe(descs chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{acquiredConnsDesc, idleConnsDesc, totalConnsDesc, maxConnsDesc, acquiresDesc, emptyAcquiresDesc, acquireWaitDesc} {
+		descs <- desc
+	}
+}
+
+func (c *poolCollector) Collect(metrics chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	metrics <- prometheus.MustNewConstMetric(acquiredConnsDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	metrics <- prometheus.MustNewConstMetric(idleConnsDesc, prometheus.GaugeValue, float64(stat.IdleConns()))
+	metrics <- prometheus.MustNewConstMetric(totalConnsDesc, prometheus.GaugeValue, float64(stat.TotalConns()))
+	metrics <- prometheus.MustNewConstMetric(maxConnsDesc, prometheus.GaugeValue, float64(stat.MaxConns()))
+	metrics <- prometheus.MustNewConstMetric(acquiresDesc, prometheus.CounterValue, float64(stat.AcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(emptyAcquiresDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(acquireWaitDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
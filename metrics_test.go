@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sed/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const metricsToken = "metrics-token-metrics-token"
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t)
+
+	settings := testSettings()
+	settings.Metrics.Enabled = true
+	settings.Metrics.Token = metricsToken
+	s.router = newTestRouter(s.store.Repositories(), settings)
+
+	token := s.admin()
+	finance := s.department(token, models.Department{Name: "Finance"})
+	head := s.addEmployee("head@example.com", "DEP_HEAD", finance)
+	s.ok(s.do(http.MethodPut, "/department/heads", token, models.Department{Id: finance, HeadId: head}), nil)
+	headToken := s.login("head@example.com")
+
+	proposal := s.letter(token, "Budget proposal")
+	report := s.letter(token, "Budget report")
+
+	yesterday := time.Now().Add(-24 * time.Hour)
+	s.ok(s.do(http.MethodPost, "/letters/describe", token, models.DescribedLetter{LetterId: proposal, DepartmentId: finance, DueDate: &yesterday}), nil)
+
+	s.ok(s.do(http.MethodPost, "/letters/agreement", token, models.Agreement{LetterId: proposal, DepartmentId: finance}), nil)
+	s.ok(s.do(http.MethodPost, "/letters/agreement", token, models.Agreement{LetterId: report, DepartmentId: finance}), nil)
+
+	var agreements []models.Agreement
+	s.ok(s.do(http.MethodPost, "/letters/agreements", headToken, nil), &agreements)
+	s.ok(s.do(http.MethodPost, "/letters/agreement/"+strconv.Itoa(agreements[0].Id)+"/true", headToken, nil), nil)
+
+	scrape := func(authorization string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+
+		recorder := httptest.NewRecorder()
+		s.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	for _, authorization := range []string{"", "Bearer wrong", metricsToken} {
+		if recorder := scrape(authorization); recorder.Code != http.StatusUnauthorized {
+			t.Errorf("scrape with %q: status %d", authorization, recorder.Code)
+		}
+	}
+
+	recorder := scrape("Bearer " + metricsToken)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d", recorder.Code)
+	}
+
+	body := recorder.Body.String()
+	for _, line := range []string{
+		`sed_http_requests_total{method="POST",route="/letter",status="200"} 2`,
+		`sed_http_requests_total{method="POST",route="/letters/agreement/:id/:agree",status="200"} 1`,
+		`sed_http_request_duration_seconds_count{method="POST",route="/letter"} 2`,
+		`sed_letters_registered_total 2`,
+		`sed_agreements_decided_total{decision="agreed"} 1`,
+		`sed_agreements_pending 1`,
+		`sed_assignments_overdue 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics lack %s", line)
+		}
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	s := newTestServer(t)
+
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	request.Header.Set("Authorization", "Bearer "+metricsToken)
+
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("status %d", recorder.Code)
+	}
+}
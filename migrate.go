@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"os"
+	"sed/db"
+	"strconv"
+	"text/tabwriter"
+)
+
+// runMigrate implements "sed migrate up|down [steps]|status".
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: sed migrate up|down [steps]|status")
+		return 2
+	}
+
+	ctx := context.Background()
+
+	settings, err := loadDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	pool, err := db.Connect(ctx, settings)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer pool.Close()
+
+	switch args[0] {
+	case "up":
+		err = db.Migrate(ctx, pool)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "steps must be a positive number")
+				return 2
+			}
+		}
+		err = db.MigrateDown(ctx, pool, steps)
+	case "status":
+		err = printMigrationStatus(ctx, pool)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n", args[0])
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func printMigrationStatus(ctx context.Context, pool *pgxpool.Pool) error {
+	migrations, err := db.MigrationStatus(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, migration := range migrations {
+		appliedAt := "pending"
+		if migration.AppliedAt != nil {
+			appliedAt = migration.AppliedAt.Format("2006-01-02 15:04:05 MST")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", migration.Version, migration.Name, appliedAt)
+	}
+
+	return w.Flush()
+}
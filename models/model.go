@@ -3,40 +3,125 @@ package models
 import "time"
 
 type DocumentType struct {
-	Id   int    `json:"id,omitempty"`
-	Type string `json:"type,omitempty"`
+	Id     int               `json:"id,omitempty"`
+	Type   string            `json:"type,omitempty"`
+	Label  string            `json:"label,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 type RoleGroup struct {
-	Id   int    `json:"id,omitempty"`
-	Role string `json:"role,omitempty"`
+	Id     int               `json:"id,omitempty"`
+	Role   string            `json:"role,omitempty"`
+	Label  string            `json:"label,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 type Department struct {
-	Id             int    `json:"id,omitempty"`
-	Name           string `json:"name,omitempty"`
-	InternalNumber string `json:"internal_number,omitempty"`
-	Phone          string `json:"phone,omitempty"`
+	Id             int          `json:"id,omitempty"`
+	ParentId       int          `json:"parent_id,omitempty"`
+	Name           string       `json:"name,omitempty"`
+	InternalNumber string       `json:"internal_number,omitempty"`
+	Phone          string       `json:"phone,omitempty"`
+	HeadId         int          `json:"head_id,omitempty"`
+	Head           *Employee    `json:"head,omitempty"`
+	DeputyId       int          `json:"deputy_id,omitempty"`
+	Deputy         *Employee    `json:"deputy,omitempty"`
+	ArchivedAt     *time.Time   `json:"archived_at,omitempty"`
+	MergedIntoId   int          `json:"merged_into_id,omitempty"`
+	Children       []Department `json:"children,omitempty"`
+	Employees      []Employee   `json:"employees,omitempty"`
+	// Version is bumped by every change. Edits send the version they read,
+	// here or as If-Match, and fail if it is out of date.
+	Version int `json:"version,omitempty"`
+}
+
+// DepartmentParent is the body of PUT /api/v2/departments/:id/parent. A
+// parent_id of 0 makes the department a root.
+type DepartmentParent struct {
+	ParentId int `json:"parent_id" validate:"min=0"`
+}
+
+// DepartmentHeads is the body of PUT /api/v2/departments/:id/heads.
+type DepartmentHeads struct {
+	HeadId   int `json:"head_id" validate:"min=0"`
+	DeputyId int `json:"deputy_id" validate:"min=0"`
+}
+
+// DepartmentMergeTarget is the body of POST /api/v2/departments/:id/merge.
+type DepartmentMergeTarget struct {
+	TargetId int `json:"target_id" validate:"required,min=1"`
+}
+
+// DepartmentFilter is the query of POST /departments. A rows_limit of 0
+// lists every department; the v2 API requires one.
+type DepartmentFilter struct {
+	Archived   bool   `json:"archived" form:"archived"`
+	RowsLimit  uint   `json:"rows_limit" form:"rows_limit" validate:"max=500"`
+	RowsOffset uint   `json:"rows_offset" form:"rows_offset"`
+	Cursor     string `json:"cursor" form:"cursor"`
+	Sort       string `json:"sort" form:"sort" validate:"omitempty,oneof=id -id name -name"`
+}
+
+type DepartmentMerge struct {
+	SourceId int `json:"source_id" validate:"required,min=1"`
+	TargetId int `json:"target_id" validate:"required,min=1,nefield=SourceId"`
 }
 
 type Employee struct {
 	Id           int        `json:"id,omitempty"`
 	FullName     string     `json:"full_name,omitempty"`
+	Position     string     `json:"position,omitempty"`
 	RoleId       int        `json:"role_id,omitempty"`
 	Role         RoleGroup  `json:"role,omitempty"`
 	Email        string     `json:"email,omitempty"`
+	Language     string     `json:"language,omitempty" validate:"omitempty,oneof=uz ru en"`
 	Password     string     `json:"password,omitempty"`
 	Token        string     `json:"token,omitempty"`
 	DepartmentId int        `json:"department_id,omitempty"`
 	Department   Department `json:"department,omitempty"`
 }
 
+type EmployeeTransfer struct {
+	Id               int        `json:"id,omitempty"`
+	EmployeeId       int        `json:"employee_id,omitempty"`
+	FromDepartmentId int        `json:"from_department_id,omitempty"`
+	FromDepartment   Department `json:"from_department,omitempty"`
+	ToDepartmentId   int        `json:"to_department_id,omitempty"`
+	ToDepartment     Department `json:"to_department,omitempty"`
+	TransferredBy    int        `json:"transferred_by,omitempty"`
+	TransferredAt    time.Time  `json:"transferred_at,omitempty"`
+}
+
+// EmployeeFilter is sent as the body of POST /users and as the query of
+// GET /api/v2/users.
 type EmployeeFilter struct {
-	FullName   string `json:"full_name"`
-	Email      string `json:"email"`
-	Department string `json:"department"`
-	RowsLimit  uint   `json:"rows_limit" validate:"required,number,min=1"`
-	RowsOffset uint   `json:"rows_offset" validate:"number,min=0"`
+	FullName      string `json:"full_name" form:"full_name"`
+	Email         string `json:"email" form:"email"`
+	Department    string `json:"department" form:"department"`
+	DepartmentIds []int  `json:"-" form:"-"`
+	RowsLimit     uint   `json:"rows_limit" form:"rows_limit" validate:"required,number,min=1,max=500"`
+	RowsOffset    uint   `json:"rows_offset" form:"rows_offset" validate:"number,min=0"`
+	Cursor        string `json:"cursor" form:"cursor"`
+	Sort          string `json:"sort" form:"sort" validate:"omitempty,oneof=id -id full_name -full_name email -email"`
+}
+
+// DepartmentEmployeeFilter is the query of POST /departments/:id/users. A
+// rows_limit of 0 lists every employee; the v2 API requires one.
+type DepartmentEmployeeFilter struct {
+	Subdepartments bool   `json:"subdepartments" form:"subdepartments"`
+	RowsLimit      uint   `json:"rows_limit" form:"rows_limit" validate:"max=500"`
+	RowsOffset     uint   `json:"rows_offset" form:"rows_offset"`
+	Cursor         string `json:"cursor" form:"cursor"`
+	Sort           string `json:"sort" form:"sort" validate:"omitempty,oneof=id -id full_name -full_name email -email"`
+}
+
+// RoleFilter is the query of POST /roles. A rows_limit of 0 lists every
+// role; the v2 API requires one.
+type RoleFilter struct {
+	RowsLimit  uint   `json:"rows_limit" form:"rows_limit" validate:"max=500"`
+	RowsOffset uint   `json:"rows_offset" form:"rows_offset"`
+	Cursor     string `json:"cursor" form:"cursor"`
+	Sort       string `json:"sort" form:"sort" validate:"omitempty,oneof=id -id role -role"`
 }
 
 type Letter struct {
@@ -50,13 +135,58 @@ type Letter struct {
 	OutgoingNumber     string       `json:"outgoing_number,omitempty"`
 	DistributionDate   time.Time    `json:"distribution_date,omitempty"`
 	Content            string       `json:"content,omitempty" validate:"required,min=20"`
+	// Version is bumped by every change. Edits send the version they read,
+	// here or as If-Match, and fail if it is out of date.
+	Version int `json:"version,omitempty"`
+	// DeletedAt is set while the letter is in the trash, where only admins
+	// see it, until it is restored or purged.
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy    int        `json:"deleted_by,omitempty"`
+	DeleteReason string     `json:"delete_reason,omitempty"`
+	// DestroyedAt is set once a destruction act has removed the content,
+	// leaving the registration data as a stub.
+	DestroyedAt      *time.Time `json:"destroyed_at,omitempty"`
+	DestructionActId int        `json:"destruction_act_id,omitempty"`
+	// RetainUntil is when the retention period of the letter ends, set in
+	// the list of overdue letters.
+	RetainUntil *time.Time `json:"retain_until,omitempty"`
+	// CaseId is the case of the nomenclature the letter is filed into, and
+	// CaseIndex its index.
+	CaseId    int    `json:"case_id,omitempty"`
+	CaseIndex string `json:"case_index,omitempty"`
+}
+
+// LetterFiling is the body of PUT /api/v2/letters/:id/case.
+type LetterFiling struct {
+	CaseId int `json:"case_id" validate:"required,min=1"`
 }
 
+// LetterDeletion is the body of DELETE /api/v2/letters/:id.
+type LetterDeletion struct {
+	Reason string `json:"reason" validate:"required,min=3"`
+}
+
+// TrashFilter is the query of GET /api/v2/trash/letters.
+type TrashFilter struct {
+	RowsLimit  uint   `json:"rows_limit" form:"rows_limit" validate:"required,min=1,max=500"`
+	RowsOffset uint   `json:"rows_offset" form:"rows_offset"`
+	Cursor     string `json:"cursor" form:"cursor"`
+	Sort       string `json:"sort" form:"sort" validate:"omitempty,oneof=id -id deleted_at -deleted_at"`
+}
+
+// LetterFilter is sent as the body of POST /letters and as the query of
+// GET /api/v2/letters.
 type LetterFilter struct {
-	Name       string `json:"name"`
-	Sender     string `json:"sender"`
-	RowsLimit  uint   `json:"rows_limit" validate:"required,number,min=1"`
-	RowsOffset uint   `json:"rows_offset"`
+	Name           string `json:"name" form:"name"`
+	Sender         string `json:"sender" form:"sender"`
+	DepartmentId   int    `json:"department_id" form:"department_id"`
+	Subdepartments bool   `json:"subdepartments" form:"subdepartments"`
+	DepartmentIds  []int  `json:"-" form:"-"`
+	CaseIndex      string `json:"case_index" form:"case_index"`
+	RowsLimit      uint   `json:"rows_limit" form:"rows_limit" validate:"required,number,min=1,max=500"`
+	RowsOffset     uint   `json:"rows_offset" form:"rows_offset"`
+	Cursor         string `json:"cursor" form:"cursor"`
+	Sort           string `json:"sort" form:"sort" validate:"omitempty,oneof=id -id name -name sender -sender entry_date -entry_date"`
 }
 
 type DescribedLetter struct {
@@ -67,6 +197,26 @@ type DescribedLetter struct {
 	Department        Department `json:"department,omitempty"`
 	ExecutiveEmployee int        `json:"executive_employee,omitempty"`
 	Employee          Employee   `json:"employee,omitempty"`
+	// DueDate is when the letter has to be distributed; past it the
+	// assignment is overdue.
+	DueDate *time.Time `json:"due_date,omitempty"`
+}
+
+// AgreementDecision is the body of PATCH /api/v2/agreements/:id.
+type AgreementDecision struct {
+	Agreed *bool `json:"agreed,omitempty" validate:"required_without=Viewed"`
+	// Viewed marks the agreement viewed; it cannot be unmarked.
+	Viewed *bool `json:"viewed,omitempty" validate:"omitempty,eq=true"`
+}
+
+// AgreementFilter is the query of POST /letters/agreements. A rows_limit of
+// 0 lists the whole inbox; the v2 API requires one.
+type AgreementFilter struct {
+	Subdepartments bool   `json:"subdepartments" form:"subdepartments"`
+	RowsLimit      uint   `json:"rows_limit" form:"rows_limit" validate:"max=500"`
+	RowsOffset     uint   `json:"rows_offset" form:"rows_offset"`
+	Cursor         string `json:"cursor" form:"cursor"`
+	Sort           string `json:"sort" form:"sort" validate:"omitempty,oneof=id -id agreed_at -agreed_at"`
 }
 
 type Agreement struct {
@@ -78,16 +228,251 @@ type Agreement struct {
 	Viewed       bool       `json:"viewed,omitempty"`
 	AgreedAt     time.Time  `json:"agreed_at,omitempty"`
 	Agreed       bool       `json:"agreed,omitempty"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	// DestructionActId is set on the agreements approving the destruction
+	// of the letter under that act.
+	DestructionActId int `json:"destruction_act_id,omitempty"`
 }
 
 type Response struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Time    time.Time   `json:"time"`
-	Payload interface{} `json:"payload,omitempty"`
+	Code          int          `json:"code"`
+	Message       string       `json:"message"`
+	Error         string       `json:"error,omitempty"`
+	Details       []FieldError `json:"details,omitempty"`
+	CorrelationId string       `json:"correlation_id,omitempty"`
+	RequestId     string       `json:"request_id,omitempty"`
+	Time          time.Time    `json:"time"`
+	Payload       interface{}  `json:"payload,omitempty"`
+	Page          *Page        `json:"page,omitempty"`
+}
+
+// Page describes the list sent as the payload. Lists are sorted by the
+// sort parameter of their filter, "field" or "-field" for descending, and
+// then by id. A page starts after the cursor, when set, and then skips
+// rows_offset rows.
+type Page struct {
+	// Total counts the rows matching the filter on every page.
+	Total int `json:"total"`
+	// NextCursor is the cursor of the following page, empty on the last.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Session is returned by POST /login.
+type Session struct {
+	Token string `json:"token"`
+	Role  string `json:"role"`
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
 type Token struct {
 	Id    int    `json:"id"`
 	Email string `json:"email"`
+	Exp   int64  `json:"exp"`
+}
+
+type ImportError struct {
+	Sheet   string `json:"sheet"`
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportReport struct {
+	DryRun      bool          `json:"dry_run"`
+	Departments int           `json:"departments"`
+	Employees   int           `json:"employees"`
+	Errors      []ImportError `json:"errors,omitempty"`
+}
+
+// Readiness is reported by GET /readyz.
+type Readiness struct {
+	Database   string `json:"database"`
+	Migrations struct {
+		Applied int   `json:"applied"`
+		Pending []int `json:"pending"`
+	} `json:"migrations"`
+}
+
+// Stats are the business gauges exported as metrics.
+type Stats struct {
+	PendingAgreements  int
+	OverdueAssignments int
+}
+
+// IdempotentRequest is a request sent with an Idempotency-Key and, once it
+// has been handled, the response to replay on retries. Status is 0 while
+// the request is in progress.
+type IdempotentRequest struct {
+	EmployeeId  int
+	Key         string
+	RequestHash string
+	Status      int
+	Headers     map[string]string
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// The entities recorded in the audit log.
+const (
+	AuditLetter         = "letter"
+	AuditEmployee       = "employee"
+	AuditDepartment     = "department"
+	AuditAgreement      = "agreement"
+	AuditImport         = "import"
+	AuditRetentionRule  = "retention_rule"
+	AuditDestructionAct = "destruction_act"
+	AuditCase           = "case"
+)
+
+// AuditEntry records one change: who made it, to which entity, the fields
+// that changed and where the request came from. Hash chains the entry to
+// the one before it, whose hash is PreviousHash.
+type AuditEntry struct {
+	Id           int                    `json:"id"`
+	ActorId      int                    `json:"actor_id,omitempty"`
+	Action       string                 `json:"action"`
+	Entity       string                 `json:"entity"`
+	EntityId     int                    `json:"entity_id"`
+	Changes      map[string]AuditChange `json:"changes"`
+	IP           string                 `json:"ip"`
+	RequestId    string                 `json:"request_id"`
+	CreatedAt    time.Time              `json:"created_at"`
+	PreviousHash string                 `json:"previous_hash"`
+	Hash         string                 `json:"hash"`
+}
+
+// AuditChange holds a field before and after a change, null when the
+// field did not exist.
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditFilter is the query of GET /api/v2/audit. From is inclusive, To
+// exclusive.
+type AuditFilter struct {
+	Entity     string    `json:"entity" form:"entity" validate:"omitempty,oneof=letter employee department agreement import retention_rule destruction_act case"`
+	EntityId   int       `json:"entity_id" form:"entity_id"`
+	ActorId    int       `json:"actor_id" form:"actor_id"`
+	From       time.Time `json:"from" form:"from"`
+	To         time.Time `json:"to" form:"to"`
+	RowsLimit  uint      `json:"rows_limit" form:"rows_limit" validate:"required,min=1,max=500"`
+	RowsOffset uint      `json:"rows_offset" form:"rows_offset"`
+	Cursor     string    `json:"cursor" form:"cursor"`
+	Sort       string    `json:"sort" form:"sort" validate:"omitempty,oneof=id -id"`
+}
+
+// AuditVerification is the result of checking the hash chain of the audit
+// log. BrokenId is the first entry that does not match its hash or does
+// not follow the entry before it.
+type AuditVerification struct {
+	Entries  int  `json:"entries"`
+	Valid    bool `json:"valid"`
+	BrokenId int  `json:"broken_id,omitempty"`
+}
+
+// RetentionRule is how many years letters of a document type are kept
+// after their entry date before they may be destroyed. Article cites the
+// legal basis.
+type RetentionRule struct {
+	Id             int          `json:"id,omitempty"`
+	DocumentTypeId int          `json:"document_type_id,omitempty"`
+	DocumentType   DocumentType `json:"document_type,omitempty"`
+	Years          int          `json:"years" validate:"required,min=1,max=100"`
+	Article        string       `json:"article,omitempty"`
+}
+
+// RetentionFilter is the query of GET /api/v2/retention/overdue.
+type RetentionFilter struct {
+	DocumentTypeId int    `json:"document_type_id" form:"document_type_id"`
+	RowsLimit      uint   `json:"rows_limit" form:"rows_limit" validate:"required,min=1,max=500"`
+	RowsOffset     uint   `json:"rows_offset" form:"rows_offset"`
+	Cursor         string `json:"cursor" form:"cursor"`
+	Sort           string `json:"sort" form:"sort" validate:"omitempty,oneof=id -id retain_until -retain_until"`
+}
+
+// The statuses of a destruction act, in the order it goes through them. A
+// submitted act is pending until every agreement is decided: it is then
+// approved, or rejected as soon as one is refused.
+const (
+	ActDraft    = "draft"
+	ActPending  = "pending"
+	ActApproved = "approved"
+	ActRejected = "rejected"
+	ActExecuted = "executed"
+)
+
+// DestructionAct lists letters past their retention period to destroy.
+// Submitting it asks the department, usually the expert commission, to
+// agree to each letter; once approved, executing it removes the content
+// of the letters.
+type DestructionAct struct {
+	Id           int        `json:"id,omitempty"`
+	DepartmentId int        `json:"department_id" validate:"required,min=1"`
+	Status       string     `json:"status,omitempty"`
+	Note         string     `json:"note,omitempty"`
+	LetterIds    []int      `json:"letter_ids" validate:"required,min=1,unique,dive,min=1"`
+	CreatedBy    int        `json:"created_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at,omitempty"`
+	SubmittedAt  *time.Time `json:"submitted_at,omitempty"`
+	ExecutedAt   *time.Time `json:"executed_at,omitempty"`
+}
+
+// DestructionActFilter is the query of GET /api/v2/destruction-acts.
+type DestructionActFilter struct {
+	Status     string `json:"status" form:"status" validate:"omitempty,oneof=draft pending approved rejected executed"`
+	RowsLimit  uint   `json:"rows_limit" form:"rows_limit" validate:"required,min=1,max=500"`
+	RowsOffset uint   `json:"rows_offset" form:"rows_offset"`
+	Cursor     string `json:"cursor" form:"cursor"`
+	Sort       string `json:"sort" form:"sort" validate:"omitempty,oneof=id -id"`
+}
+
+// Case is a file of the nomenclature, the yearly list of cases of a
+// department, such as "01-05 Correspondence with ministries". Registered
+// letters are filed into open cases. Letters filed into a case are kept
+// RetentionYears after their entry date instead of the period of their
+// document type, or permanently when it is 0.
+type Case struct {
+	Id             int        `json:"id,omitempty"`
+	DepartmentId   int        `json:"department_id" validate:"required,min=1"`
+	Year           int        `json:"year" validate:"required,min=1900,max=2999"`
+	Index          string     `json:"index" validate:"required,max=32"`
+	Title          string     `json:"title" validate:"required,min=2"`
+	RetentionYears int        `json:"retention_years" validate:"min=0,max=100"`
+	Article        string     `json:"article,omitempty"`
+	CreatedAt      time.Time  `json:"created_at,omitempty"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+	// Letters counts the letters filed into the case, which were entered
+	// between FirstEntryDate and LastEntryDate.
+	Letters        int        `json:"letters"`
+	FirstEntryDate *time.Time `json:"first_entry_date,omitempty"`
+	LastEntryDate  *time.Time `json:"last_entry_date,omitempty"`
+}
+
+// CaseFilter is the query of GET /api/v2/cases and of the case inventory,
+// which lists every case with a rows_limit of 0.
+type CaseFilter struct {
+	DepartmentId int    `json:"department_id" form:"department_id"`
+	Year         int    `json:"year" form:"year"`
+	Index        string `json:"index" form:"index"`
+	RowsLimit    uint   `json:"rows_limit" form:"rows_limit" validate:"required,min=1,max=500"`
+	RowsOffset   uint   `json:"rows_offset" form:"rows_offset"`
+	Cursor       string `json:"cursor" form:"cursor"`
+	Sort         string `json:"sort" form:"sort" validate:"omitempty,oneof=id -id index -index"`
+}
+
+// CaseInventory is the query of GET /api/v2/cases/inventory/:format, which
+// lists the nomenclature of a department for a year.
+type CaseInventory struct {
+	DepartmentId int `json:"department_id" form:"department_id" validate:"required,min=1"`
+	Year         int `json:"year" form:"year" validate:"required,min=1900,max=2999"`
 }
@@ -0,0 +1,420 @@
+// Package openapi describes the HTTP API as an OpenAPI 3 document. Request
+// and response schemas are generated from the models, including their
+// validation rules, so that they cannot go stale; the routes are listed in
+// Routes, which a test keeps in line with the router.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// BearerAuth is the token returned by POST /login.
+	BearerAuth = "bearerAuth"
+	// MetricsAuth is the metrics.token setting.
+	MetricsAuth = "metricsToken"
+)
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower-case HTTP methods to operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationId string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Ref         string  `json:"$ref,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	In          string  `json:"in,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Parameters      map[string]*Parameter      `json:"parameters"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Route describes one route of the router.
+type Route struct {
+	Method string
+	// Path is the gin pattern, such as "/letter/:id".
+	Path        string
+	Id          string
+	Summary     string
+	Description string
+	Tag         string
+	// Security names the scheme protecting the route, if any.
+	Security string
+	// Probe marks routes registered before the legacy status and language
+	// middleware.
+	Probe bool
+	// Successor is the id of the operation replacing a legacy route, which
+	// is then marked deprecated.
+	Successor string
+	Query     []*Parameter
+	// Filter is a value of the model read from the query string by its
+	// form tags. Fields set in it are the defaults.
+	Filter interface{}
+	// Request is a value of the JSON body model, nil for routes without a
+	// body.
+	Request interface{}
+	// Files are the fields of a multipart body.
+	Files []string
+	// Versioned marks edits guarded by the version of the resource, which
+	// accept If-Match and report VERSION_REQUIRED and VERSION_CONFLICT.
+	Versioned bool
+	// Idempotent marks routes replaying their response to retries sending
+	// the same Idempotency-Key, which report IDEMPOTENCY_KEY_REUSED and
+	// IDEMPOTENCY_KEY_IN_USE.
+	Idempotent bool
+	// Status is the status of a successful response, 200 when unset.
+	Status int
+	// Payload is a value of the model sent as the payload of the response
+	// envelope, nil for responses without a payload.
+	Payload interface{}
+	// Errors are the catalog errors the route reports besides the ones
+	// every route of its kind can: INTERNAL, UNAUTHORIZED behind bearer
+	// authentication, MALFORMED_REQUEST for JSON bodies, MALFORMED_QUERY
+	// for filters and the errors of versioned and idempotent routes.
+	Errors []apperr.Code
+	// Also lists content types the route answers with instead of the
+	// envelope on request.
+	Also []string
+	// Raw is the content type of routes that do not answer with the
+	// envelope.
+	Raw string
+}
+
+// query describes a query parameter.
+func query(name, typ, description string) *Parameter {
+	return &Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: typ}}
+}
+
+// pathParameters describes every parameter used in route paths.
+var pathParameters = map[string]*Parameter{
+	"id": {Name: "id", In: "path", Required: true, Description: "Id of the resource.",
+		Schema: &Schema{Type: "integer"}},
+	"agree": {Name: "agree", In: "path", Required: true, Description: "Whether the department agrees.",
+		Schema: &Schema{Type: "boolean"}},
+	"format": {Name: "format", In: "path", Required: true, Description: "Format of the export; dot is only offered for the org chart.",
+		Schema: &Schema{Type: "string", Enum: []interface{}{"json", "dot", "csv"}}},
+	"file": {Name: "file", In: "path", Required: true, Description: "Asset of the Swagger UI.",
+		Schema: &Schema{Type: "string"}},
+}
+
+// headerParameters are understood by every route behind the legacy status
+// and language middleware, except If-Match and Idempotency-Key, which only
+// the routes marked for them read.
+var headerParameters = map[string]*Parameter{
+	"Accept-Language": {Name: "Accept-Language", In: "header",
+		Description: "Language of messages and labels: uz, ru or en. Users' own language setting takes precedence.",
+		Schema:      &Schema{Type: "string"}},
+	"X-Legacy-Status": {Name: "X-Legacy-Status", In: "header",
+		Description: "When true, every response has HTTP status 200 and the real status is only in the code field.",
+		Schema:      &Schema{Type: "boolean"}},
+	"If-Match": {Name: "If-Match", In: "header",
+		Description: "ETag of the version the edit is based on. Takes precedence over the version field of the body.",
+		Schema:      &Schema{Type: "string"}},
+	"Idempotency-Key": {Name: "Idempotency-Key", In: "header",
+		Description: "Unique key of the request. Retries with the same key and body get the status, Location and the id in the payload of the first response, with Idempotent-Replayed set, instead of running again.",
+		Schema:      &Schema{Type: "string"}},
+}
+
+const description = `Every JSON response is wrapped in the Response envelope. Its code
+field repeats the HTTP status; failed requests carry a machine-readable
+error code from the catalog and, for validation errors, one entry per
+field in details.`
+
+// Spec returns the document describing Routes.
+func Spec() Document {
+	return Build(Routes)
+}
+
+// Build returns the document describing routes. It panics on routes using
+// undescribed path parameters or repeating an operation id, which are
+// programming errors.
+func Build(routes []Route) Document {
+	doc := Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: "SED API", Description: description, Version: "1"},
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas:    map[string]*Schema{},
+			Parameters: map[string]*Parameter{},
+			SecuritySchemes: map[string]*SecurityScheme{
+				BearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+					Description: "Token returned by POST /login."},
+				MetricsAuth: {Type: "http", Scheme: "bearer",
+					Description: "The metrics.token setting."},
+			},
+		},
+	}
+
+	s := schemas(doc.Components.Schemas)
+	s.of(models.Response{})
+
+	ids := map[string]Route{}
+	for _, route := range routes {
+		if _, ok := ids[route.Id]; ok {
+			panic(fmt.Sprintf("openapi: duplicate operation id %q", route.Id))
+		}
+		ids[route.Id] = route
+	}
+
+	tags := map[string]bool{}
+
+	for _, route := range routes {
+		if route.Successor != "" {
+			successor, ok := ids[route.Successor]
+			if !ok {
+				panic(fmt.Sprintf("openapi: unknown successor %q of %q", route.Successor, route.Id))
+			}
+			route.Description = strings.TrimSpace(route.Description + " Superseded by " + successor.Method + " " + template(successor.Path) + ".")
+		}
+
+		if route.Tag != "" && !tags[route.Tag] {
+			tags[route.Tag] = true
+			doc.Tags = append(doc.Tags, Tag{Name: route.Tag})
+		}
+
+		path, operation := build(s, doc.Components.Parameters, route)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = operation
+	}
+
+	return doc
+}
+
+func build(s schemas, parameters map[string]*Parameter, route Route) (string, *Operation) {
+	operation := &Operation{
+		OperationId: route.Id,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   map[string]*Response{},
+	}
+
+	if route.Tag != "" {
+		operation.Tags = []string{route.Tag}
+	}
+
+	operation.Deprecated = route.Successor != ""
+
+	for _, segment := range strings.Split(route.Path, "/") {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+
+		name := segment[1:]
+		parameter, ok := pathParameters[name]
+		if !ok {
+			panic(fmt.Sprintf("openapi: undescribed path parameter %q in %s", name, route.Path))
+		}
+
+		parameters[name] = parameter
+		operation.Parameters = append(operation.Parameters, &Parameter{Ref: "#/components/parameters/" + name})
+	}
+	path := template(route.Path)
+
+	operation.Parameters = append(operation.Parameters, route.Query...)
+	if route.Filter != nil {
+		operation.Parameters = append(operation.Parameters, s.query(route.Filter)...)
+	}
+
+	if !route.Probe && route.Raw == "" {
+		for _, name := range []string{"Accept-Language", "X-Legacy-Status"} {
+			parameters[name] = headerParameters[name]
+			operation.Parameters = append(operation.Parameters, &Parameter{Ref: "#/components/parameters/" + name})
+		}
+	}
+	if route.Versioned {
+		parameters["If-Match"] = headerParameters["If-Match"]
+		operation.Parameters = append(operation.Parameters, &Parameter{Ref: "#/components/parameters/If-Match"})
+	}
+	if route.Idempotent {
+		parameters["Idempotency-Key"] = headerParameters["Idempotency-Key"]
+		operation.Parameters = append(operation.Parameters, &Parameter{Ref: "#/components/parameters/Idempotency-Key"})
+	}
+
+	if route.Security != "" {
+		operation.Security = []map[string][]string{{route.Security: {}}}
+	}
+
+	errors := []apperr.Code{apperr.Internal}
+	if route.Security == BearerAuth {
+		errors = append(errors, apperr.Unauthorized)
+	}
+	if route.Filter != nil {
+		errors = append(errors, apperr.MalformedQuery)
+	}
+	if route.Versioned {
+		errors = append(errors, apperr.VersionRequired, apperr.VersionConflict)
+	}
+	if route.Idempotent {
+		errors = append(errors, apperr.IdempotencyReused, apperr.IdempotencyInUse)
+	}
+
+	switch {
+	case route.Request != nil:
+		operation.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{
+			"application/json": {Schema: s.of(route.Request)},
+		}}
+		errors = append(errors, apperr.MalformedRequest)
+	case len(route.Files) > 0:
+		form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for _, file := range route.Files {
+			form.Properties[file] = &Schema{Type: "string", Format: "binary"}
+		}
+		operation.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{
+			"multipart/form-data": {Schema: form},
+		}}
+	}
+
+	if route.Raw != "" {
+		operation.Responses["200"] = &Response{Description: "OK", Content: map[string]*MediaType{
+			route.Raw: {Schema: &Schema{Type: "string"}},
+		}}
+		if route.Security != "" {
+			operation.Responses["401"] = &Response{Description: "Missing or invalid bearer token."}
+		}
+		return path, operation
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	success := &Response{Description: http.StatusText(status), Content: map[string]*MediaType{
+		"application/json": {Schema: envelope(s.of(route.Payload))},
+	}}
+	for _, content := range route.Also {
+		success.Content[content] = &MediaType{Schema: &Schema{Type: "string"}}
+	}
+	operation.Responses[strconv.Itoa(status)] = success
+
+	byStatus := map[int][]apperr.Code{}
+	for _, code := range append(errors, route.Errors...) {
+		status := apperr.New(code).Status
+		byStatus[status] = append(byStatus[status], code)
+	}
+
+	for status, codes := range byStatus {
+		sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+		names := make([]string, len(codes))
+		enum := make([]interface{}, len(codes))
+		for i, code := range codes {
+			names[i] = string(code)
+			enum[i] = string(code)
+		}
+
+		operation.Responses[strconv.Itoa(status)] = &Response{
+			Description: strings.Join(names, ", "),
+			Content: map[string]*MediaType{
+				"application/json": {Schema: &Schema{AllOf: []*Schema{
+					ref("Response"),
+					{Type: "object", Properties: map[string]*Schema{"error": {Type: "string", Enum: enum}}},
+				}}},
+			},
+		}
+	}
+
+	return path, operation
+}
+
+// template turns the gin parameters :name and *name of path into the
+// OpenAPI {name}.
+func template(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// envelope returns the schema of a Response carrying payload.
+func envelope(payload *Schema) *Schema {
+	if payload == nil {
+		return ref("Response")
+	}
+
+	return &Schema{AllOf: []*Schema{
+		ref("Response"),
+		{Type: "object", Properties: map[string]*Schema{"payload": payload}},
+	}}
+}
+
+// Handler serves doc as JSON.
+func Handler(doc Document) gin.HandlerFunc {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+	}
+}
@@ -0,0 +1,117 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sed/models"
+	"strings"
+	"testing"
+)
+
+func TestSchemaConstraints(t *testing.T) {
+	s := schemas{}
+	s.of(models.Letter{})
+	s.of(models.EmployeeFilter{})
+	s.of(models.Employee{})
+	s.of(models.DepartmentMerge{})
+
+	letter := s["Letter"]
+	if !reflect.DeepEqual(letter.Required, []string{"name", "sender", "document_type_id", "content"}) {
+		t.Errorf("Letter required %v", letter.Required)
+	}
+	if name := letter.Properties["name"]; name.Type != "string" || name.MinLength == nil || *name.MinLength != 2 {
+		t.Errorf("Letter.name %+v", name)
+	}
+	if entryDate := letter.Properties["entry_date"]; entryDate.Format != "date-time" {
+		t.Errorf("Letter.entry_date %+v", entryDate)
+	}
+	if documentType := letter.Properties["document_type"]; documentType.Ref != "#/components/schemas/DocumentType" {
+		t.Errorf("Letter.document_type %+v", documentType)
+	}
+
+	if limit := s["EmployeeFilter"].Properties["rows_limit"]; limit.Minimum == nil || *limit.Minimum != 1 {
+		t.Errorf("EmployeeFilter.rows_limit %+v", limit)
+	}
+
+	if language := s["Employee"].Properties["language"]; !reflect.DeepEqual(language.Enum, []interface{}{"uz", "ru", "en"}) {
+		t.Errorf("Employee.language %+v", language)
+	}
+	if _, ok := s["Employee"].Properties["Password"]; ok {
+		t.Error("fields are not named by their json tag")
+	}
+
+	if target := s["DepartmentMerge"].Properties["target_id"]; target.Description != "must differ from source_id" {
+		t.Errorf("DepartmentMerge.target_id %+v", target)
+	}
+
+	// Department and Employee refer to each other.
+	if head := s["Department"].Properties["head"]; len(head.AllOf) != 1 || !head.Nullable {
+		t.Errorf("Department.head %+v", head)
+	}
+}
+
+func TestSpec(t *testing.T) {
+	doc := Spec()
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operation := doc.Paths["/letter/{id}"]["post"]
+	if operation == nil {
+		t.Fatal("POST /letter/{id} is not described")
+	}
+	if operation.Parameters[0].Ref != "#/components/parameters/id" {
+		t.Errorf("parameters %+v", operation.Parameters)
+	}
+	for _, status := range []string{"200", "401", "404", "500"} {
+		if operation.Responses[status] == nil {
+			t.Errorf("no %s response", status)
+		}
+	}
+	if operation.Responses["404"].Description != "LETTER_NOT_FOUND" {
+		t.Errorf("404 %q", operation.Responses["404"].Description)
+	}
+
+	if !operation.Deprecated || !strings.HasSuffix(operation.Description, "Superseded by GET /api/v2/letters/{id}.") {
+		t.Errorf("legacy operation is not deprecated: %q", operation.Description)
+	}
+
+	list := doc.Paths["/api/v2/letters"]["get"]
+	for _, parameter := range list.Parameters {
+		if parameter.Name == "rows_limit" && parameter.Schema.Default != uint(20) {
+			t.Errorf("rows_limit default %v", parameter.Schema.Default)
+		}
+	}
+
+	patch := doc.Paths["/api/v2/letters/{id}"]["patch"]
+	if patch.Responses["412"] == nil || patch.Responses["428"] == nil {
+		t.Errorf("versioned edit responses %v", patch.Responses)
+	}
+	if last := patch.Parameters[len(patch.Parameters)-1]; last.Ref != "#/components/parameters/If-Match" {
+		t.Errorf("versioned edit parameters %+v", patch.Parameters)
+	}
+
+	for name, schema := range doc.Components.Schemas {
+		if schema.Type != "object" {
+			t.Errorf("component %s is %q", name, schema.Type)
+		}
+	}
+}
+
+func TestBuildRejectsUndescribedParameters(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+
+	Build([]Route{{Method: "GET", Path: "/letters/:letter", Id: "letter"}})
+}
@@ -0,0 +1,611 @@
+package openapi
+
+import (
+	"net/http"
+	"sed/apperr"
+	"sed/handlers"
+	"sed/models"
+)
+
+var (
+	archived = query("archived", "boolean", "List archived departments instead of active ones.")
+	dryRun   = query("dry_run", "boolean", "Only validate the files.")
+)
+
+// Routes lists every route the router registers.
+var Routes = []Route{
+	{
+		Method: "GET", Path: "/healthz", Id: "healthz", Tag: "System", Probe: true,
+		Summary: "Report that the process is alive",
+	},
+	{
+		Method: "GET", Path: "/readyz", Id: "readyz", Tag: "System", Probe: true,
+		Summary:     "Report whether the instance can serve traffic",
+		Description: "Checks that the database answers and every migration is applied. The payload is sent with NOT_READY too.",
+		Payload:     models.Readiness{},
+		Errors:      []apperr.Code{apperr.NotReady},
+	},
+	{
+		Method: "GET", Path: "/metrics", Id: "metrics", Tag: "System", Probe: true, Security: MetricsAuth,
+		Summary:     "Export Prometheus metrics",
+		Description: "Only registered when metrics.enabled is set.",
+		Raw:         "text/plain",
+	},
+	{
+		Method: "GET", Path: "/openapi.json", Id: "openapi", Tag: "System", Probe: true,
+		Summary: "Describe the API as an OpenAPI 3 document",
+		Raw:     "application/json",
+	},
+	{
+		Method: "GET", Path: "/docs/*file", Id: "docs", Tag: "System", Probe: true,
+		Summary:     "Browse this document in Swagger UI",
+		Description: "GET /docs/ opens the UI; the other files are its assets.",
+		Raw:         "text/html",
+	},
+	{
+		Method: "POST", Path: "/ping", Id: "ping", Tag: "System",
+		Summary: "Check that the API answers",
+	},
+	// The legacy v1 API.
+	{
+		Method: "POST", Path: "/login", Id: "legacyLogin", Tag: "Legacy", Successor: "createSession",
+		Summary: "Exchange email and password for a bearer token",
+		Request: models.Employee{},
+		Payload: models.Session{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCredentials},
+	},
+	{
+		Method: "POST", Path: "/letters", Id: "legacyListLetters", Tag: "Legacy", Successor: "listLetters", Security: BearerAuth,
+		Summary: "List letters matching a filter",
+		Request: models.LetterFilter{},
+		Payload: []models.Letter{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/letter/:id", Id: "legacyGetLetter", Tag: "Legacy", Successor: "getLetter", Security: BearerAuth,
+		Summary: "Get a letter",
+		Payload: models.Letter{},
+		Errors:  []apperr.Code{apperr.LetterNotFound},
+	},
+	{
+		Method: "POST", Path: "/letter", Id: "legacyCreateLetter", Tag: "Legacy", Successor: "createLetter", Security: BearerAuth, Idempotent: true,
+		Summary: "Register a letter",
+		Request: models.Letter{},
+		Payload: 0,
+		Errors:  []apperr.Code{apperr.ValidationFailed},
+	},
+	{
+		Method: "PUT", Path: "/letter", Id: "legacyEditLetter", Tag: "Legacy", Successor: "patchLetter", Security: BearerAuth, Versioned: true,
+		Summary: "Edit a letter",
+		Request: models.Letter{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.LetterNotFound, apperr.LetterDestroyed},
+	},
+	{
+		Method: "POST", Path: "/letters/types", Id: "legacyListLetterTypes", Tag: "Legacy", Successor: "listDocumentTypes", Security: BearerAuth,
+		Summary: "List document types",
+		Payload: []models.DocumentType{},
+	},
+	{
+		Method: "POST", Path: "/users", Id: "legacyListUsers", Tag: "Legacy", Successor: "listUsers", Security: BearerAuth,
+		Summary: "List employees matching a filter",
+		Request: models.EmployeeFilter{},
+		Payload: []models.Employee{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/users/:id", Id: "legacyGetUser", Tag: "Legacy", Successor: "getUser", Security: BearerAuth,
+		Summary:     "Get the profile of an employee",
+		Description: "Only admins may read profiles other than their own.",
+		Payload:     models.Employee{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.EmployeeNotFound},
+	},
+	{
+		Method: "PUT", Path: "/user", Id: "legacyEditUser", Tag: "Legacy", Successor: "patchUser", Security: BearerAuth,
+		Summary:     "Edit an employee",
+		Description: "Admins only. Without an id the caller is edited.",
+		Request:     models.Employee{},
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.Forbidden, apperr.EmployeeNotFound,
+			apperr.DepartmentNotFound, apperr.DepartmentArchived},
+	},
+	{
+		Method: "POST", Path: "/users/:id/transfers", Id: "legacyListUserTransfers", Tag: "Legacy", Successor: "listUserTransfers", Security: BearerAuth,
+		Summary:     "List the department transfers of an employee",
+		Description: "Admins see everyone's transfers, other employees only their own.",
+		Payload:     []models.EmployeeTransfer{},
+		Errors:      []apperr.Code{apperr.Forbidden},
+	},
+	{
+		Method: "POST", Path: "/roles", Id: "legacyListRoles", Tag: "Legacy", Successor: "listRoles", Security: BearerAuth,
+		Summary: "List role groups",
+		Filter:  models.RoleFilter{},
+		Payload: []models.RoleGroup{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/departments", Id: "legacyListDepartments", Tag: "Legacy", Successor: "listDepartments", Security: BearerAuth,
+		Summary: "List departments",
+		Filter:  models.DepartmentFilter{},
+		Payload: []models.Department{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/department", Id: "legacyCreateDepartment", Tag: "Legacy", Successor: "createDepartment", Security: BearerAuth,
+		Summary:     "Create a department",
+		Description: "Admins only.",
+		Request:     models.Department{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.DepartmentNotFound, apperr.DepartmentArchived},
+	},
+	{
+		Method: "PUT", Path: "/department", Id: "legacyEditDepartment", Tag: "Legacy", Successor: "patchDepartment", Security: BearerAuth, Versioned: true,
+		Summary:     "Edit a department",
+		Description: "Admins only.",
+		Request:     models.Department{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.DepartmentNotFound},
+	},
+	{
+		Method: "PUT", Path: "/department/move", Id: "legacyMoveDepartment", Tag: "Legacy", Successor: "setDepartmentParent", Security: BearerAuth,
+		Summary:     "Move a department under another parent",
+		Description: "Admins only. Send id and parent_id; a parent_id of 0 makes the department a root.",
+		Request:     models.Department{},
+		Errors: []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.DepartmentNotFound, apperr.DepartmentArchived,
+			apperr.DepartmentCycle},
+	},
+	{
+		Method: "PUT", Path: "/department/heads", Id: "legacyAssignDepartmentHeads", Tag: "Legacy", Successor: "setDepartmentHeads", Security: BearerAuth,
+		Summary:     "Assign the head and deputy of a department",
+		Description: "Admins only. Send id, head_id and deputy_id; both must be staff of the department.",
+		Request:     models.Department{},
+		Errors: []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.EmployeeNotFound, apperr.NotDepartmentStaff,
+			apperr.DepartmentNotFound},
+	},
+	{
+		Method: "PUT", Path: "/department/archive", Id: "legacyArchiveDepartment", Tag: "Legacy", Successor: "deleteDepartment", Security: BearerAuth,
+		Summary:     "Archive an empty department",
+		Description: "Admins only. Send id.",
+		Request:     models.Department{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.DepartmentNotEmpty, apperr.DepartmentNotFound},
+	},
+	{
+		Method: "PUT", Path: "/department/merge", Id: "legacyMergeDepartment", Tag: "Legacy", Successor: "mergeDepartment", Security: BearerAuth,
+		Summary:     "Merge a department into another",
+		Description: "Admins only. Moves the employees, sub-departments and open assignments of the source to the target and archives the source.",
+		Request:     models.DepartmentMerge{},
+		Errors: []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.DepartmentNotFound, apperr.DepartmentArchived,
+			apperr.DepartmentCycle},
+	},
+	{
+		Method: "POST", Path: "/departments/tree", Id: "legacyDepartmentTree", Tag: "Legacy", Successor: "departmentTree", Security: BearerAuth,
+		Summary: "List departments nested by parent",
+		Query:   []*Parameter{archived},
+		Payload: []models.Department{},
+	},
+	{
+		Method: "POST", Path: "/departments/chart/:format", Id: "legacyExportOrgChart", Tag: "Legacy", Successor: "exportOrgChart", Security: BearerAuth,
+		Summary:     "Export the org chart",
+		Description: "json answers with the envelope, dot and csv with a file download.",
+		Payload:     []models.Department{},
+		Also:        []string{"text/vnd.graphviz", "text/csv"},
+		Errors:      []apperr.Code{apperr.UnsupportedFormat},
+	},
+	{
+		Method: "POST", Path: "/departments/:id/users", Id: "legacyListDepartmentUsers", Tag: "Legacy", Successor: "listDepartmentUsers", Security: BearerAuth,
+		Summary: "List the employees of a department",
+		Filter:  models.DepartmentEmployeeFilter{},
+		Payload: []models.Employee{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor, apperr.DepartmentNotFound},
+	},
+	{
+		Method: "POST", Path: "/import", Id: "legacyImportStaff", Tag: "Legacy", Successor: "importStaff", Security: BearerAuth,
+		Summary:     "Import departments and employees from spreadsheets",
+		Description: "Admins only. Accepts xlsx or csv files; the import is applied atomically or not at all.",
+		Query:       []*Parameter{dryRun},
+		Files:       []string{"departments", "employees"},
+		Payload:     models.ImportReport{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.UnsupportedFormat, apperr.ImportFileInvalid, apperr.ImportRejected},
+	},
+	{
+		Method: "POST", Path: "/letters/describe", Id: "legacyDescribeLetter", Tag: "Legacy", Successor: "assignLetter", Security: BearerAuth,
+		Summary: "Assign a letter to a department and executive",
+		Request: models.DescribedLetter{},
+		Errors:  []apperr.Code{apperr.DepartmentNotFound, apperr.DepartmentArchived, apperr.LetterNotFound},
+	},
+	{
+		Method: "POST", Path: "/letters/agreements", Id: "legacyListAgreements", Tag: "Legacy", Successor: "listAgreements", Security: BearerAuth,
+		Summary: "List the agreements waiting in the caller's inbox",
+		Filter:  models.AgreementFilter{},
+		Payload: []models.Agreement{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/letters/agreement", Id: "legacyCreateAgreement", Tag: "Legacy", Successor: "createAgreement", Security: BearerAuth, Idempotent: true,
+		Summary: "Send a letter to a department for agreement",
+		Request: models.Agreement{},
+		Errors:  []apperr.Code{apperr.DepartmentNotFound, apperr.DepartmentArchived, apperr.LetterNotFound},
+	},
+	{
+		Method: "POST", Path: "/letters/agreement/:id", Id: "legacyGetAgreement", Tag: "Legacy", Successor: "getAgreement", Security: BearerAuth,
+		Summary:     "Get an agreement and mark it viewed",
+		Description: "Only admins and the heads and deputies whose inbox holds the agreement read it. The v2 GET only reads the agreement; a PATCH with viewed true marks it viewed.",
+		Payload:     models.Agreement{},
+		Errors:      []apperr.Code{apperr.AgreementNotFound, apperr.Forbidden},
+	},
+	{
+		Method: "POST", Path: "/letters/agreement/:id/:agree", Id: "legacyDecideAgreement", Tag: "Legacy", Successor: "decideAgreement", Security: BearerAuth,
+		Summary:     "Agree or reject",
+		Description: "Only the heads and deputies whose inbox holds the agreement decide it, once.",
+		Errors:      []apperr.Code{apperr.AgreementNotFound, apperr.Forbidden, apperr.AgreementDecided},
+	},
+
+	// The v2 API.
+	{
+		Method: "POST", Path: "/api/v2/sessions", Id: "createSession", Tag: "Users",
+		Summary: "Exchange email and password for a bearer token",
+		Request: models.Employee{},
+		Payload: models.Session{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCredentials},
+	},
+	{
+		Method: "GET", Path: "/api/v2/letters", Id: "listLetters", Tag: "Letters", Security: BearerAuth,
+		Summary: "List letters matching a filter",
+		Filter:  models.LetterFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload: []models.Letter{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/api/v2/letters", Id: "createLetter", Tag: "Letters", Security: BearerAuth, Idempotent: true,
+		Summary: "Register a letter",
+		Request: models.Letter{},
+		Status:  http.StatusCreated,
+		Payload: models.Letter{},
+		Errors:  []apperr.Code{apperr.ValidationFailed},
+	},
+	{
+		Method: "GET", Path: "/api/v2/letters/:id", Id: "getLetter", Tag: "Letters", Security: BearerAuth,
+		Summary: "Get a letter",
+		Payload: models.Letter{},
+		Errors:  []apperr.Code{apperr.LetterNotFound},
+	},
+	{
+		Method: "PATCH", Path: "/api/v2/letters/:id", Id: "patchLetter", Tag: "Letters", Security: BearerAuth, Versioned: true,
+		Summary:     "Edit a letter",
+		Description: "Only the fields present in the body change.",
+		Request:     models.Letter{},
+		Payload:     models.Letter{},
+		Errors:      []apperr.Code{apperr.ValidationFailed, apperr.LetterNotFound, apperr.LetterDestroyed},
+	},
+	{
+		Method: "DELETE", Path: "/api/v2/letters/:id", Id: "deleteLetter", Tag: "Letters", Security: BearerAuth,
+		Summary:     "Move a letter to the trash",
+		Description: "Admins only. The letter leaves lists, searches and agreement inboxes until an admin restores it.",
+		Request:     models.LetterDeletion{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.LetterNotFound},
+	},
+	{
+		Method: "POST", Path: "/api/v2/letters/:id/assignments", Id: "assignLetter", Tag: "Letters", Security: BearerAuth,
+		Summary: "Assign a letter to a department and executive",
+		Request: models.DescribedLetter{},
+		Status:  http.StatusCreated,
+		Errors:  []apperr.Code{apperr.DepartmentNotFound, apperr.DepartmentArchived, apperr.LetterNotFound},
+	},
+	{
+		Method: "PUT", Path: "/api/v2/letters/:id/case", Id: "fileLetter", Tag: "Cases", Security: BearerAuth,
+		Summary:     "File a letter into a case",
+		Description: "Admins and the staff of the department of the case only. The case must be open and of the year the letter was registered in; a letter in a closed case stays there.",
+		Request:     models.LetterFiling{},
+		Payload:     models.Letter{},
+		Errors: []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.LetterNotFound, apperr.LetterDestroyed, apperr.CaseNotFound,
+			apperr.CaseClosed, apperr.CaseYearMismatch},
+	},
+	{
+		Method: "GET", Path: "/api/v2/trash/letters", Id: "listTrash", Tag: "Trash", Security: BearerAuth,
+		Summary:     "List the letters in the trash",
+		Description: "Admins only. Most recently deleted first by default.",
+		Filter:      models.TrashFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload:     []models.Letter{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/api/v2/trash/letters/:id/restore", Id: "restoreLetter", Tag: "Trash", Security: BearerAuth,
+		Summary:     "Restore a letter from the trash",
+		Description: "Admins only.",
+		Payload:     models.Letter{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.LetterNotFound},
+	},
+	{
+		Method: "DELETE", Path: "/api/v2/trash/letters/:id", Id: "purgeLetter", Tag: "Trash", Security: BearerAuth,
+		Summary:     "Purge a letter from the trash",
+		Description: "Admins only. Allowed once the letter has been in the trash for the retention period and no destruction act lists it; the audit log keeps it.",
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.LetterNotFound, apperr.LetterRetained, apperr.LetterInAct},
+	},
+	{
+		Method: "GET", Path: "/api/v2/document-types", Id: "listDocumentTypes", Tag: "Letters", Security: BearerAuth,
+		Summary: "List document types",
+		Payload: []models.DocumentType{},
+	},
+	{
+		Method: "PUT", Path: "/api/v2/document-types/:id/retention", Id: "setRetentionRule", Tag: "Retention", Security: BearerAuth,
+		Summary:     "Set the retention period of a document type",
+		Description: "Admins only. Letters of the type may be destroyed that many years after their entry date, unless the case they are filed into sets another period.",
+		Request:     models.RetentionRule{},
+		Payload:     models.RetentionRule{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.DocumentTypeNotFound},
+	},
+	{
+		Method: "DELETE", Path: "/api/v2/document-types/:id/retention", Id: "deleteRetentionRule", Tag: "Retention", Security: BearerAuth,
+		Summary:     "Keep letters of a document type indefinitely",
+		Description: "Admins only.",
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.RetentionRuleNotFound},
+	},
+	{
+		Method: "GET", Path: "/api/v2/retention-rules", Id: "listRetentionRules", Tag: "Retention", Security: BearerAuth,
+		Summary: "List the retention periods of the document types",
+		Payload: []models.RetentionRule{},
+	},
+	{
+		Method: "GET", Path: "/api/v2/retention/overdue", Id: "listOverdueLetters", Tag: "Retention", Security: BearerAuth,
+		Summary:     "List the letters past their retention period",
+		Description: "Admins only. Letters already destroyed or in an open destruction act are left out. The longest overdue first by default.",
+		Filter:      models.RetentionFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload:     []models.Letter{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "GET", Path: "/api/v2/destruction-acts", Id: "listDestructionActs", Tag: "Retention", Security: BearerAuth,
+		Summary:     "List the destruction acts",
+		Description: "Admins only.",
+		Filter:      models.DestructionActFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload:     []models.DestructionAct{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/api/v2/destruction-acts", Id: "createDestructionAct", Tag: "Retention", Security: BearerAuth,
+		Summary:     "Draft a destruction act",
+		Description: "Admins only. Every letter must be past its retention period and in no other open act.",
+		Request:     models.DestructionAct{},
+		Payload:     models.DestructionAct{},
+		Status:      http.StatusCreated,
+		Errors: []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.DepartmentNotFound, apperr.DepartmentArchived,
+			apperr.LetterNotDue},
+	},
+	{
+		Method: "GET", Path: "/api/v2/destruction-acts/:id", Id: "getDestructionAct", Tag: "Retention", Security: BearerAuth,
+		Summary:     "Get a destruction act",
+		Description: "Admins only.",
+		Payload:     models.DestructionAct{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.DestructionActNotFound},
+	},
+	{
+		Method: "POST", Path: "/api/v2/destruction-acts/:id/submit", Id: "submitDestructionAct", Tag: "Retention", Security: BearerAuth,
+		Summary:     "Submit a draft destruction act for approval",
+		Description: "Admins only. The department gets an agreement on each letter; the act is approved once all are agreed and rejected as soon as one is refused.",
+		Payload:     models.DestructionAct{},
+		Errors: []apperr.Code{apperr.Forbidden, apperr.DestructionActNotFound, apperr.DestructionActStatus,
+			apperr.DepartmentNotFound, apperr.DepartmentArchived},
+	},
+	{
+		Method: "POST", Path: "/api/v2/destruction-acts/:id/execute", Id: "executeDestructionAct", Tag: "Retention", Security: BearerAuth,
+		Summary:     "Destroy the letters of an approved act",
+		Description: "Admins only. The content of the letters is removed; their registration data stays as a stub.",
+		Payload:     models.DestructionAct{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.DestructionActNotFound, apperr.DestructionActStatus},
+	},
+	{
+		Method: "GET", Path: "/api/v2/cases", Id: "listCases", Tag: "Cases", Security: BearerAuth,
+		Summary: "List the cases of the nomenclature",
+		Filter:  models.CaseFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload: []models.Case{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/api/v2/cases", Id: "createCase", Tag: "Cases", Security: BearerAuth,
+		Summary:     "Add a case to the nomenclature of a department",
+		Description: "Admins only. Indexes are unique per department and year. Letters filed into the case are kept retention_years after their entry date, or permanently for 0.",
+		Request:     models.Case{},
+		Payload:     models.Case{},
+		Status:      http.StatusCreated,
+		Errors: []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.DepartmentNotFound, apperr.DepartmentArchived,
+			apperr.CaseIndexTaken},
+	},
+	{
+		Method: "GET", Path: "/api/v2/cases/inventory/:format", Id: "exportCaseInventory", Tag: "Cases", Security: BearerAuth,
+		Summary:     "Export the case inventory of a department for a year",
+		Description: "Cases in index order with the number and entry dates of their letters. json answers with the envelope, csv with a file download.",
+		Filter:      models.CaseInventory{},
+		Payload:     []models.Case{},
+		Also:        []string{"text/csv"},
+		Errors:      []apperr.Code{apperr.UnsupportedFormat, apperr.ValidationFailed, apperr.DepartmentNotFound},
+	},
+	{
+		Method: "GET", Path: "/api/v2/cases/:id", Id: "getCase", Tag: "Cases", Security: BearerAuth,
+		Summary: "Get a case",
+		Payload: models.Case{},
+		Errors:  []apperr.Code{apperr.CaseNotFound},
+	},
+	{
+		Method: "POST", Path: "/api/v2/cases/:id/close", Id: "closeCase", Tag: "Cases", Security: BearerAuth,
+		Summary:     "Close a case",
+		Description: "Admins only. No letter is filed into or out of a closed case.",
+		Payload:     models.Case{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.CaseNotFound},
+	},
+	{
+		Method: "POST", Path: "/api/v2/cases/:id/open", Id: "openCase", Tag: "Cases", Security: BearerAuth,
+		Summary:     "Reopen a closed case",
+		Description: "Admins only.",
+		Payload:     models.Case{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.CaseNotFound},
+	},
+	{
+		Method: "GET", Path: "/api/v2/users", Id: "listUsers", Tag: "Users", Security: BearerAuth,
+		Summary: "List employees matching a filter",
+		Filter:  models.EmployeeFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload: []models.Employee{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "GET", Path: "/api/v2/users/:id", Id: "getUser", Tag: "Users", Security: BearerAuth,
+		Summary:     "Get the profile of an employee",
+		Description: "Only admins may read profiles other than their own.",
+		Payload:     models.Employee{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.EmployeeNotFound},
+	},
+	{
+		Method: "PATCH", Path: "/api/v2/users/:id", Id: "patchUser", Tag: "Users", Security: BearerAuth,
+		Summary:     "Edit an employee",
+		Description: "Admins only. Only the fields present in the body change.",
+		Request:     models.Employee{},
+		Payload:     models.Employee{},
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.Forbidden, apperr.EmployeeNotFound,
+			apperr.DepartmentNotFound, apperr.DepartmentArchived},
+	},
+	{
+		Method: "GET", Path: "/api/v2/users/:id/transfers", Id: "listUserTransfers", Tag: "Users", Security: BearerAuth,
+		Summary:     "List the department transfers of an employee",
+		Description: "Admins see everyone's transfers, other employees only their own.",
+		Payload:     []models.EmployeeTransfer{},
+		Errors:      []apperr.Code{apperr.Forbidden},
+	},
+	{
+		Method: "GET", Path: "/api/v2/roles", Id: "listRoles", Tag: "Users", Security: BearerAuth,
+		Summary: "List role groups",
+		Filter:  models.RoleFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload: []models.RoleGroup{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "GET", Path: "/api/v2/departments", Id: "listDepartments", Tag: "Departments", Security: BearerAuth,
+		Summary: "List departments",
+		Filter:  models.DepartmentFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload: []models.Department{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/api/v2/departments", Id: "createDepartment", Tag: "Departments", Security: BearerAuth,
+		Summary:     "Create a department",
+		Description: "Admins only.",
+		Request:     models.Department{},
+		Status:      http.StatusCreated,
+		Payload:     models.Department{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.DepartmentNotFound, apperr.DepartmentArchived},
+	},
+	{
+		Method: "GET", Path: "/api/v2/departments/tree", Id: "departmentTree", Tag: "Departments", Security: BearerAuth,
+		Summary: "List departments nested by parent",
+		Query:   []*Parameter{archived},
+		Payload: []models.Department{},
+	},
+	{
+		Method: "GET", Path: "/api/v2/departments/chart/:format", Id: "exportOrgChart", Tag: "Departments", Security: BearerAuth,
+		Summary:     "Export the org chart",
+		Description: "json answers with the envelope, dot and csv with a file download.",
+		Payload:     []models.Department{},
+		Also:        []string{"text/vnd.graphviz", "text/csv"},
+		Errors:      []apperr.Code{apperr.UnsupportedFormat},
+	},
+	{
+		Method: "GET", Path: "/api/v2/departments/:id", Id: "getDepartment", Tag: "Departments", Security: BearerAuth,
+		Summary: "Get a department",
+		Payload: models.Department{},
+		Errors:  []apperr.Code{apperr.DepartmentNotFound},
+	},
+	{
+		Method: "PATCH", Path: "/api/v2/departments/:id", Id: "patchDepartment", Tag: "Departments", Security: BearerAuth, Versioned: true,
+		Summary:     "Edit a department",
+		Description: "Admins only. Changes the name, internal number and phone present in the body. The parent and heads are set through their own resources.",
+		Request:     models.Department{},
+		Payload:     models.Department{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.DepartmentNotFound},
+	},
+	{
+		Method: "DELETE", Path: "/api/v2/departments/:id", Id: "deleteDepartment", Tag: "Departments", Security: BearerAuth,
+		Summary:     "Archive an empty department",
+		Description: "Admins only. Departments with employees or active sub-departments have to be merged instead.",
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.DepartmentNotEmpty, apperr.DepartmentNotFound},
+	},
+	{
+		Method: "PUT", Path: "/api/v2/departments/:id/parent", Id: "setDepartmentParent", Tag: "Departments", Security: BearerAuth,
+		Summary:     "Move a department under another parent",
+		Description: "Admins only.",
+		Request:     models.DepartmentParent{},
+		Payload:     models.Department{},
+		Errors: []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.DepartmentNotFound, apperr.DepartmentArchived,
+			apperr.DepartmentCycle},
+	},
+	{
+		Method: "PUT", Path: "/api/v2/departments/:id/heads", Id: "setDepartmentHeads", Tag: "Departments", Security: BearerAuth,
+		Summary:     "Assign the head and deputy of a department",
+		Description: "Admins only. Both must be staff of the department; 0 leaves the position empty.",
+		Request:     models.DepartmentHeads{},
+		Payload:     models.Department{},
+		Errors: []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.EmployeeNotFound, apperr.NotDepartmentStaff,
+			apperr.DepartmentNotFound},
+	},
+	{
+		Method: "POST", Path: "/api/v2/departments/:id/merge", Id: "mergeDepartment", Tag: "Departments", Security: BearerAuth,
+		Summary:     "Merge a department into another",
+		Description: "Admins only. Moves the employees, sub-departments and open assignments of the department to the target and archives it.",
+		Request:     models.DepartmentMergeTarget{},
+		Errors: []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.DepartmentNotFound, apperr.DepartmentArchived,
+			apperr.DepartmentCycle},
+	},
+	{
+		Method: "GET", Path: "/api/v2/departments/:id/users", Id: "listDepartmentUsers", Tag: "Departments", Security: BearerAuth,
+		Summary: "List the employees of a department",
+		Filter:  models.DepartmentEmployeeFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload: []models.Employee{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor, apperr.DepartmentNotFound},
+	},
+	{
+		Method: "POST", Path: "/api/v2/imports", Id: "importStaff", Tag: "Departments", Security: BearerAuth,
+		Summary:     "Import departments and employees from spreadsheets",
+		Description: "Admins only. Accepts xlsx or csv files; the import is applied atomically or not at all.",
+		Query:       []*Parameter{dryRun},
+		Files:       []string{"departments", "employees"},
+		Payload:     models.ImportReport{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.UnsupportedFormat, apperr.ImportFileInvalid, apperr.ImportRejected},
+	},
+	{
+		Method: "GET", Path: "/api/v2/agreements", Id: "listAgreements", Tag: "Agreements", Security: BearerAuth,
+		Summary: "List the agreements waiting in the caller's inbox",
+		Filter:  models.AgreementFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload: []models.Agreement{},
+		Errors:  []apperr.Code{apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "POST", Path: "/api/v2/agreements", Id: "createAgreement", Tag: "Agreements", Security: BearerAuth, Idempotent: true,
+		Summary: "Send a letter to a department for agreement",
+		Request: models.Agreement{},
+		Status:  http.StatusCreated,
+		Errors:  []apperr.Code{apperr.DepartmentNotFound, apperr.DepartmentArchived, apperr.LetterNotFound},
+	},
+	{
+		Method: "GET", Path: "/api/v2/agreements/:id", Id: "getAgreement", Tag: "Agreements", Security: BearerAuth,
+		Summary:     "Get an agreement",
+		Description: "Only admins and the heads and deputies whose inbox holds the agreement read it.",
+		Payload:     models.Agreement{},
+		Errors:      []apperr.Code{apperr.AgreementNotFound, apperr.Forbidden},
+	},
+	{
+		Method: "PATCH", Path: "/api/v2/agreements/:id", Id: "decideAgreement", Tag: "Agreements", Security: BearerAuth,
+		Summary:     "Mark an agreement viewed, agree or reject",
+		Description: "viewed true marks the agreement viewed, for admins and the heads and deputies whose inbox holds it. Only the heads and deputies whose inbox holds the agreement decide it, once.",
+		Request:     models.AgreementDecision{},
+		Payload:     models.Agreement{},
+		Errors:      []apperr.Code{apperr.ValidationFailed, apperr.AgreementNotFound, apperr.Forbidden, apperr.AgreementDecided},
+	},
+	{
+		Method: "GET", Path: "/api/v2/audit", Id: "listAudit", Tag: "Audit", Security: BearerAuth,
+		Summary:     "List the audit log",
+		Description: "Admins only. Every change of letters, employees, departments and agreements, with the fields that changed.",
+		Filter:      models.AuditFilter{RowsLimit: handlers.DefaultRowsLimit},
+		Payload:     []models.AuditEntry{},
+		Errors:      []apperr.Code{apperr.Forbidden, apperr.ValidationFailed, apperr.InvalidCursor},
+	},
+	{
+		Method: "GET", Path: "/api/v2/audit/verify", Id: "verifyAudit", Tag: "Audit", Security: BearerAuth,
+		Summary:     "Check the hash chain of the audit log",
+		Description: "Admins only. Reports the first entry that was changed or follows a removed one.",
+		Payload:     models.AuditVerification{},
+		Errors:      []apperr.Code{apperr.Forbidden},
+	},
+}
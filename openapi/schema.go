@@ -0,0 +1,248 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI 3.0 schema object, limited to the keywords the
+// models need.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *uint64            `json:"minLength,omitempty"`
+	MaxLength            *uint64            `json:"maxLength,omitempty"`
+	MinItems             *uint64            `json:"minItems,omitempty"`
+	MaxItems             *uint64            `json:"maxItems,omitempty"`
+	// Validate is the validate tag of the field as the server applies it,
+	// for rules that have no OpenAPI keyword.
+	Validate string `json:"x-validate,omitempty"`
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemas generates schemas from Go types the way encoding/json marshals
+// them. Named structs are collected as components and referenced, which
+// also handles types that refer to each other.
+type schemas map[string]*Schema
+
+// of returns the schema of the type of v, or nil for a nil v.
+func (s schemas) of(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s schemas) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schema(t.Elem())
+		if schema.Ref != "" {
+			return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		if _, ok := s[t.Name()]; !ok {
+			// Registered before the fields are walked, so that recursion
+			// ends at the reference.
+			s[t.Name()] = &Schema{}
+			*s[t.Name()] = *s.object(t)
+		}
+		return ref(t.Name())
+	default:
+		// interface{}: any value.
+		return &Schema{}
+	}
+}
+
+func (s schemas) object(t reflect.Type) *Schema {
+	object := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+
+		property := s.schema(field.Type)
+		if tag := field.Tag.Get("validate"); tag != "" {
+			if property.Ref != "" {
+				property = &Schema{AllOf: []*Schema{property}}
+			}
+			if constrain(property, t, tag) {
+				object.Required = append(object.Required, name)
+			}
+		}
+
+		object.Properties[name] = property
+	}
+
+	return object
+}
+
+// query describes the fields of filter with a form tag as query
+// parameters. Their values in filter are the defaults.
+func (s schemas) query(filter interface{}) (parameters []*Parameter) {
+	value := reflect.ValueOf(filter)
+	t := value.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("form")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		schema := s.schema(field.Type)
+		if tag := field.Tag.Get("validate"); tag != "" {
+			constrain(schema, t, tag)
+		}
+		if !value.Field(i).IsZero() {
+			schema.Default = value.Field(i).Interface()
+		}
+
+		parameters = append(parameters, &Parameter{Name: name, In: "query", Schema: schema})
+	}
+
+	return parameters
+}
+
+// constrain maps the validate rules of a field onto schema and reports
+// whether the field is required.
+func constrain(schema *Schema, parent reflect.Type, tag string) (required bool) {
+	schema.Validate = tag
+
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			break
+		}
+
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, value)
+			}
+		case "min", "max":
+			limit(schema, name, param)
+		case "nefield", "eqfield":
+			if field, ok := parent.FieldByName(param); ok {
+				relation := "must differ from "
+				if name == "eqfield" {
+					relation = "must equal "
+				}
+				schema.Description = relation + jsonName(field)
+			}
+		}
+	}
+
+	return required
+}
+
+// limit sets the bound the min or max rule puts on the length of strings
+// and arrays or on the value of numbers.
+func limit(schema *Schema, rule, param string) {
+	switch schema.Type {
+	case "string", "array":
+		n, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return
+		}
+		switch {
+		case schema.Type == "string" && rule == "min":
+			schema.MinLength = &n
+		case schema.Type == "string":
+			schema.MaxLength = &n
+		case rule == "min":
+			schema.MinItems = &n
+		default:
+			schema.MaxItems = &n
+		}
+	case "integer", "number":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		if rule == "min" {
+			schema.Minimum = &n
+		} else {
+			schema.Maximum = &n
+		}
+	}
+}
+
+// jsonName returns the name encoding/json uses for field, or "" when the
+// field is not marshalled.
+func jsonName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+func float(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,38 @@
+package openapi
+
+import (
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"net/http"
+)
+
+// initializer replaces the one shipped with Swagger UI, which loads the
+// petstore example.
+const initializer = `window.onload = function () {
+  window.ui = SwaggerUIBundle({
+    url: "../openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// UI serves the embedded Swagger UI for the document at /openapi.json. It
+// must be registered with a *file wildcard as the last path segment.
+func UI() gin.HandlerFunc {
+	files := http.FileServer(http.FS(swaggerFiles.FS))
+
+	return func(c *gin.Context) {
+		file := c.Param("file")
+		if file == "/swagger-initializer.js" {
+			c.Data(http.StatusOK, "application/javascript; charset=utf-8", []byte(initializer))
+			return
+		}
+
+		request := c.Request.Clone(c.Request.Context())
+		request.URL.Path = file
+		files.ServeHTTP(c.Writer, request)
+	}
+}
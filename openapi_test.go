@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPIMatchesRoutes fails when a route is registered without being
+// described in the OpenAPI document or the other way round.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	settings := testSettings()
+	settings.Metrics.Enabled = true
+	settings.Metrics.Token = metricsToken
+
+	s := newTestServer(t)
+	s.router = newTestRouter(s.store.Repositories(), settings)
+
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d", recorder.Code)
+	}
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi %q", spec.OpenAPI)
+	}
+
+	documented := map[string]bool{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	registered := map[string]bool{}
+	for _, route := range s.router.Routes() {
+		segments := strings.Split(route.Path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+				segments[i] = "{" + segment[1:] + "}"
+			}
+		}
+		registered[route.Method+" "+strings.Join(segments, "/")] = true
+	}
+
+	var undocumented, unregistered []string
+	for route := range registered {
+		if !documented[route] {
+			undocumented = append(undocumented, route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			unregistered = append(unregistered, route)
+		}
+	}
+	sort.Strings(undocumented)
+	sort.Strings(unregistered)
+
+	if len(undocumented) > 0 {
+		t.Errorf("routes missing from openapi.Routes: %v", undocumented)
+	}
+	if len(unregistered) > 0 {
+		t.Errorf("openapi.Routes describes unregistered routes: %v", unregistered)
+	}
+}
+
+func TestSwaggerUI(t *testing.T) {
+	s := newTestServer(t)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		s.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	if recorder := get("/docs/"); recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "swagger-ui") {
+		t.Errorf("GET /docs/: status %d: %.200s", recorder.Code, recorder.Body.String())
+	}
+
+	if recorder := get("/docs/swagger-initializer.js"); !strings.Contains(recorder.Body.String(), "../openapi.json") {
+		t.Errorf("initializer does not load our document: %s", recorder.Body.String())
+	}
+
+	if recorder := get("/docs/swagger-ui-bundle.js"); recorder.Code != http.StatusOK || recorder.Body.Len() == 0 {
+		t.Errorf("GET /docs/swagger-ui-bundle.js: status %d", recorder.Code)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sed/apperr"
+	"sed/models"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLetterPagination(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	for _, name := range []string{"Delta", "Alpha", "Echo", "Charlie", "Bravo"} {
+		s.letter(token, name)
+	}
+
+	var (
+		names  []string
+		cursor string
+		pages  int
+	)
+	for {
+		response := s.do(http.MethodGet, "/api/v2/letters?sort=name&rows_limit=2&cursor="+url.QueryEscape(cursor), token, nil)
+
+		var letters []models.Letter
+		s.ok(response, &letters)
+		for _, letter := range letters {
+			names = append(names, letter.Name)
+		}
+		pages++
+
+		if response.Page == nil || response.Page.Total != 5 {
+			t.Fatalf("page %+v", response.Page)
+		}
+		if response.Page.NextCursor == "" {
+			break
+		}
+		cursor = response.Page.NextCursor
+	}
+
+	if pages != 3 || strings.Join(names, " ") != "Alpha Bravo Charlie Delta Echo" {
+		t.Errorf("%d pages of %v", pages, names)
+	}
+
+	response := s.do(http.MethodGet, "/api/v2/letters?sort=-name&rows_limit=2&rows_offset=1", token, nil)
+	var letters []models.Letter
+	s.ok(response, &letters)
+	if len(letters) != 2 || letters[0].Name != "Delta" || letters[1].Name != "Charlie" {
+		t.Errorf("offset page %+v", letters)
+	}
+
+	first := s.do(http.MethodGet, "/api/v2/letters?sort=name&rows_limit=2", token, nil)
+	s.fails(s.do(http.MethodGet, "/api/v2/letters?sort=-name&cursor="+first.Page.NextCursor, token, nil), apperr.InvalidCursor)
+	s.fails(s.do(http.MethodGet, "/api/v2/letters?cursor=not-a-cursor", token, nil), apperr.InvalidCursor)
+	s.invalid(s.do(http.MethodGet, "/api/v2/letters?sort=content", token, nil), "sort", "oneof")
+
+	// The legacy route takes the same parameters in its body.
+	legacy := s.do(http.MethodPost, "/letters", token, models.LetterFilter{RowsLimit: 2, Sort: "name", Cursor: first.Page.NextCursor})
+	s.ok(legacy, &letters)
+	if len(letters) != 2 || letters[0].Name != "Charlie" || legacy.Page.Total != 5 {
+		t.Errorf("legacy page %+v %+v", letters, legacy.Page)
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	s.department(token, models.Department{Name: "Audit"})
+	s.department(token, models.Department{Name: "Legal"})
+	head := s.addEmployee("head@example.com", "DEP_HEAD", finance)
+	s.addEmployee("clerk@example.com", "EMPLOYEE", finance)
+	s.addEmployee("accountant@example.com", "EMPLOYEE", finance)
+	s.ok(s.do(http.MethodPut, "/department/heads", token, models.Department{Id: finance, HeadId: head}), nil)
+	headToken := s.login("head@example.com")
+
+	for i := 0; i < 3; i++ {
+		letter := s.letter(token, "Budget "+strconv.Itoa(i))
+		s.ok(s.do(http.MethodPost, "/letters/agreement", token, models.Agreement{LetterId: letter, DepartmentId: finance}), nil)
+	}
+
+	t.Run("departments", func(t *testing.T) {
+		s := s.with(t)
+
+		var departments []models.Department
+		response := s.do(http.MethodPost, "/departments?sort=name", token, nil)
+		s.ok(response, &departments)
+		if len(departments) != 3 || departments[0].Name != "Audit" || response.Page.Total != 3 || response.Page.NextCursor != "" {
+			t.Errorf("legacy list is not complete %+v %+v", departments, response.Page)
+		}
+
+		response = s.do(http.MethodGet, "/api/v2/departments?rows_limit=1", token, nil)
+		s.ok(response, &departments)
+		if len(departments) != 1 || departments[0].Name != "Legal" || response.Page.NextCursor == "" {
+			t.Errorf("newest department %+v %+v", departments, response.Page)
+		}
+	})
+
+	t.Run("department employees", func(t *testing.T) {
+		s := s.with(t)
+
+		var employees []models.Employee
+		path := "/api/v2/departments/" + strconv.Itoa(finance) + "/users?sort=email&rows_limit=2"
+		response := s.do(http.MethodGet, path, token, nil)
+		s.ok(response, &employees)
+		if len(employees) != 2 || employees[0].Email != "accountant@example.com" || response.Page.Total != 3 {
+			t.Fatalf("first page %+v %+v", employees, response.Page)
+		}
+
+		s.ok(s.do(http.MethodGet, path+"&cursor="+response.Page.NextCursor, token, nil), &employees)
+		if len(employees) != 1 || employees[0].Email != "head@example.com" {
+			t.Errorf("second page %+v", employees)
+		}
+	})
+
+	t.Run("agreements", func(t *testing.T) {
+		s := s.with(t)
+
+		var agreements []models.Agreement
+		response := s.do(http.MethodPost, "/letters/agreements", headToken, nil)
+		s.ok(response, &agreements)
+		if len(agreements) != 3 || response.Page.Total != 3 {
+			t.Errorf("legacy inbox is not complete %+v", response.Page)
+		}
+
+		response = s.do(http.MethodGet, "/api/v2/agreements?rows_limit=2&sort=agreed_at", headToken, nil)
+		s.ok(response, &agreements)
+		if len(agreements) != 2 || agreements[0].Letter.Name != "Budget 0" {
+			t.Fatalf("first page %+v", agreements)
+		}
+
+		s.ok(s.do(http.MethodGet, "/api/v2/agreements?rows_limit=2&sort=agreed_at&cursor="+response.Page.NextCursor, headToken, nil), &agreements)
+		if len(agreements) != 1 || agreements[0].Letter.Name != "Budget 2" {
+			t.Errorf("second page %+v", agreements)
+		}
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		s := s.with(t)
+
+		var roles []models.RoleGroup
+		response := s.do(http.MethodGet, "/api/v2/roles?sort=-role&rows_limit=1", token, nil)
+		s.ok(response, &roles)
+		if len(roles) != 1 || roles[0].Role != "EMPLOYEE" || response.Page.Total != 3 {
+			t.Errorf("roles %+v %+v", roles, response.Page)
+		}
+
+		s.fails(s.do(http.MethodPost, "/roles?rows_limit=many", token, nil), apperr.MalformedQuery)
+	})
+
+	// Only the legacy API lists everything at once; pages are at most 500
+	// rows.
+	for _, path := range []string{
+		"/api/v2/letters",
+		"/api/v2/trash/letters",
+		"/api/v2/retention/overdue",
+		"/api/v2/destruction-acts",
+		"/api/v2/cases",
+		"/api/v2/users",
+		"/api/v2/roles",
+		"/api/v2/departments",
+		"/api/v2/departments/" + strconv.Itoa(finance) + "/users",
+		"/api/v2/agreements",
+		"/api/v2/audit",
+	} {
+		t.Run(path, func(t *testing.T) {
+			s := s.with(t)
+
+			s.invalid(s.do(http.MethodGet, path+"?rows_limit=", token, nil), "rows_limit", "required")
+			s.invalid(s.do(http.MethodGet, path+"?rows_limit=0", token, nil), "rows_limit", "required")
+			s.invalid(s.do(http.MethodGet, path+"?rows_limit=501", token, nil), "rows_limit", "max")
+		})
+	}
+	s.invalid(s.do(http.MethodPost, "/letters/agreements?rows_limit=501", headToken, nil), "rows_limit", "max")
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sed/models"
+	"time"
+)
+
+// AuditHash returns the SHA-256 of entry without its id and hash, which
+// includes PreviousHash and so chains entry to the one before it. Changing
+// or removing an entry breaks the chain from there on.
+func AuditHash(entry models.AuditEntry) string {
+	data, _ := json.Marshal([]interface{}{
+		entry.PreviousHash,
+		entry.ActorId,
+		entry.Action,
+		entry.Entity,
+		entry.EntityId,
+		entry.Changes,
+		entry.IP,
+		entry.RequestId,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditChained reports whether entry follows the entry whose hash is
+// previous, "" for the first entry, and matches its own hash.
+func AuditChained(previous string, entry models.AuditEntry) bool {
+	return entry.PreviousHash == previous && AuditHash(entry) == entry.Hash
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"sed/models"
+	"testing"
+	"time"
+)
+
+func TestAuditChained(t *testing.T) {
+	var entries []models.AuditEntry
+	previous := ""
+	for _, sender := range []string{"Ministry of Finance", "Tax Committee", "Customs Committee"} {
+		entry := models.AuditEntry{
+			ActorId:      1,
+			Action:       "update",
+			Entity:       models.AuditLetter,
+			EntityId:     7,
+			Changes:      map[string]models.AuditChange{"sender": {After: sender}},
+			CreatedAt:    time.Now(),
+			PreviousHash: previous,
+		}
+		entry.Hash = AuditHash(entry)
+		previous = entry.Hash
+		entries = append(entries, entry)
+	}
+
+	broken := func(entries []models.AuditEntry) int {
+		previous := ""
+		for i, entry := range entries {
+			if !AuditChained(previous, entry) {
+				return i
+			}
+			previous = entry.Hash
+		}
+		return -1
+	}
+
+	if i := broken(entries); i != -1 {
+		t.Fatalf("intact chain broken at %d", i)
+	}
+
+	changed := append([]models.AuditEntry(nil), entries...)
+	changed[1].Changes = map[string]models.AuditChange{"sender": {After: "Nobody"}}
+	if i := broken(changed); i != 1 {
+		t.Errorf("changed entry found at %d", i)
+	}
+
+	removed := append([]models.AuditEntry{entries[0]}, entries[2])
+	if i := broken(removed); i != 1 {
+		t.Errorf("removed entry found at %d", i)
+	}
+}
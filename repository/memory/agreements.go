@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sed/models"
+	"sed/repository"
+	"time"
+)
+
+type Agreements struct {
+	s *Store
+}
+
+func (r *Agreements) Inbox(_ context.Context, employeeId int, filter models.AgreementFilter) (agreements []models.Agreement, page models.Page, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order := repository.ParseOrder(filter.Sort, "-id")
+	key, ok := repository.AgreementKeys[order.Field]
+	if !ok {
+		return nil, page, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+
+	ids := r.inbox(employeeId, filter.Subdepartments)
+
+	for _, agreement := range r.s.agreements {
+		if _, live := r.s.letter(agreement.LetterId); live && contains(ids, agreement.DepartmentId) {
+			agreements = append(agreements, r.view(agreement))
+		}
+	}
+
+	from, to, page, err := paginate(agreements, func(i int) (interface{}, int) {
+		return key(agreements[i]), agreements[i].Id
+	}, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit)
+	if err != nil {
+		return nil, page, err
+	}
+
+	return agreements[from:to], page, nil
+}
+
+// inbox mirrors the inboxSubtree query of the postgres implementation.
+func (r *Agreements) inbox(employeeId int, subdepartments bool) []int {
+	employee := r.s.employees[employeeId]
+	role := r.s.roleName(employee.RoleId)
+
+	var anchors []int
+	for _, id := range sortedKeys(r.s.departments) {
+		department := r.s.departments[id]
+		switch {
+		case department.HeadId == employeeId, department.DeputyId == employeeId:
+			anchors = append(anchors, id)
+		case department.HeadId == 0 && department.Id == employee.DepartmentId && (role == "ADMIN" || role == "DEP_HEAD"):
+			anchors = append(anchors, id)
+		}
+	}
+
+	return r.s.subtree(anchors, subdepartments)
+}
+
+func (r *Agreements) view(agreement models.Agreement) models.Agreement {
+	letter := r.s.letters[agreement.LetterId]
+	department := r.s.departments[agreement.DepartmentId]
+
+	return models.Agreement{
+		Id:               agreement.Id,
+		Department:       models.Department{Id: department.Id, Name: department.Name},
+		Letter:           models.Letter{Id: letter.Id, Name: letter.Name, EntryDate: letter.EntryDate},
+		Viewed:           agreement.Viewed,
+		AgreedAt:         orNow(agreement.AgreedAt),
+		Agreed:           agreement.Agreed,
+		DestructionActId: agreement.DestructionActId,
+	}
+}
+
+func (r *Agreements) Create(_ context.Context, agreement models.Agreement) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.departments[agreement.DepartmentId]; !ok {
+		return 0, repository.ErrNotFound
+	}
+
+	if _, ok := r.s.letter(agreement.LetterId); !ok {
+		return 0, repository.ErrNotFound
+	}
+
+	stored := models.Agreement{
+		Id:           r.s.nextId(),
+		DepartmentId: agreement.DepartmentId,
+		LetterId:     agreement.LetterId,
+		AgreedAt:     time.Now(),
+	}
+	r.s.agreements[stored.Id] = stored
+
+	return stored.Id, nil
+}
+
+func (r *Agreements) Get(_ context.Context, id int) (models.Agreement, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	agreement, ok := r.s.agreements[id]
+	if !ok {
+		return models.Agreement{}, repository.ErrNotFound
+	}
+
+	return r.view(agreement), nil
+}
+
+func (r *Agreements) MarkViewed(_ context.Context, id int) error {
+	return r.update(id, func(stored *models.Agreement) {
+		stored.Viewed = true
+	})
+}
+
+func (r *Agreements) Holds(_ context.Context, id, employeeId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.agreements[id]
+	switch {
+	case !ok:
+		return repository.ErrNotFound
+	case !contains(r.inbox(employeeId, true), stored.DepartmentId):
+		return repository.ErrNotAllowed
+	}
+
+	return nil
+}
+
+func (r *Agreements) Decide(_ context.Context, id, employeeId int, agree bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.agreements[id]
+	switch {
+	case !ok:
+		return repository.ErrNotFound
+	case !contains(r.inbox(employeeId, true), stored.DepartmentId):
+		return repository.ErrNotAllowed
+	case stored.DecidedAt != nil:
+		return repository.ErrConflict
+	}
+
+	now := time.Now()
+	stored.Agreed = agree
+	stored.DecidedAt = &now
+	r.s.agreements[id] = stored
+
+	return nil
+}
+
+func (r *Agreements) update(id int, change func(stored *models.Agreement)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.agreements[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	change(&stored)
+	r.s.agreements[id] = stored
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sed/models"
+	"sed/repository"
+	"time"
+)
+
+type Audit struct {
+	s *Store
+}
+
+func (r *Audit) Append(_ context.Context, entry models.AuditEntry) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	entry.Id = r.s.nextId()
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.PreviousHash = ""
+	if len(r.s.audit) > 0 {
+		entry.PreviousHash = r.s.audit[len(r.s.audit)-1].Hash
+	}
+	entry.Hash = repository.AuditHash(entry)
+	r.s.audit = append(r.s.audit, entry)
+
+	return nil
+}
+
+func (r *Audit) List(_ context.Context, filter models.AuditFilter) (entries []models.AuditEntry, page models.Page, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order := repository.ParseOrder(filter.Sort, "-id")
+	key, ok := repository.AuditKeys[order.Field]
+	if !ok {
+		return nil, page, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+
+	for _, entry := range r.s.audit {
+		switch {
+		case filter.Entity != "" && entry.Entity != filter.Entity,
+			filter.EntityId != 0 && entry.EntityId != filter.EntityId,
+			filter.ActorId != 0 && entry.ActorId != filter.ActorId,
+			!filter.From.IsZero() && entry.CreatedAt.Before(filter.From),
+			!filter.To.IsZero() && !entry.CreatedAt.Before(filter.To):
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	from, to, page, err := paginate(entries, func(i int) (interface{}, int) {
+		return key(entries[i]), entries[i].Id
+	}, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit)
+	if err != nil {
+		return nil, page, err
+	}
+
+	return entries[from:to], page, nil
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sed/models"
+	"sed/repository"
+	"strings"
+	"time"
+)
+
+type Cases struct {
+	s *Store
+}
+
+// withLetters counts the letters filed into c and their entry dates.
+func (s *Store) withLetters(c models.Case) models.Case {
+	for _, letter := range s.letters {
+		if letter.CaseId != c.Id || letter.DeletedAt != nil {
+			continue
+		}
+
+		entryDate := letter.EntryDate
+		c.Letters++
+		if c.FirstEntryDate == nil || entryDate.Before(*c.FirstEntryDate) {
+			c.FirstEntryDate = &entryDate
+		}
+		if c.LastEntryDate == nil || entryDate.After(*c.LastEntryDate) {
+			c.LastEntryDate = &entryDate
+		}
+	}
+
+	return c
+}
+
+func (r *Cases) List(_ context.Context, filter models.CaseFilter) (cases []models.Case, page models.Page, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order := repository.ParseOrder(filter.Sort, "-id")
+	key, ok := repository.CaseKeys[order.Field]
+	if !ok {
+		return nil, page, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+
+	index := strings.TrimSpace(filter.Index)
+
+	for _, c := range r.s.cases {
+		if filter.DepartmentId != 0 && c.DepartmentId != filter.DepartmentId {
+			continue
+		}
+
+		if filter.Year != 0 && c.Year != filter.Year {
+			continue
+		}
+
+		if index != "" && c.Index != index {
+			continue
+		}
+
+		cases = append(cases, r.s.withLetters(c))
+	}
+
+	from, to, page, err := paginate(cases, func(i int) (interface{}, int) {
+		return key(cases[i]), cases[i].Id
+	}, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit)
+	if err != nil {
+		return nil, page, err
+	}
+
+	return cases[from:to], page, nil
+}
+
+func (r *Cases) Get(_ context.Context, id int) (models.Case, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.cases[id]
+	if !ok {
+		return models.Case{}, repository.ErrNotFound
+	}
+
+	return r.s.withLetters(c), nil
+}
+
+func (r *Cases) Create(_ context.Context, c models.Case) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.departments[c.DepartmentId]; !ok {
+		return 0, fmt.Errorf("department %d does not exist", c.DepartmentId)
+	}
+
+	for _, stored := range r.s.cases {
+		if stored.DepartmentId == c.DepartmentId && stored.Year == c.Year && stored.Index == c.Index {
+			return 0, repository.ErrDuplicate
+		}
+	}
+
+	stored := models.Case{
+		Id:             r.s.nextId(),
+		DepartmentId:   c.DepartmentId,
+		Year:           c.Year,
+		Index:          c.Index,
+		Title:          c.Title,
+		RetentionYears: c.RetentionYears,
+		Article:        c.Article,
+		CreatedAt:      time.Now(),
+	}
+	r.s.cases[stored.Id] = stored
+
+	return stored.Id, nil
+}
+
+func (r *Cases) SetClosed(_ context.Context, id int, closed bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.cases[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	switch {
+	case closed && c.ClosedAt == nil:
+		now := time.Now()
+		c.ClosedAt = &now
+	case !closed:
+		c.ClosedAt = nil
+	}
+	r.s.cases[id] = c
+
+	return nil
+}
+
+func (r *Cases) File(_ context.Context, letterId, caseId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	letter, ok := r.s.letter(letterId)
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	target, ok := r.s.cases[caseId]
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	current, filed := r.s.cases[letter.CaseId]
+	if target.ClosedAt != nil || filed && current.ClosedAt != nil {
+		return repository.ErrCaseClosed
+	}
+
+	letter.CaseId = caseId
+	letter.Version++
+	r.s.letters[letterId] = letter
+
+	return nil
+}
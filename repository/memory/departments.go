@@ -0,0 +1,266 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sed/models"
+	"sed/repository"
+	"time"
+)
+
+type Departments struct {
+	s *Store
+}
+
+func (r *Departments) List(_ context.Context, filter models.DepartmentFilter) (departments []models.Department, page models.Page, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order := repository.ParseOrder(filter.Sort, "-id")
+	key, ok := repository.DepartmentKeys[order.Field]
+	if !ok {
+		return nil, page, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+
+	for _, department := range r.s.departments {
+		if !filter.Archived && department.ArchivedAt != nil {
+			continue
+		}
+
+		departments = append(departments, r.withHeads(department))
+	}
+
+	from, to, page, err := paginate(departments, func(i int) (interface{}, int) {
+		return key(departments[i]), departments[i].Id
+	}, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit)
+	if err != nil {
+		return nil, page, err
+	}
+
+	return departments[from:to], page, nil
+}
+
+func (r *Departments) Get(_ context.Context, id int) (models.Department, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	department, ok := r.s.departments[id]
+	if !ok {
+		return models.Department{}, repository.ErrNotFound
+	}
+
+	return r.withHeads(department), nil
+}
+
+// withHeads fills the head and deputy of department the way the postgres
+// join does.
+func (r *Departments) withHeads(department models.Department) models.Department {
+	if head, ok := r.s.employees[department.HeadId]; ok {
+		department.Head = &models.Employee{Id: head.Id, FullName: head.FullName, Position: head.Position}
+	}
+
+	if deputy, ok := r.s.employees[department.DeputyId]; ok {
+		department.Deputy = &models.Employee{Id: deputy.Id, FullName: deputy.FullName, Position: deputy.Position}
+	}
+
+	return department
+}
+
+func (r *Departments) Create(_ context.Context, department models.Department) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.departments[department.ParentId]; department.ParentId != 0 && !ok {
+		return 0, repository.ErrNotFound
+	}
+
+	stored := models.Department{
+		Id:             r.s.nextId(),
+		ParentId:       department.ParentId,
+		Name:           department.Name,
+		InternalNumber: department.InternalNumber,
+		Phone:          department.Phone,
+		Version:        1,
+	}
+	r.s.departments[stored.Id] = stored
+
+	return stored.Id, nil
+}
+
+func (r *Departments) Update(_ context.Context, department models.Department) (int, error) {
+	return r.update(department.Id, func(stored *models.Department) error {
+		if stored.Version != department.Version {
+			return repository.ErrConflict
+		}
+
+		stored.Name = department.Name
+		stored.InternalNumber = department.InternalNumber
+		stored.Phone = department.Phone
+		return nil
+	})
+}
+
+func (r *Departments) Move(_ context.Context, id, parentId int) error {
+	_, err := r.update(id, func(stored *models.Department) error {
+		if r.s.cyclic(id, parentId) {
+			return repository.ErrCycle
+		}
+		stored.ParentId = parentId
+		return nil
+	})
+	return err
+}
+
+func (r *Departments) SetHeads(_ context.Context, id, headId, deputyId int) error {
+	_, err := r.update(id, func(stored *models.Department) error {
+		stored.HeadId = headId
+		stored.DeputyId = deputyId
+		return nil
+	})
+	return err
+}
+
+func (r *Departments) IsArchived(_ context.Context, id int) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	department, ok := r.s.departments[id]
+	if !ok {
+		return false, repository.ErrNotFound
+	}
+
+	return department.ArchivedAt != nil, nil
+}
+
+func (r *Departments) Members(_ context.Context, id int) (employees int, children int, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, employee := range r.s.employees {
+		if employee.DepartmentId == id {
+			employees++
+		}
+	}
+
+	for _, department := range r.s.departments {
+		if department.ParentId == id && department.ArchivedAt == nil {
+			children++
+		}
+	}
+
+	return employees, children, nil
+}
+
+func (r *Departments) Archive(_ context.Context, id int) error {
+	_, err := r.update(id, func(stored *models.Department) error {
+		now := time.Now()
+		stored.ArchivedAt = &now
+		stored.HeadId = 0
+		stored.DeputyId = 0
+		return nil
+	})
+	return err
+}
+
+func (r *Departments) Merge(_ context.Context, sourceId, targetId, actorId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	source, ok := r.s.departments[sourceId]
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	if _, ok = r.s.departments[targetId]; !ok {
+		return repository.ErrNotFound
+	}
+
+	if r.s.cyclic(sourceId, targetId) {
+		return repository.ErrCycle
+	}
+
+	now := time.Now()
+
+	for _, id := range sortedKeys(r.s.employees) {
+		employee := r.s.employees[id]
+		if employee.DepartmentId != sourceId {
+			continue
+		}
+
+		r.s.transfers = append(r.s.transfers, models.EmployeeTransfer{
+			Id:               r.s.nextId(),
+			EmployeeId:       employee.Id,
+			FromDepartmentId: sourceId,
+			ToDepartmentId:   targetId,
+			TransferredBy:    actorId,
+			TransferredAt:    now,
+		})
+
+		employee.DepartmentId = targetId
+		r.s.employees[id] = employee
+	}
+
+	for id, department := range r.s.departments {
+		if department.ParentId == sourceId {
+			department.ParentId = targetId
+			department.Version++
+			r.s.departments[id] = department
+		}
+	}
+
+	for id, agreement := range r.s.agreements {
+		if agreement.DepartmentId == sourceId && agreement.DecidedAt == nil {
+			agreement.DepartmentId = targetId
+			r.s.agreements[id] = agreement
+		}
+	}
+
+	for i, describedLetter := range r.s.described {
+		if describedLetter.DepartmentId == sourceId && r.s.letters[describedLetter.LetterId].DistributionDate.IsZero() {
+			r.s.described[i].DepartmentId = targetId
+		}
+	}
+
+	source.ArchivedAt = &now
+	source.MergedIntoId = targetId
+	source.HeadId = 0
+	source.DeputyId = 0
+	source.Version++
+	r.s.departments[sourceId] = source
+
+	return nil
+}
+
+func (r *Departments) Subtree(_ context.Context, id int, subdepartments bool) ([]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.departments[id]; !ok {
+		return []int{}, nil
+	}
+
+	return r.s.subtree([]int{id}, subdepartments), nil
+}
+
+// update applies change to an active department, like the postgres updates
+// guarded by "archived_at is null", and bumps its version unless change
+// fails.
+func (r *Departments) update(id int, change func(stored *models.Department) error) (version int, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.departments[id]
+	if !ok || stored.ArchivedAt != nil {
+		return 0, repository.ErrNotFound
+	}
+
+	err = change(&stored)
+	if err != nil {
+		return 0, err
+	}
+
+	stored.Version++
+	r.s.departments[id] = stored
+
+	return stored.Version, nil
+}
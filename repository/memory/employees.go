@@ -0,0 +1,216 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sed/models"
+	"sed/repository"
+	"sort"
+	"time"
+)
+
+type Employees struct {
+	s *Store
+}
+
+// view returns employee shaped like the rows of the postgres employee select.
+func (r *Employees) view(employee models.Employee) models.Employee {
+	department := r.s.departments[employee.DepartmentId]
+
+	return models.Employee{
+		Id:           employee.Id,
+		FullName:     employee.FullName,
+		Position:     employee.Position,
+		RoleId:       employee.RoleId,
+		Role:         models.RoleGroup{Id: employee.RoleId, Role: r.s.roleName(employee.RoleId)},
+		Email:        employee.Email,
+		Language:     employee.Language,
+		DepartmentId: department.Id,
+		Department: models.Department{
+			Id:       department.Id,
+			Name:     department.Name,
+			HeadId:   department.HeadId,
+			DeputyId: department.DeputyId,
+		},
+	}
+}
+
+func (r *Employees) ByToken(_ context.Context, token string) (models.Employee, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, employee := range r.s.employees {
+		if employee.Token == token {
+			return models.Employee{Id: employee.Id, Language: employee.Language}, nil
+		}
+	}
+
+	return models.Employee{}, repository.ErrNotFound
+}
+
+func (r *Employees) ByEmail(_ context.Context, email string) (models.Employee, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, employee := range r.s.employees {
+		if employee.Email == email {
+			return models.Employee{
+				Id:       employee.Id,
+				Password: employee.Password,
+				Role:     models.RoleGroup{Role: r.s.roleName(employee.RoleId)},
+				FullName: employee.FullName,
+				Email:    employee.Email,
+			}, nil
+		}
+	}
+
+	return models.Employee{}, repository.ErrNotFound
+}
+
+func (r *Employees) SetToken(_ context.Context, id int, token string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	employee, ok := r.s.employees[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	employee.Token = token
+	r.s.employees[id] = employee
+
+	return nil
+}
+
+func (r *Employees) Get(_ context.Context, id int) (models.Employee, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	employee, ok := r.s.employees[id]
+	if !ok {
+		return models.Employee{}, repository.ErrNotFound
+	}
+
+	return r.view(employee), nil
+}
+
+func (r *Employees) List(_ context.Context, filter models.EmployeeFilter) (employees []models.Employee, page models.Page, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order := repository.ParseOrder(filter.Sort, "-id")
+	key, ok := repository.EmployeeKeys[order.Field]
+	if !ok {
+		return nil, page, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+
+	for _, stored := range r.s.employees {
+		employee := r.view(stored)
+
+		if !containsFold(employee.FullName, filter.FullName) ||
+			!containsFold(employee.Email, filter.Email) ||
+			!containsFold(employee.Department.Name, filter.Department) {
+			continue
+		}
+
+		if filter.DepartmentIds != nil && (employee.DepartmentId == 0 || !contains(filter.DepartmentIds, employee.DepartmentId)) {
+			continue
+		}
+
+		employees = append(employees, employee)
+	}
+
+	from, to, page, err := paginate(employees, func(i int) (interface{}, int) {
+		return key(employees[i]), employees[i].Id
+	}, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit)
+	if err != nil {
+		return nil, page, err
+	}
+
+	return employees[from:to], page, nil
+}
+
+func (r *Employees) All(_ context.Context) (employees []models.Employee, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, employee := range r.s.employees {
+		employees = append(employees, r.view(employee))
+	}
+
+	sort.Slice(employees, func(i, j int) bool {
+		return employees[i].FullName < employees[j].FullName
+	})
+
+	return employees, nil
+}
+
+func (r *Employees) Update(_ context.Context, employee models.Employee, actorId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.employees[employee.Id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	if stored.DepartmentId != employee.DepartmentId {
+		r.s.transfers = append(r.s.transfers, models.EmployeeTransfer{
+			Id:               r.s.nextId(),
+			EmployeeId:       employee.Id,
+			FromDepartmentId: stored.DepartmentId,
+			ToDepartmentId:   employee.DepartmentId,
+			TransferredBy:    actorId,
+			TransferredAt:    time.Now(),
+		})
+	}
+
+	stored.FullName = employee.FullName
+	stored.RoleId = employee.RoleId
+	stored.DepartmentId = employee.DepartmentId
+	stored.Position = employee.Position
+	stored.Language = employee.Language
+	r.s.employees[employee.Id] = stored
+
+	return nil
+}
+
+func (r *Employees) Transfers(_ context.Context, employeeId int) (transfers []models.EmployeeTransfer, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i := len(r.s.transfers) - 1; i >= 0; i-- {
+		transfer := r.s.transfers[i]
+		if transfer.EmployeeId != employeeId {
+			continue
+		}
+
+		transfer.FromDepartment.Name = r.s.departments[transfer.FromDepartmentId].Name
+		transfer.ToDepartment.Name = r.s.departments[transfer.ToDepartmentId].Name
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, nil
+}
+
+func (r *Employees) Roles(_ context.Context, filter models.RoleFilter) ([]models.RoleGroup, models.Page, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order := repository.ParseOrder(filter.Sort, "id")
+	key, ok := repository.RoleKeys[order.Field]
+	if !ok {
+		return nil, models.Page{}, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+
+	roleGroups := append([]models.RoleGroup(nil), r.s.roles...)
+
+	from, to, page, err := paginate(roleGroups, func(i int) (interface{}, int) {
+		return key(roleGroups[i]), roleGroups[i].Id
+	}, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit)
+	if err != nil {
+		return nil, page, err
+	}
+
+	return roleGroups[from:to], page, nil
+}
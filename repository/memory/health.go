@@ -0,0 +1,29 @@
+package memory
+
+import (
+	"context"
+	"sed/db"
+)
+
+type Health struct {
+	s *Store
+}
+
+func (r *Health) Ping(_ context.Context) error {
+	return nil
+}
+
+// Migrations reports every embedded migration as applied when the store
+// was created, since the store starts with the schema they describe.
+func (r *Health) Migrations(_ context.Context) ([]db.Migration, error) {
+	migrations, err := db.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range migrations {
+		migrations[i].AppliedAt = &r.s.createdAt
+	}
+
+	return migrations, nil
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"sed/models"
+	"time"
+)
+
+type Idempotency struct {
+	s *Store
+}
+
+// idempotencyKey is the primary key of the postgres table.
+type idempotencyKey struct {
+	employeeId int
+	key        string
+}
+
+func (r *Idempotency) Begin(_ context.Context, request models.IdempotentRequest) (models.IdempotentRequest, bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	for key, stored := range r.s.idempotency {
+		if stored.ExpiresAt.Before(now) {
+			delete(r.s.idempotency, key)
+		}
+	}
+
+	key := idempotencyKey{request.EmployeeId, request.Key}
+	if stored, ok := r.s.idempotency[key]; ok {
+		return stored, true, nil
+	}
+
+	request.Status = 0
+	request.Headers = nil
+	request.Body = nil
+	r.s.idempotency[key] = request
+
+	return models.IdempotentRequest{}, false, nil
+}
+
+func (r *Idempotency) Complete(_ context.Context, request models.IdempotentRequest) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	key := idempotencyKey{request.EmployeeId, request.Key}
+	stored, ok := r.s.idempotency[key]
+	if !ok {
+		return nil
+	}
+
+	stored.Status = request.Status
+	stored.Headers = request.Headers
+	stored.Body = request.Body
+	r.s.idempotency[key] = stored
+
+	return nil
+}
+
+func (r *Idempotency) Release(_ context.Context, employeeId int, key string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.idempotency, idempotencyKey{employeeId, key})
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"sed/importer"
+	"sed/models"
+	"strings"
+)
+
+type Imports struct {
+	s *Store
+}
+
+func (r *Imports) Departments(_ context.Context) (map[string]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.departments(), nil
+}
+
+func (r *Imports) departments() map[string]int {
+	departments := make(map[string]int)
+	for _, department := range r.s.departments {
+		if department.ArchivedAt == nil {
+			departments[strings.ToLower(department.Name)] = department.Id
+		}
+	}
+	return departments
+}
+
+func (r *Imports) Roles(_ context.Context) (map[string]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	roles := make(map[string]int)
+	for _, role := range r.s.roles {
+		roles[strings.ToUpper(role.Role)] = role.Id
+	}
+
+	return roles, nil
+}
+
+func (r *Imports) TakenEmails(_ context.Context, emails []string) (map[string]bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	taken := make(map[string]bool)
+	for _, employee := range r.s.employees {
+		email := strings.ToLower(employee.Email)
+		for _, candidate := range emails {
+			if candidate == email {
+				taken[email] = true
+			}
+		}
+	}
+
+	return taken, nil
+}
+
+// Import holds the store lock for the whole batch, so readers see either
+// none or all of it.
+func (r *Imports) Import(_ context.Context, batch importer.Batch) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	departments := r.departments()
+
+	for _, department := range batch.Departments {
+		stored := models.Department{
+			Id:             r.s.nextId(),
+			ParentId:       departments[strings.ToLower(department.Parent)],
+			Name:           department.Name,
+			InternalNumber: department.InternalNumber,
+			Phone:          department.Phone,
+			Version:        1,
+		}
+		r.s.departments[stored.Id] = stored
+		departments[strings.ToLower(stored.Name)] = stored.Id
+	}
+
+	for _, employee := range batch.Employees {
+		stored := models.Employee{
+			Id:           r.s.nextId(),
+			FullName:     employee.FullName,
+			Position:     employee.Position,
+			RoleId:       r.s.roleId(employee.Role),
+			Email:        employee.Email,
+			Password:     employee.Password,
+			DepartmentId: departments[strings.ToLower(employee.Department)],
+		}
+		r.s.employees[stored.Id] = stored
+	}
+
+	return nil
+}
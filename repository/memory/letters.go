@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sed/models"
+	"sed/repository"
+	"strings"
+	"time"
+)
+
+type Letters struct {
+	s *Store
+}
+
+func (r *Letters) List(_ context.Context, filter models.LetterFilter) (letters []models.Letter, page models.Page, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order := repository.ParseOrder(filter.Sort, "-id")
+	key, ok := repository.LetterKeys[order.Field]
+	if !ok {
+		return nil, page, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+
+	sender := strings.TrimSpace(filter.Sender)
+	name := strings.ToLower(strings.TrimSpace(filter.Name))
+	caseIndex := strings.TrimSpace(filter.CaseIndex)
+
+	for _, letter := range r.s.letters {
+		if letter.DeletedAt != nil {
+			continue
+		}
+
+		if sender != "" && !strings.Contains(letter.Sender, sender) {
+			continue
+		}
+
+		if name != "" && !strings.Contains(strings.ToLower(letter.Name), name) {
+			continue
+		}
+
+		if filter.DepartmentId != 0 && !r.described(letter.Id, filter.DepartmentIds) {
+			continue
+		}
+
+		letter.CaseIndex = r.s.cases[letter.CaseId].Index
+		if caseIndex != "" && letter.CaseIndex != caseIndex {
+			continue
+		}
+
+		letter.Content = ""
+		letter.EntryDate = orNow(letter.EntryDate)
+		letter.DistributionDate = orNow(letter.DistributionDate)
+		letters = append(letters, r.withType(letter))
+	}
+
+	from, to, page, err := paginate(letters, func(i int) (interface{}, int) {
+		return key(letters[i]), letters[i].Id
+	}, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit)
+	if err != nil {
+		return nil, page, err
+	}
+
+	return letters[from:to], page, nil
+}
+
+func (r *Letters) described(letterId int, departmentIds []int) bool {
+	for _, describedLetter := range r.s.described {
+		if describedLetter.LetterId == letterId && contains(departmentIds, describedLetter.DepartmentId) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Letters) withType(letter models.Letter) models.Letter {
+	for _, documentType := range r.s.documentTypes {
+		if documentType.Id == letter.DocumentTypeId {
+			letter.DocumentType.Type = documentType.Type
+			letter.DocumentType.Labels = documentType.Labels
+		}
+	}
+	letter.DocumentTypeId = 0
+	return letter
+}
+
+func (r *Letters) Get(_ context.Context, id int) (models.Letter, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	letter, ok := r.s.letter(id)
+	if !ok {
+		return models.Letter{}, repository.ErrNotFound
+	}
+
+	return r.full(letter), nil
+}
+
+// full returns letter as Get and Deleted do, with its document type id.
+func (r *Letters) full(letter models.Letter) models.Letter {
+	letter.DistributionDate = orNow(letter.DistributionDate)
+	letter.CaseIndex = r.s.cases[letter.CaseId].Index
+	documentTypeId := letter.DocumentTypeId
+
+	letter = r.withType(letter)
+	letter.DocumentTypeId = documentTypeId
+	return letter
+}
+
+func (r *Letters) Create(_ context.Context, letter models.Letter) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	stamp := now.Format("2006-01-02 15:04:05.999999-07")
+
+	letter.Id = r.s.nextId()
+	letter.RegistrationNumber = stamp
+	letter.EntryDate = now
+	letter.OutgoingNumber = stamp
+	letter.DistributionDate = time.Time{}
+	letter.DocumentType = models.DocumentType{}
+	letter.Version = 1
+	r.s.letters[letter.Id] = letter
+
+	return letter.Id, nil
+}
+
+func (r *Letters) Update(_ context.Context, letter models.Letter) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.letter(letter.Id)
+	if !ok {
+		return 0, repository.ErrNotFound
+	}
+
+	if stored.Version != letter.Version || stored.DestroyedAt != nil {
+		return 0, repository.ErrConflict
+	}
+
+	stored.Name = letter.Name
+	stored.Sender = letter.Sender
+	stored.DocumentTypeId = letter.DocumentTypeId
+	stored.Content = letter.Content
+	stored.Version++
+	r.s.letters[letter.Id] = stored
+
+	return stored.Version, nil
+}
+
+func (r *Letters) Describe(_ context.Context, describedLetter models.DescribedLetter) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.letter(describedLetter.LetterId); !ok {
+		return repository.ErrNotFound
+	}
+
+	describedLetter.Id = r.s.nextId()
+	r.s.described = append(r.s.described, describedLetter)
+
+	return nil
+}
+
+func (r *Letters) DocumentTypes(_ context.Context) ([]models.DocumentType, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return append([]models.DocumentType(nil), r.s.documentTypes...), nil
+}
+
+func (r *Letters) Delete(_ context.Context, id, actorId int, reason string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.letter(id)
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	now := time.Now()
+	stored.DeletedAt = &now
+	stored.DeletedBy = actorId
+	stored.DeleteReason = reason
+	stored.Version++
+	r.s.letters[id] = stored
+
+	return nil
+}
+
+func (r *Letters) Deleted(_ context.Context, id int) (models.Letter, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	letter, ok := r.s.letters[id]
+	if !ok || letter.DeletedAt == nil {
+		return models.Letter{}, repository.ErrNotFound
+	}
+
+	return r.full(letter), nil
+}
+
+func (r *Letters) Trash(_ context.Context, filter models.TrashFilter) (letters []models.Letter, page models.Page, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order := repository.ParseOrder(filter.Sort, "-deleted_at")
+	key, ok := repository.TrashKeys[order.Field]
+	if !ok {
+		return nil, page, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+
+	for _, letter := range r.s.letters {
+		if letter.DeletedAt == nil {
+			continue
+		}
+
+		letter.Content = ""
+		letter.OutgoingNumber = ""
+		letter.DistributionDate = time.Time{}
+		letter.Version = 0
+		letters = append(letters, r.withType(letter))
+	}
+
+	from, to, page, err := paginate(letters, func(i int) (interface{}, int) {
+		return key(letters[i]), letters[i].Id
+	}, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit)
+	if err != nil {
+		return nil, page, err
+	}
+
+	return letters[from:to], page, nil
+}
+
+func (r *Letters) Restore(_ context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.letters[id]
+	if !ok || stored.DeletedAt == nil {
+		return repository.ErrNotFound
+	}
+
+	stored.DeletedAt = nil
+	stored.DeletedBy = 0
+	stored.DeleteReason = ""
+	stored.Version++
+	r.s.letters[id] = stored
+
+	return nil
+}
+
+func (r *Letters) Purge(_ context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.letters[id]
+	if !ok || stored.DeletedAt == nil {
+		return repository.ErrNotFound
+	}
+
+	for _, act := range r.s.acts {
+		if contains(act.LetterIds, id) {
+			return repository.ErrInAct
+		}
+	}
+
+	for agreementId, agreement := range r.s.agreements {
+		if agreement.LetterId == id {
+			delete(r.s.agreements, agreementId)
+		}
+	}
+
+	described := r.s.described[:0]
+	for _, describedLetter := range r.s.described {
+		if describedLetter.LetterId != id {
+			described = append(described, describedLetter)
+		}
+	}
+	r.s.described = described
+
+	delete(r.s.letters, id)
+
+	return nil
+}
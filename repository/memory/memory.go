@@ -0,0 +1,245 @@
+// Package memory implements the repository interfaces in process memory.
+// It is meant for tests and local development without Postgres.
+package memory
+
+import (
+	"sed/models"
+	"sed/repository"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store holds every table. All repositories returned by Repositories share
+// it, so a change made through one is visible through the others.
+type Store struct {
+	mu            sync.Mutex
+	lastId        int
+	roles         []models.RoleGroup
+	documentTypes []models.DocumentType
+	departments   map[int]models.Department
+	employees     map[int]models.Employee
+	transfers     []models.EmployeeTransfer
+	letters       map[int]models.Letter
+	described     []models.DescribedLetter
+	agreements    map[int]models.Agreement
+	idempotency   map[idempotencyKey]models.IdempotentRequest
+	audit         []models.AuditEntry
+	rules         map[int]models.RetentionRule
+	acts          map[int]models.DestructionAct
+	cases         map[int]models.Case
+	createdAt     time.Time
+}
+
+// New returns a store seeded with the same roles, document types and labels
+// as the reference data migrations.
+func New() *Store {
+	s := &Store{
+		departments: make(map[int]models.Department),
+		employees:   make(map[int]models.Employee),
+		letters:     make(map[int]models.Letter),
+		agreements:  make(map[int]models.Agreement),
+		idempotency: make(map[idempotencyKey]models.IdempotentRequest),
+		rules:       make(map[int]models.RetentionRule),
+		acts:        make(map[int]models.DestructionAct),
+		cases:       make(map[int]models.Case),
+		createdAt:   time.Now(),
+	}
+
+	roles := []struct {
+		role   string
+		labels map[string]string
+	}{
+		{"ADMIN", map[string]string{"en": "Administrator", "ru": "Администратор", "uz": "Administrator"}},
+		{"DEP_HEAD", map[string]string{"en": "Head of department", "ru": "Начальник отдела", "uz": "Bo'lim boshlig'i"}},
+		{"EMPLOYEE", map[string]string{"en": "Employee", "ru": "Сотрудник", "uz": "Xodim"}},
+	}
+	for _, role := range roles {
+		s.roles = append(s.roles, models.RoleGroup{Id: s.nextId(), Role: role.role, Labels: role.labels})
+	}
+
+	documentTypes := []struct {
+		documentType string
+		labels       map[string]string
+	}{
+		{"Incoming letter", map[string]string{"en": "Incoming letter", "ru": "Входящее письмо", "uz": "Kiruvchi xat"}},
+		{"Outgoing letter", map[string]string{"en": "Outgoing letter", "ru": "Исходящее письмо", "uz": "Chiquvchi xat"}},
+		{"Internal memo", map[string]string{"en": "Internal memo", "ru": "Служебная записка", "uz": "Xizmat xati"}},
+		{"Order", map[string]string{"en": "Order", "ru": "Приказ", "uz": "Buyruq"}},
+		{"Citizen appeal", map[string]string{"en": "Citizen appeal", "ru": "Обращение граждан", "uz": "Fuqarolar murojaati"}},
+	}
+	for _, documentType := range documentTypes {
+		s.documentTypes = append(s.documentTypes, models.DocumentType{Id: s.nextId(), Type: documentType.documentType, Labels: documentType.labels})
+	}
+
+	return s
+}
+
+// letter returns the letter with id unless it is missing or in the trash.
+func (s *Store) letter(id int) (models.Letter, bool) {
+	letter, ok := s.letters[id]
+	return letter, ok && letter.DeletedAt == nil
+}
+
+func (s *Store) Repositories() repository.Repositories {
+	return repository.Repositories{
+		Letters:     &Letters{s},
+		Employees:   &Employees{s},
+		Departments: &Departments{s},
+		Agreements:  &Agreements{s},
+		Imports:     &Imports{s},
+		Health:      &Health{s},
+		Stats:       &Stats{s},
+		Idempotency: &Idempotency{s},
+		Audit:       &Audit{s},
+		Retention:   &Retention{s},
+		Cases:       &Cases{s},
+	}
+}
+
+// AddEmployee stores employee as is, including its password hash and
+// token, and returns the assigned id.
+func (s *Store) AddEmployee(employee models.Employee) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	employee.Id = s.nextId()
+	s.employees[employee.Id] = employee
+	return employee.Id
+}
+
+// AddLetter stores letter as is, including its entry date, and returns the
+// assigned id.
+func (s *Store) AddLetter(letter models.Letter) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letter.Id = s.nextId()
+	s.letters[letter.Id] = letter
+	return letter.Id
+}
+
+// RoleId returns the id of the named role, or 0.
+func (s *Store) RoleId(role string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.roleId(role)
+}
+
+// nextId hands out ids from a single sequence, so ids never collide across tables.
+func (s *Store) nextId() int {
+	s.lastId++
+	return s.lastId
+}
+
+func (s *Store) roleId(role string) int {
+	for _, roleGroup := range s.roles {
+		if roleGroup.Role == role {
+			return roleGroup.Id
+		}
+	}
+	return 0
+}
+
+func (s *Store) roleName(id int) string {
+	for _, roleGroup := range s.roles {
+		if roleGroup.Id == id {
+			return roleGroup.Role
+		}
+	}
+	return ""
+}
+
+// subtree mirrors the recursive query of the postgres implementation.
+func (s *Store) subtree(ids []int, subdepartments bool) []int {
+	var (
+		result []int
+		seen   = make(map[int]bool)
+	)
+
+	for len(ids) > 0 {
+		var next []int
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			result = append(result, id)
+
+			if !subdepartments {
+				continue
+			}
+
+			for _, department := range s.departments {
+				if department.ParentId == id {
+					next = append(next, department.Id)
+				}
+			}
+		}
+		sort.Ints(next)
+		ids = next
+	}
+
+	return result
+}
+
+// cyclic reports whether putting department id below parentId closes a
+// loop, that is whether id is parentId or one of its ancestors.
+func (s *Store) cyclic(id, parentId int) bool {
+	seen := make(map[int]bool)
+	for ancestor := parentId; ancestor != 0; ancestor = s.departments[ancestor].ParentId {
+		if ancestor == id || seen[ancestor] {
+			return true
+		}
+		seen[ancestor] = true
+	}
+	return false
+}
+
+func contains(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether s contains substr ignoring case, like
+// ilike '%substr%'. A blank substr matches everything.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(strings.TrimSpace(substr)))
+}
+
+func sortedKeys(m interface{}) []int {
+	var keys []int
+	switch m := m.(type) {
+	case map[int]models.Department:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[int]models.Employee:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[int]models.Letter:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[int]models.Agreement:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+	return keys
+}
+
+func orNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
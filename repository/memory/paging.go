@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"reflect"
+	"sed/models"
+	"sed/repository"
+	"sort"
+)
+
+// paginate mirrors the keyset pagination of the postgres implementation.
+// It sorts rows, a slice, by order using key, which returns the sort field
+// and id of row i, and returns the bounds of the page after cursor,
+// skipping offset rows and keeping at most limit, or every row for 0.
+func paginate(rows interface{}, key func(i int) (interface{}, int), order repository.Order, cursor string, offset, limit uint) (from, to int, page models.Page, err error) {
+	n := reflect.ValueOf(rows).Len()
+	swap := reflect.Swapper(rows)
+
+	keys := make([]interface{}, n)
+	ids := make([]int, n)
+	for i := range keys {
+		keys[i], ids[i] = key(i)
+	}
+
+	sort.Sort(sorter{
+		n: n,
+		less: func(i, j int) bool {
+			return repository.Compare(order, keys[i], ids[i], keys[j], ids[j]) < 0
+		},
+		swap: func(i, j int) {
+			swap(i, j)
+			keys[i], keys[j] = keys[j], keys[i]
+			ids[i], ids[j] = ids[j], ids[i]
+		},
+	})
+
+	page.Total = n
+
+	if cursor != "" {
+		c, err := repository.ParseCursor(cursor, order)
+		if err != nil {
+			return 0, 0, page, err
+		}
+
+		for from < n {
+			before, err := c.Before(order, keys[from], ids[from])
+			if err != nil {
+				return 0, 0, page, err
+			}
+			if !before {
+				break
+			}
+			from++
+		}
+	}
+
+	from += int(offset)
+	if from > n {
+		from = n
+	}
+
+	to = n
+	if limit > 0 && from+int(limit) < n {
+		to = from + int(limit)
+		page.NextCursor = repository.NewCursor(order, keys[to-1], ids[to-1])
+	}
+
+	return from, to, page, nil
+}
+
+// sorter sorts rows and their keys together.
+type sorter struct {
+	n    int
+	less func(i, j int) bool
+	swap func(i, j int)
+}
+
+func (s sorter) Len() int           { return s.n }
+func (s sorter) Swap(i, j int)      { s.swap(i, j) }
+func (s sorter) Less(i, j int) bool { return s.less(i, j) }
@@ -0,0 +1,333 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sed/models"
+	"sed/repository"
+	"sort"
+	"time"
+)
+
+type Retention struct {
+	s *Store
+}
+
+func (r *Retention) Rules(_ context.Context) ([]models.RetentionRule, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var rules []models.RetentionRule
+	for _, rule := range r.s.rules {
+		for _, documentType := range r.s.documentTypes {
+			if documentType.Id == rule.DocumentTypeId {
+				rule.DocumentType.Type = documentType.Type
+				rule.DocumentType.Labels = documentType.Labels
+			}
+		}
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].DocumentTypeId < rules[j].DocumentTypeId
+	})
+
+	return rules, nil
+}
+
+func (r *Retention) SetRule(_ context.Context, rule models.RetentionRule) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	known := false
+	for _, documentType := range r.s.documentTypes {
+		known = known || documentType.Id == rule.DocumentTypeId
+	}
+	if !known {
+		return 0, fmt.Errorf("document type %d does not exist", rule.DocumentTypeId)
+	}
+
+	stored, ok := r.s.rules[rule.DocumentTypeId]
+	if !ok {
+		stored.Id = r.s.nextId()
+	}
+
+	stored.DocumentTypeId = rule.DocumentTypeId
+	stored.Years = rule.Years
+	stored.Article = rule.Article
+	r.s.rules[rule.DocumentTypeId] = stored
+
+	return stored.Id, nil
+}
+
+func (r *Retention) DeleteRule(_ context.Context, documentTypeId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.rules[documentTypeId]; !ok {
+		return repository.ErrNotFound
+	}
+
+	delete(r.s.rules, documentTypeId)
+
+	return nil
+}
+
+// retainUntil returns when the retention period of letter ends, or false
+// when it is kept permanently. The period of the case the letter is filed
+// into takes precedence over the rule of its document type.
+func (s *Store) retainUntil(letter models.Letter) (time.Time, bool) {
+	years := 0
+	if c, filed := s.cases[letter.CaseId]; filed {
+		years = c.RetentionYears
+	} else {
+		years = s.rules[letter.DocumentTypeId].Years
+	}
+
+	if years == 0 {
+		return time.Time{}, false
+	}
+
+	return letter.EntryDate.AddDate(years, 0, 0), true
+}
+
+// overdue reports whether the letter with id is past its retention period
+// and neither destroyed nor in an open act.
+func (s *Store) overdue(id int) (time.Time, bool) {
+	letter, ok := s.letter(id)
+	if !ok || letter.DestroyedAt != nil {
+		return time.Time{}, false
+	}
+
+	until, ok := s.retainUntil(letter)
+	if !ok || until.After(time.Now()) {
+		return time.Time{}, false
+	}
+
+	if s.inAct(id) {
+		return time.Time{}, false
+	}
+
+	return until, true
+}
+
+// inAct reports whether a draft, pending or approved act holds letter id.
+func (s *Store) inAct(id int) bool {
+	for _, act := range s.acts {
+		open := act.Status == models.ActDraft || act.Status == models.ActPending || act.Status == models.ActApproved
+		if open && contains(act.LetterIds, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Retention) Overdue(_ context.Context, filter models.RetentionFilter) (letters []models.Letter, page models.Page, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order := repository.ParseOrder(filter.Sort, "retain_until")
+	key, ok := repository.RetentionKeys[order.Field]
+	if !ok {
+		return nil, page, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+
+	for id, letter := range r.s.letters {
+		until, overdue := r.s.overdue(id)
+		if !overdue || filter.DocumentTypeId != 0 && letter.DocumentTypeId != filter.DocumentTypeId {
+			continue
+		}
+
+		view := models.Letter{
+			Id:                 letter.Id,
+			Name:               letter.Name,
+			Sender:             letter.Sender,
+			RegistrationNumber: letter.RegistrationNumber,
+			EntryDate:          letter.EntryDate,
+			RetainUntil:        &until,
+		}
+		for _, documentType := range r.s.documentTypes {
+			if documentType.Id == letter.DocumentTypeId {
+				view.DocumentType.Type = documentType.Type
+				view.DocumentType.Labels = documentType.Labels
+			}
+		}
+		letters = append(letters, view)
+	}
+
+	from, to, page, err := paginate(letters, func(i int) (interface{}, int) {
+		return key(letters[i]), letters[i].Id
+	}, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit)
+	if err != nil {
+		return nil, page, err
+	}
+
+	return letters[from:to], page, nil
+}
+
+func (r *Retention) CreateAct(_ context.Context, act models.DestructionAct) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.departments[act.DepartmentId]; !ok {
+		return 0, fmt.Errorf("department %d does not exist", act.DepartmentId)
+	}
+
+	for _, letterId := range act.LetterIds {
+		if _, overdue := r.s.overdue(letterId); !overdue {
+			return 0, repository.ErrNotDue
+		}
+	}
+
+	stored := models.DestructionAct{
+		Id:           r.s.nextId(),
+		DepartmentId: act.DepartmentId,
+		Status:       models.ActDraft,
+		Note:         act.Note,
+		LetterIds:    append([]int(nil), act.LetterIds...),
+		CreatedBy:    act.CreatedBy,
+		CreatedAt:    time.Now(),
+	}
+	sort.Ints(stored.LetterIds)
+	r.s.acts[stored.Id] = stored
+
+	return stored.Id, nil
+}
+
+func (r *Retention) Act(_ context.Context, id int) (models.DestructionAct, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	act, ok := r.s.acts[id]
+	if !ok {
+		return models.DestructionAct{}, repository.ErrNotFound
+	}
+
+	act.LetterIds = append([]int(nil), act.LetterIds...)
+	return act, nil
+}
+
+func (r *Retention) Acts(_ context.Context, filter models.DestructionActFilter) (acts []models.DestructionAct, page models.Page, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order := repository.ParseOrder(filter.Sort, "-id")
+	key, ok := repository.DestructionActKeys[order.Field]
+	if !ok {
+		return nil, page, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+
+	for _, act := range r.s.acts {
+		if filter.Status != "" && act.Status != filter.Status {
+			continue
+		}
+
+		act.LetterIds = append([]int(nil), act.LetterIds...)
+		acts = append(acts, act)
+	}
+
+	from, to, page, err := paginate(acts, func(i int) (interface{}, int) {
+		return key(acts[i]), acts[i].Id
+	}, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit)
+	if err != nil {
+		return nil, page, err
+	}
+
+	return acts[from:to], page, nil
+}
+
+// updateAct applies change to act id if it is in status.
+func (r *Retention) updateAct(id int, status string, change func(act *models.DestructionAct)) error {
+	act, ok := r.s.acts[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	if act.Status != status {
+		return repository.ErrActStatus
+	}
+
+	change(&act)
+	r.s.acts[id] = act
+
+	return nil
+}
+
+func (r *Retention) SubmitAct(_ context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.updateAct(id, models.ActDraft, func(act *models.DestructionAct) {
+		now := time.Now()
+		act.Status = models.ActPending
+		act.SubmittedAt = &now
+
+		for _, letterId := range act.LetterIds {
+			agreement := models.Agreement{
+				Id:               r.s.nextId(),
+				DepartmentId:     act.DepartmentId,
+				LetterId:         letterId,
+				AgreedAt:         now,
+				DestructionActId: act.Id,
+			}
+			r.s.agreements[agreement.Id] = agreement
+		}
+	})
+}
+
+func (r *Retention) SettleAct(_ context.Context, id int) (string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	status := ""
+	err := r.updateAct(id, models.ActPending, func(act *models.DestructionAct) {
+		refused, undecided := false, false
+		for _, agreement := range r.s.agreements {
+			if agreement.DestructionActId != act.Id {
+				continue
+			}
+
+			switch {
+			case agreement.DecidedAt == nil:
+				undecided = true
+			case !agreement.Agreed:
+				refused = true
+			}
+		}
+
+		switch {
+		case refused:
+			act.Status = models.ActRejected
+		case !undecided:
+			act.Status = models.ActApproved
+		}
+		status = act.Status
+	})
+
+	return status, err
+}
+
+func (r *Retention) ExecuteAct(_ context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.updateAct(id, models.ActApproved, func(act *models.DestructionAct) {
+		now := time.Now()
+		act.Status = models.ActExecuted
+		act.ExecutedAt = &now
+
+		for _, letterId := range act.LetterIds {
+			letter, ok := r.s.letters[letterId]
+			if !ok {
+				continue
+			}
+
+			letter.Content = ""
+			letter.DestroyedAt = &now
+			letter.DestructionActId = act.Id
+			letter.Version++
+			r.s.letters[letterId] = letter
+		}
+	})
+}
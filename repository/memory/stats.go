@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"context"
+	"sed/models"
+	"time"
+)
+
+type Stats struct {
+	s *Store
+}
+
+func (r *Stats) Stats(_ context.Context) (stats models.Stats, err error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, agreement := range r.s.agreements {
+		if _, live := r.s.letter(agreement.LetterId); live && agreement.DecidedAt == nil {
+			stats.PendingAgreements++
+		}
+	}
+
+	now := time.Now()
+	for _, describedLetter := range r.s.described {
+		letter, live := r.s.letter(describedLetter.LetterId)
+		if live && describedLetter.DueDate != nil && describedLetter.DueDate.Before(now) && letter.DistributionDate.IsZero() {
+			stats.OverdueAssignments++
+		}
+	}
+
+	return stats, nil
+}
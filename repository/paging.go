@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sed/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned by lists given a cursor they did not issue
+// for the same sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Order is a parsed sort parameter: a field of the list's whitelist and its
+// direction. Rows with equal fields are ordered by id in the same
+// direction, which makes the order total and keyset pagination stable.
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// ParseOrder parses sort, "field" or "-field" for descending, falling back
+// to fallback when sort is empty.
+func ParseOrder(sort, fallback string) Order {
+	if sort == "" {
+		sort = fallback
+	}
+
+	if strings.HasPrefix(sort, "-") {
+		return Order{Field: sort[1:], Descending: true}
+	}
+	return Order{Field: sort}
+}
+
+func (o Order) String() string {
+	if o.Descending {
+		return "-" + o.Field
+	}
+	return o.Field
+}
+
+// Cursor is the position of the last row of a page: the value of its sort
+// field and its id.
+type Cursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	Id   int    `json:"i"`
+}
+
+// NewCursor returns the encoded cursor of the row with id whose sort field
+// has the value key, an int, a string or a time.Time.
+func NewCursor(order Order, key interface{}, id int) string {
+	data, _ := json.Marshal(Cursor{Sort: order.String(), Key: formatKey(key), Id: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes cursor and checks that it was issued for order.
+func ParseCursor(cursor string, order Order) (c Cursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	if c.Sort != order.String() {
+		return c, fmt.Errorf("%w: issued for sort %q", ErrInvalidCursor, c.Sort)
+	}
+
+	return c, nil
+}
+
+// Before reports whether the row with id and sort field key comes before
+// or at c in order, that is, whether a page starting after c skips it.
+func (c Cursor) Before(order Order, key interface{}, id int) (bool, error) {
+	other, err := parseKey(key, c.Key)
+	if err != nil {
+		return false, err
+	}
+
+	return Compare(order, key, id, other, c.Id) <= 0, nil
+}
+
+// Compare orders the row with sort field a and id aId before the row with
+// b and bId when negative, after when positive. Both fields must have the
+// same type.
+func Compare(order Order, a interface{}, aId int, b interface{}, bId int) int {
+	cmp := 0
+	switch a := a.(type) {
+	case int:
+		cmp = compareInt(a, b.(int))
+	case time.Time:
+		switch b := b.(time.Time); {
+		case a.Before(b):
+			cmp = -1
+		case a.After(b):
+			cmp = 1
+		}
+	default:
+		cmp = strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+
+	if cmp == 0 {
+		cmp = compareInt(aId, bId)
+	}
+	if order.Descending {
+		cmp = -cmp
+	}
+
+	return cmp
+}
+
+// formatKey renders a sort field so that Postgres can cast it back to the
+// column type.
+func formatKey(key interface{}) string {
+	switch key := key.(type) {
+	case int:
+		return strconv.Itoa(key)
+	case time.Time:
+		return key.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(key)
+	}
+}
+
+// parseKey parses a field rendered by formatKey into the type of like.
+func parseKey(like interface{}, formatted string) (key interface{}, err error) {
+	switch like.(type) {
+	case int:
+		key, err = strconv.Atoi(formatted)
+	case time.Time:
+		key, err = time.Parse(time.RFC3339Nano, formatted)
+	default:
+		key = formatted
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return key, nil
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// The sort keys of each list by the field names accepted by the sort
+// parameter of its filter. Both implementations build cursors from them.
+var (
+	LetterKeys = map[string]func(models.Letter) interface{}{
+		"id":         func(l models.Letter) interface{} { return l.Id },
+		"name":       func(l models.Letter) interface{} { return l.Name },
+		"sender":     func(l models.Letter) interface{} { return l.Sender },
+		"entry_date": func(l models.Letter) interface{} { return l.EntryDate },
+	}
+	TrashKeys = map[string]func(models.Letter) interface{}{
+		"id":         func(l models.Letter) interface{} { return l.Id },
+		"deleted_at": func(l models.Letter) interface{} { return *l.DeletedAt },
+	}
+	RetentionKeys = map[string]func(models.Letter) interface{}{
+		"id":           func(l models.Letter) interface{} { return l.Id },
+		"retain_until": func(l models.Letter) interface{} { return *l.RetainUntil },
+	}
+	DestructionActKeys = map[string]func(models.DestructionAct) interface{}{
+		"id": func(a models.DestructionAct) interface{} { return a.Id },
+	}
+	CaseKeys = map[string]func(models.Case) interface{}{
+		"id":    func(c models.Case) interface{} { return c.Id },
+		"index": func(c models.Case) interface{} { return c.Index },
+	}
+	EmployeeKeys = map[string]func(models.Employee) interface{}{
+		"id":        func(e models.Employee) interface{} { return e.Id },
+		"full_name": func(e models.Employee) interface{} { return e.FullName },
+		"email":     func(e models.Employee) interface{} { return e.Email },
+	}
+	DepartmentKeys = map[string]func(models.Department) interface{}{
+		"id":   func(d models.Department) interface{} { return d.Id },
+		"name": func(d models.Department) interface{} { return d.Name },
+	}
+	AgreementKeys = map[string]func(models.Agreement) interface{}{
+		"id":        func(a models.Agreement) interface{} { return a.Id },
+		"agreed_at": func(a models.Agreement) interface{} { return a.AgreedAt },
+	}
+	RoleKeys = map[string]func(models.RoleGroup) interface{}{
+		"id":   func(r models.RoleGroup) interface{} { return r.Id },
+		"role": func(r models.RoleGroup) interface{} { return r.Role },
+	}
+	AuditKeys = map[string]func(models.AuditEntry) interface{}{
+		"id": func(e models.AuditEntry) interface{} { return e.Id },
+	}
+)
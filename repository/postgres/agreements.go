@@ -0,0 +1,237 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/models"
+	"sed/repository"
+)
+
+type Agreements struct {
+	pool *pgxpool.Pool
+}
+
+// inboxSubtree collects the departments an employee, $1, heads and, when
+// $2 is set, the departments below them.
+const inboxSubtree = `with recursive subtree as (
+    select d.id
+    from departments d
+             left join employees e on e.id = $1
+             left join role_group rg on e.role_id = rg.id
+    where d.head_id = $1
+       or d.deputy_id = $1
+       or (d.head_id is null and d.id = e.department_id and rg.role in ('ADMIN', 'DEP_HEAD'))
+    union
+    select d.id
+    from departments d
+             join subtree s on d.parent_id = s.id
+    where $2
+)
+`
+
+// inboxWhere restricts agreements to the subtree, leaving out those of
+// letters in the trash.
+const inboxWhere = `from agreements a
+         left join letters l on a.letter_id = l.id
+         left join departments d on a.department_id = d.id
+where a.department_id in (select id from subtree)
+  and l.deleted_at is null
+`
+
+// agreementColumns are the columns agreements can be sorted by.
+var agreementColumns = map[string]column{
+	"id":        {"a.id", "int"},
+	"agreed_at": {"a.agreed_at", "timestamptz"},
+}
+
+func (r *Agreements) Inbox(ctx context.Context, employeeId int, filter models.AgreementFilter) (agreements []models.Agreement, page models.Page, err error) {
+	order := repository.ParseOrder(filter.Sort, "-id")
+	args := []interface{}{employeeId, filter.Subdepartments}
+
+	err = r.pool.QueryRow(ctx, inboxSubtree+`select count(*) `+inboxWhere+`;`, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, page, err
+	}
+
+	keyset, tail, args, err := pageClauses(agreementColumns, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit, args)
+	if err != nil {
+		return nil, page, err
+	}
+
+	rows, err := r.pool.Query(
+		ctx,
+		inboxSubtree+`select a.id, l.id, d.id, d.name, l.name, l.entry_date, a.viewed, a.agreed, coalesce(a.agreed_at, now()),
+       coalesce(a.destruction_act_id, 0)
+`+inboxWhere+keyset+tail+`;`,
+		args...,
+	)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		agreement := models.Agreement{}
+
+		err = rows.Scan(
+			&agreement.Id,
+			&agreement.Letter.Id,
+			&agreement.Department.Id,
+			&agreement.Department.Name,
+			&agreement.Letter.Name,
+			&agreement.Letter.EntryDate,
+			&agreement.Viewed,
+			&agreement.Agreed,
+			&agreement.AgreedAt,
+			&agreement.DestructionActId,
+		)
+		if err != nil {
+			return nil, page, err
+		}
+
+		agreements = append(agreements, agreement)
+	}
+
+	if filter.RowsLimit > 0 && uint(len(agreements)) > filter.RowsLimit {
+		agreements = agreements[:filter.RowsLimit]
+		last := agreements[len(agreements)-1]
+		page.NextCursor = repository.NewCursor(order, repository.AgreementKeys[order.Field](last), last.Id)
+	}
+
+	return agreements, page, rows.Err()
+}
+
+func (r *Agreements) Create(ctx context.Context, agreement models.Agreement) (id int, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`insert into agreements (department_id, letter_id, viewed, agreed_at)
+select $1, $2, false, now()
+where exists(select 1 from letters where id = $2 and deleted_at is null)
+returning id;`,
+		agreement.DepartmentId,
+		agreement.LetterId,
+	).Scan(&id)
+	return id, notFound(err)
+}
+
+func (r *Agreements) Get(ctx context.Context, id int) (agreement models.Agreement, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`select a.id,
+       l.id,
+       d.id,
+       d.name,
+       l.name,
+       l.entry_date,
+       a.viewed,
+       coalesce(a.agreed_at, now()),
+       a.agreed,
+       coalesce(a.destruction_act_id, 0)
+from agreements a
+         left join letters l on a.letter_id = l.id
+         left join departments d on a.department_id = d.id
+where a.id = $1;`,
+		id,
+	).Scan(
+		&agreement.Id,
+		&agreement.Letter.Id,
+		&agreement.Department.Id,
+		&agreement.Department.Name,
+		&agreement.Letter.Name,
+		&agreement.Letter.EntryDate,
+		&agreement.Viewed,
+		&agreement.AgreedAt,
+		&agreement.Agreed,
+		&agreement.DestructionActId,
+	)
+	return agreement, notFound(err)
+}
+
+func (r *Agreements) MarkViewed(ctx context.Context, id int) error {
+	rtn, err := r.pool.Exec(
+		ctx,
+		`update agreements
+set viewed = true
+where id = $1;`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rtn.RowsAffected() < 1 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Agreements) Holds(ctx context.Context, id, employeeId int) error {
+	var inbox bool
+	err := r.pool.QueryRow(
+		ctx,
+		inboxSubtree+`select department_id in (select id from subtree)
+from agreements
+where id = $3;`,
+		employeeId,
+		true,
+		id,
+	).Scan(&inbox)
+	switch {
+	case err != nil:
+		return notFound(err)
+	case !inbox:
+		return repository.ErrNotAllowed
+	}
+	return nil
+}
+
+func (r *Agreements) Decide(ctx context.Context, id, employeeId int, agree bool) error {
+	rtn, err := r.pool.Exec(
+		ctx,
+		inboxSubtree+`update agreements
+set agreed     = $3,
+    decided_at = now()
+where id = $4
+  and decided_at is null
+  and department_id in (select id from subtree);`,
+		employeeId,
+		true,
+		agree,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rtn.RowsAffected() < 1 {
+		return r.undecidable(ctx, id, employeeId)
+	}
+
+	return nil
+}
+
+// undecidable explains why the decision of employeeId on agreement id
+// matched no row: it is missing, outside the inbox of the employee or
+// already decided.
+func (r *Agreements) undecidable(ctx context.Context, id, employeeId int) error {
+	var inbox, decided bool
+	err := r.pool.QueryRow(
+		ctx,
+		inboxSubtree+`select department_id in (select id from subtree), decided_at is not null
+from agreements
+where id = $3;`,
+		employeeId,
+		true,
+		id,
+	).Scan(&inbox, &decided)
+	switch {
+	case err != nil:
+		return notFound(err)
+	case !inbox:
+		return repository.ErrNotAllowed
+	case decided:
+		return repository.ErrConflict
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/models"
+	"sed/repository"
+	"time"
+)
+
+// auditLockKey is the advisory lock serializing appends to the audit log.
+const auditLockKey = 7_391_002_027
+
+type Audit struct {
+	pool *pgxpool.Pool
+}
+
+// auditColumns are the columns the audit log can be sorted by.
+var auditColumns = map[string]column{
+	"id": {"a.id", "int"},
+}
+
+func (r *Audit) Append(ctx context.Context, entry models.AuditEntry) error {
+	// Postgres keeps microseconds; the hash has to match what is read back.
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	return r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `select pg_advisory_xact_lock($1);`, auditLockKey)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(
+			ctx,
+			`select coalesce((select hash from audit_log order by id desc limit 1), '');`,
+		).Scan(&entry.PreviousHash)
+		if err != nil {
+			return err
+		}
+
+		entry.Hash = repository.AuditHash(entry)
+
+		_, err = tx.Exec(
+			ctx,
+			`insert into audit_log (actor_id, action, entity, entity_id, changes, ip, request_id, created_at, previous_hash, hash)
+values (nullif($1, 0), $2, $3, $4, $5, $6, $7, $8, $9, $10);`,
+			entry.ActorId,
+			entry.Action,
+			entry.Entity,
+			entry.EntityId,
+			entry.Changes,
+			entry.IP,
+			entry.RequestId,
+			entry.CreatedAt,
+			entry.PreviousHash,
+			entry.Hash,
+		)
+		return err
+	})
+}
+
+func (r *Audit) List(ctx context.Context, filter models.AuditFilter) (entries []models.AuditEntry, page models.Page, err error) {
+	order := repository.ParseOrder(filter.Sort, "-id")
+	query, args := auditFilters(filter)
+
+	from := `from audit_log a
+where true
+` + query
+
+	page.Total, err = count(ctx, r.pool, `select a.id `+from, args...)
+	if err != nil {
+		return nil, page, err
+	}
+
+	keyset, tail, args, err := pageClauses(auditColumns, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit, args)
+	if err != nil {
+		return nil, page, err
+	}
+
+	rows, err := r.pool.Query(
+		ctx,
+		`select a.id,
+       coalesce(a.actor_id, 0),
+       a.action,
+       a.entity,
+       a.entity_id,
+       a.changes,
+       a.ip,
+       a.request_id,
+       a.created_at,
+       a.previous_hash,
+       a.hash
+`+from+keyset+tail+`;`,
+		args...,
+	)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := models.AuditEntry{}
+		err = rows.Scan(
+			&entry.Id,
+			&entry.ActorId,
+			&entry.Action,
+			&entry.Entity,
+			&entry.EntityId,
+			&entry.Changes,
+			&entry.IP,
+			&entry.RequestId,
+			&entry.CreatedAt,
+			&entry.PreviousHash,
+			&entry.Hash,
+		)
+		if err != nil {
+			return nil, page, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if filter.RowsLimit > 0 && uint(len(entries)) > filter.RowsLimit {
+		entries = entries[:filter.RowsLimit]
+		last := entries[len(entries)-1]
+		page.NextCursor = repository.NewCursor(order, repository.AuditKeys[order.Field](last), last.Id)
+	}
+
+	return entries, page, rows.Err()
+}
+
+// auditFilters turns filter into "and ..." conditions.
+func auditFilters(filter models.AuditFilter) (query string, args []interface{}) {
+	if filter.Entity != "" {
+		args = append(args, filter.Entity)
+		query += fmt.Sprintf(" and a.entity = $%d ", len(args))
+	}
+
+	if filter.EntityId != 0 {
+		args = append(args, filter.EntityId)
+		query += fmt.Sprintf(" and a.entity_id = $%d ", len(args))
+	}
+
+	if filter.ActorId != 0 {
+		args = append(args, filter.ActorId)
+		query += fmt.Sprintf(" and a.actor_id = $%d ", len(args))
+	}
+
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		query += fmt.Sprintf(" and a.created_at >= $%d ", len(args))
+	}
+
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		query += fmt.Sprintf(" and a.created_at < $%d ", len(args))
+	}
+
+	return query, args
+}
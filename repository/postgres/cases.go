@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/models"
+	"sed/repository"
+	"strings"
+)
+
+type Cases struct {
+	pool *pgxpool.Pool
+}
+
+// caseColumns are the columns cases can be sorted by.
+var caseColumns = map[string]column{
+	"id":    {"k.id", "int"},
+	"index": {"k.index", "text"},
+}
+
+// caseSelect selects cases with the count and entry dates of the letters
+// filed into them.
+const caseSelect = `select k.id,
+       k.department_id,
+       k.year,
+       k.index,
+       k.title,
+       k.retention_years,
+       k.article,
+       k.created_at,
+       k.closed_at,
+       f.letters,
+       f.first_entry_date,
+       f.last_entry_date
+from cases k
+         left join lateral (select count(*)          as letters,
+                                   min(l.entry_date) as first_entry_date,
+                                   max(l.entry_date) as last_entry_date
+                            from letters l
+                            where l.case_id = k.id
+                              and l.deleted_at is null) f on true
+`
+
+func scanCase(row pgx.Row) (c models.Case, err error) {
+	err = row.Scan(
+		&c.Id,
+		&c.DepartmentId,
+		&c.Year,
+		&c.Index,
+		&c.Title,
+		&c.RetentionYears,
+		&c.Article,
+		&c.CreatedAt,
+		&c.ClosedAt,
+		&c.Letters,
+		&c.FirstEntryDate,
+		&c.LastEntryDate,
+	)
+	return c, err
+}
+
+func (r *Cases) List(ctx context.Context, filter models.CaseFilter) (cases []models.Case, page models.Page, err error) {
+	order := repository.ParseOrder(filter.Sort, "-id")
+
+	var (
+		query string
+		args  []interface{}
+	)
+	if filter.DepartmentId != 0 {
+		args = append(args, filter.DepartmentId)
+		query += fmt.Sprintf(" and k.department_id = $%d ", len(args))
+	}
+	if filter.Year != 0 {
+		args = append(args, filter.Year)
+		query += fmt.Sprintf(" and k.year = $%d ", len(args))
+	}
+	if index := strings.TrimSpace(filter.Index); index != "" {
+		args = append(args, index)
+		query += fmt.Sprintf(" and k.index = $%d ", len(args))
+	}
+
+	where := `where true
+` + query
+
+	page.Total, err = count(ctx, r.pool, `select k.id from cases k `+where, args...)
+	if err != nil {
+		return nil, page, err
+	}
+
+	keyset, tail, args, err := pageClauses(caseColumns, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit, args)
+	if err != nil {
+		return nil, page, err
+	}
+
+	rows, err := r.pool.Query(ctx, caseSelect+where+keyset+tail+`;`, args...)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanCase(rows)
+		if err != nil {
+			return nil, page, err
+		}
+
+		cases = append(cases, c)
+	}
+
+	if filter.RowsLimit > 0 && uint(len(cases)) > filter.RowsLimit {
+		cases = cases[:filter.RowsLimit]
+		last := cases[len(cases)-1]
+		page.NextCursor = repository.NewCursor(order, repository.CaseKeys[order.Field](last), last.Id)
+	}
+
+	return cases, page, rows.Err()
+}
+
+func (r *Cases) Get(ctx context.Context, id int) (models.Case, error) {
+	c, err := scanCase(r.pool.QueryRow(ctx, caseSelect+`where k.id = $1;`, id))
+	return c, notFound(err)
+}
+
+func (r *Cases) Create(ctx context.Context, c models.Case) (id int, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`insert into cases (department_id, year, index, title, retention_years, article)
+values ($1, $2, $3, $4, $5, $6)
+on conflict (department_id, year, index) do nothing
+returning id;`,
+		c.DepartmentId,
+		c.Year,
+		c.Index,
+		c.Title,
+		c.RetentionYears,
+		c.Article,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, repository.ErrDuplicate
+	}
+	return id, err
+}
+
+func (r *Cases) SetClosed(ctx context.Context, id int, closed bool) error {
+	rtn, err := r.pool.Exec(
+		ctx,
+		`update cases
+set closed_at = case when $2 then coalesce(closed_at, now()) end
+where id = $1;`,
+		id,
+		closed,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rtn.RowsAffected() < 1 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Cases) File(ctx context.Context, letterId, caseId int) error {
+	return r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var closed bool
+		err := tx.QueryRow(
+			ctx,
+			`select exists(select 1 from cases k where k.id = l.case_id and k.closed_at is not null)
+from letters l
+where l.id = $1
+  and l.deleted_at is null
+    for update;`,
+			letterId,
+		).Scan(&closed)
+		if err != nil {
+			return notFound(err)
+		}
+
+		var targetClosed bool
+		err = tx.QueryRow(
+			ctx,
+			`select closed_at is not null
+from cases
+where id = $1
+    for share;`,
+			caseId,
+		).Scan(&targetClosed)
+		if err != nil {
+			return notFound(err)
+		}
+
+		if closed || targetClosed {
+			return repository.ErrCaseClosed
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`update letters
+set case_id = $2,
+    version = version + 1
+where id = $1;`,
+			letterId,
+			caseId,
+		)
+		return err
+	})
+}
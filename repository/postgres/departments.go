@@ -0,0 +1,384 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/models"
+	"sed/repository"
+)
+
+type Departments struct {
+	pool *pgxpool.Pool
+}
+
+// departmentSelect is the column list shared by the queries returning
+// departments with their head and deputy.
+const departmentSelect = `select d.id,
+       coalesce(d.parent_id, 0),
+       d.name,
+       d.internal_number,
+       d.phone,
+       coalesce(h.id, 0),
+       coalesce(h.full_name, ''),
+       coalesce(h.position, ''),
+       coalesce(dp.id, 0),
+       coalesce(dp.full_name, ''),
+       coalesce(dp.position, ''),
+       d.archived_at,
+       coalesce(d.merged_into_id, 0),
+       d.version
+from departments d
+         left join employees h on d.head_id = h.id
+         left join employees dp on d.deputy_id = dp.id
+`
+
+func scanDepartment(row pgx.Row) (department models.Department, err error) {
+	var head, deputy models.Employee
+
+	err = row.Scan(
+		&department.Id,
+		&department.ParentId,
+		&department.Name,
+		&department.InternalNumber,
+		&department.Phone,
+		&head.Id,
+		&head.FullName,
+		&head.Position,
+		&deputy.Id,
+		&deputy.FullName,
+		&deputy.Position,
+		&department.ArchivedAt,
+		&department.MergedIntoId,
+		&department.Version,
+	)
+
+	if head.Id != 0 {
+		department.HeadId = head.Id
+		department.Head = &head
+	}
+
+	if deputy.Id != 0 {
+		department.DeputyId = deputy.Id
+		department.Deputy = &deputy
+	}
+
+	return department, err
+}
+
+// departmentColumns are the columns departments can be sorted by.
+var departmentColumns = map[string]column{
+	"id":   {"d.id", "int"},
+	"name": {"d.name", "text"},
+}
+
+func (r *Departments) List(ctx context.Context, filter models.DepartmentFilter) (departments []models.Department, page models.Page, err error) {
+	order := repository.ParseOrder(filter.Sort, "-id")
+	where := `where ($1 or d.archived_at is null)
+`
+
+	page.Total, err = count(ctx, r.pool, `select d.id from departments d `+where, filter.Archived)
+	if err != nil {
+		return nil, page, err
+	}
+
+	keyset, tail, args, err := pageClauses(departmentColumns, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit, []interface{}{filter.Archived})
+	if err != nil {
+		return nil, page, err
+	}
+
+	rows, err := r.pool.Query(ctx, departmentSelect+where+keyset+tail+`;`, args...)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		department, err := scanDepartment(rows)
+		if err != nil {
+			return nil, page, err
+		}
+
+		departments = append(departments, department)
+	}
+
+	if filter.RowsLimit > 0 && uint(len(departments)) > filter.RowsLimit {
+		departments = departments[:filter.RowsLimit]
+		last := departments[len(departments)-1]
+		page.NextCursor = repository.NewCursor(order, repository.DepartmentKeys[order.Field](last), last.Id)
+	}
+
+	return departments, page, rows.Err()
+}
+
+func (r *Departments) Get(ctx context.Context, id int) (models.Department, error) {
+	department, err := scanDepartment(r.pool.QueryRow(ctx, departmentSelect+`where d.id = $1;`, id))
+	return department, notFound(err)
+}
+
+func (r *Departments) Create(ctx context.Context, department models.Department) (id int, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`insert into departments (name, internal_number, phone, parent_id)
+values ($1, $2, $3, nullif($4, 0))
+returning id;`,
+		department.Name,
+		department.InternalNumber,
+		department.Phone,
+		department.ParentId,
+	).Scan(&id)
+	return id, err
+}
+
+func (r *Departments) Update(ctx context.Context, department models.Department) (version int, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`update departments
+set name            = $1,
+    internal_number = $2,
+    phone           = $3,
+    version         = version + 1
+where id = $4
+  and version = $5
+  and archived_at is null
+returning version;`,
+		department.Name,
+		department.InternalNumber,
+		department.Phone,
+		department.Id,
+		department.Version,
+	).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, conflict(ctx, r.pool, `select 1 from departments where id = $1 and archived_at is null`, department.Id)
+	}
+	return version, err
+}
+
+func (r *Departments) Move(ctx context.Context, id, parentId int) error {
+	return r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		err := acyclic(ctx, tx, id, parentId)
+		if err != nil {
+			return err
+		}
+
+		rtn, err := tx.Exec(
+			ctx,
+			`update departments
+set parent_id = nullif($1, 0),
+    version   = version + 1
+where id = $2
+  and archived_at is null;`,
+			parentId,
+			id,
+		)
+		if err != nil {
+			return err
+		}
+
+		if rtn.RowsAffected() < 1 {
+			return repository.ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// acyclic locks department id and the chain of ancestors of parentId, so
+// that concurrent moves wait for each other, and returns
+// repository.ErrCycle when id is among them.
+func acyclic(ctx context.Context, tx pgx.Tx, id, parentId int) error {
+	_, err := tx.Exec(
+		ctx,
+		`select id
+from departments
+where id = $1
+    for update;`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[int]bool)
+	for ancestor := parentId; ancestor != 0; {
+		if ancestor == id || seen[ancestor] {
+			return repository.ErrCycle
+		}
+		seen[ancestor] = true
+
+		err = tx.QueryRow(
+			ctx,
+			`select coalesce(parent_id, 0)
+from departments
+where id = $1
+    for update;`,
+			ancestor,
+		).Scan(&ancestor)
+		if err != nil {
+			return notFound(err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Departments) SetHeads(ctx context.Context, id, headId, deputyId int) error {
+	return r.exec(
+		ctx,
+		`update departments
+set head_id   = nullif($1, 0),
+    deputy_id = nullif($2, 0),
+    version   = version + 1
+where id = $3
+  and archived_at is null;`,
+		headId,
+		deputyId,
+		id,
+	)
+}
+
+func (r *Departments) IsArchived(ctx context.Context, id int) (archived bool, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`select archived_at is not null
+from departments
+where id = $1;`,
+		id,
+	).Scan(&archived)
+	return archived, notFound(err)
+}
+
+func (r *Departments) Members(ctx context.Context, id int) (employees int, children int, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`select (select count(*) from employees where department_id = $1),
+       (select count(*) from departments where parent_id = $1 and archived_at is null);`,
+		id,
+	).Scan(&employees, &children)
+	return employees, children, err
+}
+
+func (r *Departments) Archive(ctx context.Context, id int) error {
+	return r.exec(
+		ctx,
+		`update departments
+set archived_at = now(),
+    head_id     = null,
+    deputy_id   = null,
+    version     = version + 1
+where id = $1
+  and archived_at is null;`,
+		id,
+	)
+}
+
+func (r *Departments) Merge(ctx context.Context, sourceId, targetId, actorId int) error {
+	return r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		err := acyclic(ctx, tx, sourceId, targetId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`insert into employee_transfers (employee_id, from_department_id, to_department_id, transferred_by)
+select id, $1, $2, nullif($3, 0)
+from employees
+where department_id = $1;`,
+			sourceId,
+			targetId,
+			actorId,
+		)
+		if err != nil {
+			return err
+		}
+
+		statements := []string{
+			`update employees
+set department_id = $2
+where department_id = $1;`,
+			`update departments
+set parent_id = $2,
+    version   = version + 1
+where parent_id = $1;`,
+			`update agreements
+set department_id = $2
+where department_id = $1
+  and decided_at is null;`,
+			`update described_letters dl
+set department_id = $2
+from letters l
+where l.id = dl.letter_id
+  and dl.department_id = $1
+  and l.distribution_date is null;`,
+			`update departments
+set archived_at    = now(),
+    merged_into_id = $2,
+    head_id        = null,
+    deputy_id      = null,
+    version        = version + 1
+where id = $1;`,
+		}
+
+		for _, statement := range statements {
+			_, err = tx.Exec(ctx, statement, sourceId, targetId)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *Departments) Subtree(ctx context.Context, id int, subdepartments bool) (ids []int, err error) {
+	rows, err := r.pool.Query(
+		ctx,
+		`with recursive subtree as (
+    select id
+    from departments
+    where id = $1
+    union
+    select d.id
+    from departments d
+             join subtree s on d.parent_id = s.id
+    where $2
+)
+select id
+from subtree;`,
+		id,
+		subdepartments,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids = []int{}
+	for rows.Next() {
+		departmentId := 0
+		err = rows.Scan(&departmentId)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, departmentId)
+	}
+
+	return ids, rows.Err()
+}
+
+// exec runs an update and reports repository.ErrNotFound when no row matched.
+func (r *Departments) exec(ctx context.Context, sql string, args ...interface{}) error {
+	rtn, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	if rtn.RowsAffected() < 1 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,326 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/models"
+	"sed/repository"
+	"strings"
+)
+
+type Employees struct {
+	pool *pgxpool.Pool
+}
+
+// employeeSelect is the column list shared by every query returning employees.
+const employeeSelect = `select e.id,
+       e.full_name,
+       coalesce(e.position, ''),
+       coalesce(e.role_id, 0),
+       coalesce(rg.role, ''),
+       e.email,
+       e.language,
+       coalesce(d.id, 0),
+       coalesce(d.name, ''),
+       coalesce(d.head_id, 0),
+       coalesce(d.deputy_id, 0)
+` + employeeFrom
+
+// employeeFrom joins the role and department of employees.
+const employeeFrom = `from employees e
+         left join role_group rg on e.role_id = rg.id
+         left join departments d on e.department_id = d.id
+`
+
+// employeeColumns are the columns employees can be sorted by.
+var employeeColumns = map[string]column{
+	"id":        {"e.id", "int"},
+	"full_name": {"e.full_name", "text"},
+	"email":     {"e.email", "text"},
+}
+
+func scanEmployee(row pgx.Row) (employee models.Employee, err error) {
+	err = row.Scan(
+		&employee.Id,
+		&employee.FullName,
+		&employee.Position,
+		&employee.RoleId,
+		&employee.Role.Role,
+		&employee.Email,
+		&employee.Language,
+		&employee.DepartmentId,
+		&employee.Department.Name,
+		&employee.Department.HeadId,
+		&employee.Department.DeputyId,
+	)
+	employee.Role.Id = employee.RoleId
+	employee.Department.Id = employee.DepartmentId
+	return employee, err
+}
+
+func (r *Employees) queryEmployees(ctx context.Context, sql string, args ...interface{}) (employees []models.Employee, err error) {
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		employee, err := scanEmployee(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		employees = append(employees, employee)
+	}
+
+	return employees, rows.Err()
+}
+
+func (r *Employees) ByToken(ctx context.Context, token string) (employee models.Employee, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`select id, language
+from employees
+where token = $1;`,
+		token,
+	).Scan(&employee.Id, &employee.Language)
+	return employee, notFound(err)
+}
+
+func (r *Employees) ByEmail(ctx context.Context, email string) (employee models.Employee, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`select e.id, e.password, coalesce(rg.role, ''), e.full_name, e.email
+from employees e
+         left join role_group rg on e.role_id = rg.id
+where email = $1;`,
+		email,
+	).Scan(
+		&employee.Id,
+		&employee.Password,
+		&employee.Role.Role,
+		&employee.FullName,
+		&employee.Email,
+	)
+	return employee, notFound(err)
+}
+
+func (r *Employees) SetToken(ctx context.Context, id int, token string) error {
+	rtn, err := r.pool.Exec(
+		ctx,
+		`update employees set token = $1 where id = $2;`,
+		token,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rtn.RowsAffected() < 1 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Employees) Get(ctx context.Context, id int) (models.Employee, error) {
+	employee, err := scanEmployee(r.pool.QueryRow(ctx, employeeSelect+`where e.id = $1;`, id))
+	return employee, notFound(err)
+}
+
+func (r *Employees) List(ctx context.Context, filter models.EmployeeFilter) (employees []models.Employee, page models.Page, err error) {
+	order := repository.ParseOrder(filter.Sort, "-id")
+	where := `where ($1 = '' or e.full_name ilike '%' || $1 || '%')
+  and ($2 = '' or e.email ilike '%' || $2 || '%')
+  and ($3 = '' or d.name ilike '%' || $3 || '%')
+  and ($4::int[] is null or d.id = any ($4))
+`
+	args := []interface{}{
+		strings.TrimSpace(filter.FullName),
+		strings.TrimSpace(filter.Email),
+		strings.TrimSpace(filter.Department),
+		filter.DepartmentIds,
+	}
+
+	page.Total, err = count(ctx, r.pool, `select e.id `+employeeFrom+where, args...)
+	if err != nil {
+		return nil, page, err
+	}
+
+	keyset, tail, args, err := pageClauses(employeeColumns, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit, args)
+	if err != nil {
+		return nil, page, err
+	}
+
+	employees, err = r.queryEmployees(ctx, employeeSelect+where+keyset+tail+`;`, args...)
+	if err != nil {
+		return nil, page, err
+	}
+
+	if filter.RowsLimit > 0 && uint(len(employees)) > filter.RowsLimit {
+		employees = employees[:filter.RowsLimit]
+		last := employees[len(employees)-1]
+		page.NextCursor = repository.NewCursor(order, repository.EmployeeKeys[order.Field](last), last.Id)
+	}
+
+	return employees, page, nil
+}
+
+func (r *Employees) All(ctx context.Context) ([]models.Employee, error) {
+	return r.queryEmployees(ctx, employeeSelect+`order by e.full_name;`)
+}
+
+func (r *Employees) Update(ctx context.Context, employee models.Employee, actorId int) error {
+	return r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		previousDepartmentId := 0
+		err := tx.QueryRow(
+			ctx,
+			`select coalesce(department_id, 0)
+from employees
+where id = $1
+    for update;`,
+			employee.Id,
+		).Scan(&previousDepartmentId)
+		if err != nil {
+			return notFound(err)
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`update employees
+set full_name     = $1,
+    role_id       = $2,
+    department_id = nullif($3, 0),
+    position      = $4,
+    language      = $5
+where id = $6;`,
+			employee.FullName,
+			employee.RoleId,
+			employee.DepartmentId,
+			employee.Position,
+			employee.Language,
+			employee.Id,
+		)
+		if err != nil {
+			return err
+		}
+
+		if previousDepartmentId == employee.DepartmentId {
+			return nil
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`insert into employee_transfers (employee_id, from_department_id, to_department_id, transferred_by)
+values ($1, nullif($2, 0), nullif($3, 0), nullif($4, 0));`,
+			employee.Id,
+			previousDepartmentId,
+			employee.DepartmentId,
+			actorId,
+		)
+		return err
+	})
+}
+
+func (r *Employees) Transfers(ctx context.Context, employeeId int) (transfers []models.EmployeeTransfer, err error) {
+	rows, err := r.pool.Query(
+		ctx,
+		`select t.id,
+       t.employee_id,
+       coalesce(t.from_department_id, 0),
+       coalesce(fd.name, ''),
+       coalesce(t.to_department_id, 0),
+       coalesce(td.name, ''),
+       coalesce(t.transferred_by, 0),
+       t.transferred_at
+from employee_transfers t
+         left join departments fd on t.from_department_id = fd.id
+         left join departments td on t.to_department_id = td.id
+where t.employee_id = $1
+order by t.transferred_at desc, t.id desc;`,
+		employeeId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		transfer := models.EmployeeTransfer{}
+
+		err = rows.Scan(
+			&transfer.Id,
+			&transfer.EmployeeId,
+			&transfer.FromDepartmentId,
+			&transfer.FromDepartment.Name,
+			&transfer.ToDepartmentId,
+			&transfer.ToDepartment.Name,
+			&transfer.TransferredBy,
+			&transfer.TransferredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, rows.Err()
+}
+
+// roleColumns are the columns role groups can be sorted by.
+var roleColumns = map[string]column{
+	"id":   {"id", "int"},
+	"role": {"role", "text"},
+}
+
+func (r *Employees) Roles(ctx context.Context, filter models.RoleFilter) (roleGroups []models.RoleGroup, page models.Page, err error) {
+	order := repository.ParseOrder(filter.Sort, "id")
+
+	page.Total, err = count(ctx, r.pool, `select id from role_group`)
+	if err != nil {
+		return nil, page, err
+	}
+
+	keyset, tail, args, err := pageClauses(roleColumns, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit, nil)
+	if err != nil {
+		return nil, page, err
+	}
+
+	rows, err := r.pool.Query(
+		ctx,
+		`select id, role, labels
+from role_group
+where true`+keyset+`
+`+tail+`;`,
+		args...,
+	)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		roleGroup := models.RoleGroup{}
+		err = rows.Scan(
+			&roleGroup.Id,
+			&roleGroup.Role,
+			&roleGroup.Labels,
+		)
+		if err != nil {
+			return nil, page, err
+		}
+
+		roleGroups = append(roleGroups, roleGroup)
+	}
+
+	if filter.RowsLimit > 0 && uint(len(roleGroups)) > filter.RowsLimit {
+		roleGroups = roleGroups[:filter.RowsLimit]
+		last := roleGroups[len(roleGroups)-1]
+		page.NextCursor = repository.NewCursor(order, repository.RoleKeys[order.Field](last), last.Id)
+	}
+
+	return roleGroups, page, rows.Err()
+}
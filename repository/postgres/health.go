@@ -0,0 +1,19 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/db"
+)
+
+type Health struct {
+	pool *pgxpool.Pool
+}
+
+func (r *Health) Ping(ctx context.Context) error {
+	return r.pool.Ping(ctx)
+}
+
+func (r *Health) Migrations(ctx context.Context) ([]db.Migration, error) {
+	return db.MigrationStatus(ctx, r.pool)
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/models"
+)
+
+type Idempotency struct {
+	pool *pgxpool.Pool
+}
+
+func (r *Idempotency) Begin(ctx context.Context, request models.IdempotentRequest) (stored models.IdempotentRequest, found bool, err error) {
+	err = r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `delete from idempotency_keys where expires_at < now();`)
+		if err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(
+			ctx,
+			`insert into idempotency_keys (employee_id, key, request_hash, expires_at)
+values ($1, $2, $3, $4)
+on conflict (employee_id, key) do nothing;`,
+			request.EmployeeId,
+			request.Key,
+			request.RequestHash,
+			request.ExpiresAt,
+		)
+		if err != nil || tag.RowsAffected() == 1 {
+			return err
+		}
+
+		found = true
+		return tx.QueryRow(
+			ctx,
+			`select employee_id, key, request_hash, status, headers, coalesce(body, ''), expires_at
+from idempotency_keys
+where employee_id = $1
+  and key = $2;`,
+			request.EmployeeId,
+			request.Key,
+		).Scan(
+			&stored.EmployeeId,
+			&stored.Key,
+			&stored.RequestHash,
+			&stored.Status,
+			&stored.Headers,
+			&stored.Body,
+			&stored.ExpiresAt,
+		)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Deleted by a concurrent Release between the two statements.
+		return r.Begin(ctx, request)
+	}
+	return stored, found, err
+}
+
+func (r *Idempotency) Complete(ctx context.Context, request models.IdempotentRequest) error {
+	_, err := r.pool.Exec(
+		ctx,
+		`update idempotency_keys
+set status  = $3,
+    headers = $4,
+    body    = $5
+where employee_id = $1
+  and key = $2;`,
+		request.EmployeeId,
+		request.Key,
+		request.Status,
+		request.Headers,
+		request.Body,
+	)
+	return err
+}
+
+func (r *Idempotency) Release(ctx context.Context, employeeId int, key string) error {
+	_, err := r.pool.Exec(
+		ctx,
+		`delete from idempotency_keys
+where employee_id = $1
+  and key = $2;`,
+		employeeId,
+		key,
+	)
+	return err
+}
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/importer"
+	"strings"
+)
+
+type Imports struct {
+	pool *pgxpool.Pool
+}
+
+func (r *Imports) Departments(ctx context.Context) (map[string]int, error) {
+	return r.names(
+		ctx,
+		`select id, lower(name)
+from departments
+where archived_at is null;`,
+	)
+}
+
+func (r *Imports) Roles(ctx context.Context) (map[string]int, error) {
+	return r.names(
+		ctx,
+		`select id, upper(role)
+from role_group;`,
+	)
+}
+
+func (r *Imports) TakenEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	rows, err := r.pool.Query(
+		ctx,
+		`select lower(email)
+from employees
+where lower(email) = any ($1);`,
+		emails,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		email := ""
+		err = rows.Scan(&email)
+		if err != nil {
+			return nil, err
+		}
+		taken[email] = true
+	}
+
+	return taken, rows.Err()
+}
+
+func (r *Imports) Import(ctx context.Context, batch importer.Batch) error {
+	departments, err := r.Departments(ctx)
+	if err != nil {
+		return err
+	}
+
+	roles, err := r.Roles(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		for _, department := range batch.Departments {
+			id := 0
+			err := tx.QueryRow(
+				ctx,
+				`insert into departments (name, internal_number, phone, parent_id)
+values ($1, $2, $3, nullif($4, 0))
+returning id;`,
+				department.Name,
+				department.InternalNumber,
+				department.Phone,
+				departments[strings.ToLower(department.Parent)],
+			).Scan(&id)
+			if err != nil {
+				return fmt.Errorf("%s row %d: %w", importer.SheetDepartments, department.Row, err)
+			}
+
+			departments[strings.ToLower(department.Name)] = id
+		}
+
+		for _, employee := range batch.Employees {
+			_, err := tx.Exec(
+				ctx,
+				`insert into employees (full_name, email, password, role_id, department_id, position)
+values ($1, $2, $3, $4, $5, $6);`,
+				employee.FullName,
+				employee.Email,
+				employee.Password,
+				roles[employee.Role],
+				departments[strings.ToLower(employee.Department)],
+				employee.Position,
+			)
+			if err != nil {
+				return fmt.Errorf("%s row %d: %w", importer.SheetEmployees, employee.Row, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *Imports) names(ctx context.Context, sql string) (map[string]int, error) {
+	rows, err := r.pool.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]int)
+	for rows.Next() {
+		id, name := 0, ""
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+		names[name] = id
+	}
+
+	return names, rows.Err()
+}
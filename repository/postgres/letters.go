@@ -0,0 +1,474 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/models"
+	"sed/repository"
+	"strings"
+	"time"
+)
+
+type Letters struct {
+	pool *pgxpool.Pool
+}
+
+// letterColumns are the columns letters can be sorted by.
+var letterColumns = map[string]column{
+	"id":         {"l.id", "int"},
+	"name":       {"l.name", "text"},
+	"sender":     {"l.sender", "text"},
+	"entry_date": {"l.entry_date", "timestamptz"},
+}
+
+func (r *Letters) List(ctx context.Context, filter models.LetterFilter) (letters []models.Letter, page models.Page, err error) {
+	order := repository.ParseOrder(filter.Sort, "-id")
+	query, args := letterFilters(filter)
+
+	from := `from letters l
+         left join document_type dt on l.document_type_id = dt.id
+         left join cases k on l.case_id = k.id
+where l.deleted_at is null
+` + query
+
+	page.Total, err = count(ctx, r.pool, `select l.id `+from, args...)
+	if err != nil {
+		return nil, page, err
+	}
+
+	keyset, tail, args, err := pageClauses(letterColumns, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit, args)
+	if err != nil {
+		return nil, page, err
+	}
+
+	rows, err := r.pool.Query(
+		ctx,
+		`select l.id,
+       l.name,
+       l.sender,
+       coalesce(dt.type, ''),
+       coalesce(dt.labels, '{}'),
+       coalesce(l.registration_number, ''),
+       coalesce(l.entry_date, now()),
+       coalesce(l.outgoing_number, ''),
+       coalesce(l.distribution_date, now()),
+       coalesce(l.case_id, 0),
+       coalesce(k.index, '')
+`+from+keyset+tail+`;`,
+		args...,
+	)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		letter := models.Letter{}
+		err = rows.Scan(
+			&letter.Id,
+			&letter.Name,
+			&letter.Sender,
+			&letter.DocumentType.Type,
+			&letter.DocumentType.Labels,
+			&letter.RegistrationNumber,
+			&letter.EntryDate,
+			&letter.OutgoingNumber,
+			&letter.DistributionDate,
+			&letter.CaseId,
+			&letter.CaseIndex,
+		)
+		if err != nil {
+			return nil, page, err
+		}
+
+		letters = append(letters, letter)
+	}
+
+	if filter.RowsLimit > 0 && uint(len(letters)) > filter.RowsLimit {
+		letters = letters[:filter.RowsLimit]
+		last := letters[len(letters)-1]
+		page.NextCursor = repository.NewCursor(order, repository.LetterKeys[order.Field](last), last.Id)
+	}
+
+	return letters, page, rows.Err()
+}
+
+// letterFilters turns filter into "and ..." conditions.
+func letterFilters(filter models.LetterFilter) (query string, args []interface{}) {
+	filter.Sender = strings.TrimSpace(filter.Sender)
+	if len(filter.Sender) > 0 {
+		args = append(args, "%"+filter.Sender+"%")
+		query += fmt.Sprintf(" and l.sender like $%d ", len(args))
+	}
+
+	filter.Name = strings.TrimSpace(filter.Name)
+	if len(filter.Name) > 0 {
+		args = append(args, "%"+strings.ToLower(filter.Name)+"%")
+		query += fmt.Sprintf(" and lower(l.name) like $%d ", len(args))
+	}
+
+	if filter.DepartmentId != 0 {
+		args = append(args, filter.DepartmentIds)
+		query += fmt.Sprintf(" and exists(select 1 from described_letters dl where dl.letter_id = l.id and dl.department_id = any ($%d)) ", len(args))
+	}
+
+	filter.CaseIndex = strings.TrimSpace(filter.CaseIndex)
+	if len(filter.CaseIndex) > 0 {
+		args = append(args, filter.CaseIndex)
+		query += fmt.Sprintf(" and k.index = $%d ", len(args))
+	}
+
+	return query, args
+}
+
+func (r *Letters) Get(ctx context.Context, id int) (letter models.Letter, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`select l.id,
+       l.name,
+       l.sender,
+       coalesce(l.document_type_id, 0),
+       coalesce(dt.type, ''),
+       coalesce(dt.labels, '{}'),
+       coalesce(l.registration_number, ''),
+       coalesce(l.entry_date, now()),
+       coalesce(l.outgoing_number, ''),
+       coalesce(l.distribution_date, now()),
+       l.content,
+       l.version,
+       l.destroyed_at,
+       coalesce(l.destruction_act_id, 0),
+       coalesce(l.case_id, 0),
+       coalesce(k.index, '')
+from letters l
+         left join document_type dt on l.document_type_id = dt.id
+         left join cases k on l.case_id = k.id
+where l.id = $1
+  and l.deleted_at is null;`,
+		id,
+	).Scan(
+		&letter.Id,
+		&letter.Name,
+		&letter.Sender,
+		&letter.DocumentTypeId,
+		&letter.DocumentType.Type,
+		&letter.DocumentType.Labels,
+		&letter.RegistrationNumber,
+		&letter.EntryDate,
+		&letter.OutgoingNumber,
+		&letter.DistributionDate,
+		&letter.Content,
+		&letter.Version,
+		&letter.DestroyedAt,
+		&letter.DestructionActId,
+		&letter.CaseId,
+		&letter.CaseIndex,
+	)
+	return letter, notFound(err)
+}
+
+func (r *Letters) Create(ctx context.Context, letter models.Letter) (id int, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`insert into letters (name, sender, document_type_id, registration_number, entry_date, outgoing_number, content)
+values ($1, $2, $3, now(), now(), now(), $4) returning id;`,
+		letter.Name,
+		letter.Sender,
+		letter.DocumentTypeId,
+		letter.Content,
+	).Scan(&id)
+	return id, err
+}
+
+func (r *Letters) Update(ctx context.Context, letter models.Letter) (version int, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`update letters
+set name                = $1,
+    sender              = $2,
+    document_type_id    = $3,
+    content             = $4,
+    version             = version + 1
+where id = $5
+  and version = $6
+  and deleted_at is null
+  and destroyed_at is null
+returning version;`,
+		letter.Name,
+		letter.Sender,
+		letter.DocumentTypeId,
+		letter.Content,
+		letter.Id,
+		letter.Version,
+	).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, conflict(ctx, r.pool, `select 1 from letters where id = $1 and deleted_at is null`, letter.Id)
+	}
+	return version, err
+}
+
+func (r *Letters) Describe(ctx context.Context, describedLetter models.DescribedLetter) error {
+	rtn, err := r.pool.Exec(
+		ctx,
+		`insert into described_letters (letter_id, department_id, executive_employee, due_date)
+select $1, $2, nullif($3, 0), $4
+where exists(select 1 from letters where id = $1 and deleted_at is null);`,
+		describedLetter.LetterId,
+		describedLetter.DepartmentId,
+		describedLetter.ExecutiveEmployee,
+		describedLetter.DueDate,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rtn.RowsAffected() < 1 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Letters) DocumentTypes(ctx context.Context) (documentTypes []models.DocumentType, err error) {
+	rows, err := r.pool.Query(
+		ctx,
+		`select id, type, labels
+from document_type;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		documentType := models.DocumentType{}
+		err = rows.Scan(
+			&documentType.Id,
+			&documentType.Type,
+			&documentType.Labels,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		documentTypes = append(documentTypes, documentType)
+	}
+
+	return documentTypes, rows.Err()
+}
+
+func (r *Letters) Delete(ctx context.Context, id, actorId int, reason string) error {
+	rtn, err := r.pool.Exec(
+		ctx,
+		`update letters
+set deleted_at    = now(),
+    deleted_by    = $2,
+    delete_reason = $3,
+    version       = version + 1
+where id = $1
+  and deleted_at is null;`,
+		id,
+		actorId,
+		reason,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rtn.RowsAffected() < 1 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Letters) Deleted(ctx context.Context, id int) (letter models.Letter, err error) {
+	letter.DeletedAt = new(time.Time)
+	err = r.pool.QueryRow(
+		ctx,
+		`select l.id,
+       l.name,
+       l.sender,
+       coalesce(l.document_type_id, 0),
+       coalesce(dt.type, ''),
+       coalesce(dt.labels, '{}'),
+       coalesce(l.registration_number, ''),
+       coalesce(l.entry_date, now()),
+       coalesce(l.outgoing_number, ''),
+       coalesce(l.distribution_date, now()),
+       l.content,
+       l.version,
+       l.deleted_at,
+       coalesce(l.deleted_by, 0),
+       coalesce(l.delete_reason, '')
+from letters l
+         left join document_type dt on l.document_type_id = dt.id
+where l.id = $1
+  and l.deleted_at is not null;`,
+		id,
+	).Scan(
+		&letter.Id,
+		&letter.Name,
+		&letter.Sender,
+		&letter.DocumentTypeId,
+		&letter.DocumentType.Type,
+		&letter.DocumentType.Labels,
+		&letter.RegistrationNumber,
+		&letter.EntryDate,
+		&letter.OutgoingNumber,
+		&letter.DistributionDate,
+		&letter.Content,
+		&letter.Version,
+		letter.DeletedAt,
+		&letter.DeletedBy,
+		&letter.DeleteReason,
+	)
+	return letter, notFound(err)
+}
+
+// trashColumns are the columns the trash can be sorted by.
+var trashColumns = map[string]column{
+	"id":         {"l.id", "int"},
+	"deleted_at": {"l.deleted_at", "timestamptz"},
+}
+
+func (r *Letters) Trash(ctx context.Context, filter models.TrashFilter) (letters []models.Letter, page models.Page, err error) {
+	order := repository.ParseOrder(filter.Sort, "-deleted_at")
+
+	from := `from letters l
+         left join document_type dt on l.document_type_id = dt.id
+where l.deleted_at is not null
+`
+
+	page.Total, err = count(ctx, r.pool, `select l.id `+from)
+	if err != nil {
+		return nil, page, err
+	}
+
+	keyset, tail, args, err := pageClauses(trashColumns, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit, nil)
+	if err != nil {
+		return nil, page, err
+	}
+
+	rows, err := r.pool.Query(
+		ctx,
+		`select l.id,
+       l.name,
+       l.sender,
+       coalesce(dt.type, ''),
+       coalesce(dt.labels, '{}'),
+       coalesce(l.registration_number, ''),
+       coalesce(l.entry_date, now()),
+       l.deleted_at,
+       coalesce(l.deleted_by, 0),
+       coalesce(l.delete_reason, '')
+`+from+keyset+tail+`;`,
+		args...,
+	)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		letter := models.Letter{DeletedAt: new(time.Time)}
+		err = rows.Scan(
+			&letter.Id,
+			&letter.Name,
+			&letter.Sender,
+			&letter.DocumentType.Type,
+			&letter.DocumentType.Labels,
+			&letter.RegistrationNumber,
+			&letter.EntryDate,
+			letter.DeletedAt,
+			&letter.DeletedBy,
+			&letter.DeleteReason,
+		)
+		if err != nil {
+			return nil, page, err
+		}
+
+		letters = append(letters, letter)
+	}
+
+	if filter.RowsLimit > 0 && uint(len(letters)) > filter.RowsLimit {
+		letters = letters[:filter.RowsLimit]
+		last := letters[len(letters)-1]
+		page.NextCursor = repository.NewCursor(order, repository.TrashKeys[order.Field](last), last.Id)
+	}
+
+	return letters, page, rows.Err()
+}
+
+func (r *Letters) Restore(ctx context.Context, id int) error {
+	rtn, err := r.pool.Exec(
+		ctx,
+		`update letters
+set deleted_at    = null,
+    deleted_by    = null,
+    delete_reason = null,
+    version       = version + 1
+where id = $1
+  and deleted_at is not null;`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rtn.RowsAffected() < 1 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Letters) Purge(ctx context.Context, id int) error {
+	return r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		found := 0
+		err := tx.QueryRow(
+			ctx,
+			`select 1
+from letters
+where id = $1
+  and deleted_at is not null
+    for update;`,
+			id,
+		).Scan(&found)
+		if err != nil {
+			return notFound(err)
+		}
+
+		inAct := false
+		err = tx.QueryRow(
+			ctx,
+			`select exists(select 1
+              from destruction_act_letters
+              where letter_id = $1);`,
+			id,
+		).Scan(&inAct)
+		if err != nil {
+			return err
+		}
+
+		if inAct {
+			return repository.ErrInAct
+		}
+
+		for _, query := range []string{
+			`delete from agreements where letter_id = $1;`,
+			`delete from described_letters where letter_id = $1;`,
+			`delete from letters where id = $1;`,
+		} {
+			_, err = tx.Exec(ctx, query, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/repository"
+)
+
+// column is a column a list can be sorted by: its expression and the type
+// the key of a cursor is cast to for comparison.
+type column struct {
+	expr string
+	cast string
+}
+
+// pageClauses returns the clauses selecting one page of a list sorted by
+// order. keyset is an "and ..." condition for rows after cursor, tail the
+// order by, offset and limit. The limit fetches one row more than asked
+// for, so that the caller knows whether a next page exists; a limit of 0
+// fetches every row. args starts with the given arguments so that
+// placeholders continue after them.
+func pageClauses(columns map[string]column, order repository.Order, cursor string, offset, limit uint, args []interface{}) (keyset, tail string, _ []interface{}, err error) {
+	sorted, ok := columns[order.Field]
+	if !ok {
+		return "", "", nil, fmt.Errorf("unknown sort field %q", order.Field)
+	}
+	id := columns["id"]
+
+	direction, comparison := "asc", ">"
+	if order.Descending {
+		direction, comparison = "desc", "<"
+	}
+
+	if cursor != "" {
+		c, err := repository.ParseCursor(cursor, order)
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		args = append(args, c.Key, c.Id)
+		keyset = fmt.Sprintf(" and (%s, %s) %s ($%d::%s, $%d) ", sorted.expr, id.expr, comparison, len(args)-1, sorted.cast, len(args))
+	}
+
+	var fetch interface{}
+	if limit > 0 {
+		fetch = limit + 1
+	}
+	args = append(args, offset, fetch)
+
+	tail = fmt.Sprintf("order by %s %s, %s %s\noffset $%d limit $%d", sorted.expr, direction, id.expr, direction, len(args)-1, len(args))
+
+	return keyset, tail, args, nil
+}
+
+// count returns the number of rows the query, a select without order by
+// and limit, yields.
+func count(ctx context.Context, pool *pgxpool.Pool, query string, args ...interface{}) (total int, err error) {
+	err = pool.QueryRow(ctx, `select count(*) from (`+query+`) matching;`, args...).Scan(&total)
+	return total, err
+}
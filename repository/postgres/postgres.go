@@ -0,0 +1,49 @@
+// Package postgres implements the repository interfaces on top of pgx.
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/repository"
+)
+
+func New(pool *pgxpool.Pool) repository.Repositories {
+	return repository.Repositories{
+		Letters:     &Letters{pool: pool},
+		Employees:   &Employees{pool: pool},
+		Departments: &Departments{pool: pool},
+		Agreements:  &Agreements{pool: pool},
+		Imports:     &Imports{pool: pool},
+		Health:      &Health{pool: pool},
+		Stats:       &Stats{pool: pool},
+		Idempotency: &Idempotency{pool: pool},
+		Audit:       &Audit{pool: pool},
+		Retention:   &Retention{pool: pool},
+		Cases:       &Cases{pool: pool},
+	}
+}
+
+// notFound maps pgx.ErrNoRows to repository.ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+// conflict explains why a versioned update matched no row: the row selected
+// by exists, a query taking the id, has a newer version, or it is gone.
+func conflict(ctx context.Context, pool *pgxpool.Pool, exists string, id int) error {
+	found := false
+	err := pool.QueryRow(ctx, `select exists(`+exists+`);`, id).Scan(&found)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		return repository.ErrConflict
+	}
+	return repository.ErrNotFound
+}
@@ -0,0 +1,467 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/models"
+	"sed/repository"
+	"time"
+)
+
+type Retention struct {
+	pool *pgxpool.Pool
+}
+
+// retainUntil is when the retention period of a letter ends: the period of
+// its case or, for letters not filed into a case, of its document type.
+// It is null for letters kept permanently.
+const retainUntil = `(l.entry_date + make_interval(years => case
+                                                   when l.case_id is null then r.years
+                                                   else nullif(k.retention_years, 0) end))`
+
+// overdueFrom selects the letters past their retention period that are
+// neither destroyed nor in an open act.
+const overdueFrom = `from letters l
+         left join cases k on l.case_id = k.id
+         left join retention_rules r on r.document_type_id = l.document_type_id
+         left join document_type dt on l.document_type_id = dt.id
+where l.deleted_at is null
+  and l.destroyed_at is null
+  and ` + retainUntil + ` <= now()
+  and not exists(select 1
+                 from destruction_act_letters al
+                          join destruction_acts a on a.id = al.act_id
+                 where al.letter_id = l.id
+                   and a.status in ('draft', 'pending', 'approved'))
+`
+
+// retentionColumns are the columns overdue letters can be sorted by.
+var retentionColumns = map[string]column{
+	"id":           {"l.id", "int"},
+	"retain_until": {retainUntil, "timestamptz"},
+}
+
+// destructionActColumns are the columns acts can be sorted by.
+var destructionActColumns = map[string]column{
+	"id": {"a.id", "int"},
+}
+
+func (r *Retention) Rules(ctx context.Context) (rules []models.RetentionRule, err error) {
+	rows, err := r.pool.Query(
+		ctx,
+		`select r.id, r.document_type_id, dt.type, dt.labels, r.years, r.article
+from retention_rules r
+         join document_type dt on r.document_type_id = dt.id
+order by r.document_type_id;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule := models.RetentionRule{}
+		err = rows.Scan(
+			&rule.Id,
+			&rule.DocumentTypeId,
+			&rule.DocumentType.Type,
+			&rule.DocumentType.Labels,
+			&rule.Years,
+			&rule.Article,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (r *Retention) SetRule(ctx context.Context, rule models.RetentionRule) (id int, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`insert into retention_rules (document_type_id, years, article)
+values ($1, $2, $3)
+on conflict (document_type_id) do update set years   = excluded.years,
+                                             article = excluded.article
+returning id;`,
+		rule.DocumentTypeId,
+		rule.Years,
+		rule.Article,
+	).Scan(&id)
+	return id, err
+}
+
+func (r *Retention) DeleteRule(ctx context.Context, documentTypeId int) error {
+	rtn, err := r.pool.Exec(ctx, `delete from retention_rules where document_type_id = $1;`, documentTypeId)
+	if err != nil {
+		return err
+	}
+
+	if rtn.RowsAffected() < 1 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Retention) Overdue(ctx context.Context, filter models.RetentionFilter) (letters []models.Letter, page models.Page, err error) {
+	order := repository.ParseOrder(filter.Sort, "retain_until")
+
+	var (
+		query string
+		args  []interface{}
+	)
+	if filter.DocumentTypeId != 0 {
+		args = append(args, filter.DocumentTypeId)
+		query = fmt.Sprintf(" and l.document_type_id = $%d ", len(args))
+	}
+
+	page.Total, err = count(ctx, r.pool, `select l.id `+overdueFrom+query, args...)
+	if err != nil {
+		return nil, page, err
+	}
+
+	keyset, tail, args, err := pageClauses(retentionColumns, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit, args)
+	if err != nil {
+		return nil, page, err
+	}
+
+	rows, err := r.pool.Query(
+		ctx,
+		`select l.id,
+       l.name,
+       l.sender,
+       coalesce(dt.type, ''),
+       coalesce(dt.labels, '{}'),
+       coalesce(l.registration_number, ''),
+       l.entry_date,
+       `+retainUntil+`
+`+overdueFrom+query+keyset+tail+`;`,
+		args...,
+	)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		letter := models.Letter{RetainUntil: new(time.Time)}
+		err = rows.Scan(
+			&letter.Id,
+			&letter.Name,
+			&letter.Sender,
+			&letter.DocumentType.Type,
+			&letter.DocumentType.Labels,
+			&letter.RegistrationNumber,
+			&letter.EntryDate,
+			letter.RetainUntil,
+		)
+		if err != nil {
+			return nil, page, err
+		}
+
+		letters = append(letters, letter)
+	}
+
+	if filter.RowsLimit > 0 && uint(len(letters)) > filter.RowsLimit {
+		letters = letters[:filter.RowsLimit]
+		last := letters[len(letters)-1]
+		page.NextCursor = repository.NewCursor(order, repository.RetentionKeys[order.Field](last), last.Id)
+	}
+
+	return letters, page, rows.Err()
+}
+
+func (r *Retention) CreateAct(ctx context.Context, act models.DestructionAct) (id int, err error) {
+	err = r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(
+			ctx,
+			`insert into destruction_acts (department_id, note, created_by)
+values ($1, $2, $3)
+returning id;`,
+			act.DepartmentId,
+			act.Note,
+			act.CreatedBy,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		rtn, err := tx.Exec(
+			ctx,
+			`insert into destruction_act_letters (act_id, letter_id)
+select $1, l.id
+`+overdueFrom+` and l.id = any ($2);`,
+			id,
+			act.LetterIds,
+		)
+		if err != nil {
+			return err
+		}
+
+		if rtn.RowsAffected() != int64(len(act.LetterIds)) {
+			return repository.ErrNotDue
+		}
+
+		return nil
+	})
+	return id, err
+}
+
+func (r *Retention) Act(ctx context.Context, id int) (act models.DestructionAct, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`select a.id,
+       a.department_id,
+       a.status,
+       a.note,
+       coalesce(a.created_by, 0),
+       a.created_at,
+       a.submitted_at,
+       a.executed_at
+from destruction_acts a
+where a.id = $1;`,
+		id,
+	).Scan(
+		&act.Id,
+		&act.DepartmentId,
+		&act.Status,
+		&act.Note,
+		&act.CreatedBy,
+		&act.CreatedAt,
+		&act.SubmittedAt,
+		&act.ExecutedAt,
+	)
+	if err != nil {
+		return act, notFound(err)
+	}
+
+	act.LetterIds, err = r.actLetters(ctx, id)
+	return act, err
+}
+
+// actLetters returns the ids of the letters of act id.
+func (r *Retention) actLetters(ctx context.Context, id int) (ids []int, err error) {
+	rows, err := r.pool.Query(
+		ctx,
+		`select letter_id
+from destruction_act_letters
+where act_id = $1
+order by letter_id;`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		letterId := 0
+		err = rows.Scan(&letterId)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, letterId)
+	}
+
+	return ids, rows.Err()
+}
+
+func (r *Retention) Acts(ctx context.Context, filter models.DestructionActFilter) (acts []models.DestructionAct, page models.Page, err error) {
+	order := repository.ParseOrder(filter.Sort, "-id")
+
+	var (
+		query string
+		args  []interface{}
+	)
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query = fmt.Sprintf(" and a.status = $%d ", len(args))
+	}
+
+	from := `from destruction_acts a
+where true
+` + query
+
+	page.Total, err = count(ctx, r.pool, `select a.id `+from, args...)
+	if err != nil {
+		return nil, page, err
+	}
+
+	keyset, tail, args, err := pageClauses(destructionActColumns, order, filter.Cursor, filter.RowsOffset, filter.RowsLimit, args)
+	if err != nil {
+		return nil, page, err
+	}
+
+	rows, err := r.pool.Query(
+		ctx,
+		`select a.id,
+       a.department_id,
+       a.status,
+       a.note,
+       coalesce(a.created_by, 0),
+       a.created_at,
+       a.submitted_at,
+       a.executed_at
+`+from+keyset+tail+`;`,
+		args...,
+	)
+	if err != nil {
+		return nil, page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		act := models.DestructionAct{}
+		err = rows.Scan(
+			&act.Id,
+			&act.DepartmentId,
+			&act.Status,
+			&act.Note,
+			&act.CreatedBy,
+			&act.CreatedAt,
+			&act.SubmittedAt,
+			&act.ExecutedAt,
+		)
+		if err != nil {
+			return nil, page, err
+		}
+
+		acts = append(acts, act)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, page, err
+	}
+
+	if filter.RowsLimit > 0 && uint(len(acts)) > filter.RowsLimit {
+		acts = acts[:filter.RowsLimit]
+		last := acts[len(acts)-1]
+		page.NextCursor = repository.NewCursor(order, repository.DestructionActKeys[order.Field](last), last.Id)
+	}
+
+	for i := range acts {
+		acts[i].LetterIds, err = r.actLetters(ctx, acts[i].Id)
+		if err != nil {
+			return nil, page, err
+		}
+	}
+
+	return acts, page, nil
+}
+
+// actStatus reports why act id did not move out of the status a step
+// requires: it is missing or in another status.
+func (r *Retention) actStatus(ctx context.Context, id int) error {
+	err := conflict(ctx, r.pool, `select 1 from destruction_acts where id = $1`, id)
+	if errors.Is(err, repository.ErrConflict) {
+		return repository.ErrActStatus
+	}
+	return err
+}
+
+func (r *Retention) SubmitAct(ctx context.Context, id int) error {
+	err := r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		rtn, err := tx.Exec(
+			ctx,
+			`update destruction_acts
+set status       = 'pending',
+    submitted_at = now()
+where id = $1
+  and status = 'draft';`,
+			id,
+		)
+		if err != nil {
+			return err
+		}
+
+		if rtn.RowsAffected() < 1 {
+			return pgx.ErrNoRows
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`insert into agreements (department_id, letter_id, viewed, agreed_at, destruction_act_id)
+select a.department_id, al.letter_id, false, now(), a.id
+from destruction_acts a
+         join destruction_act_letters al on al.act_id = a.id
+where a.id = $1;`,
+			id,
+		)
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return r.actStatus(ctx, id)
+	}
+	return err
+}
+
+func (r *Retention) SettleAct(ctx context.Context, id int) (status string, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`update destruction_acts a
+set status = case
+                 when exists(select 1
+                             from agreements g
+                             where g.destruction_act_id = a.id
+                               and g.decided_at is not null
+                               and not g.agreed) then 'rejected'
+                 when not exists(select 1
+                                 from agreements g
+                                 where g.destruction_act_id = a.id
+                                   and g.decided_at is null) then 'approved'
+                 else status
+    end
+where a.id = $1
+  and a.status = 'pending'
+returning a.status;`,
+		id,
+	).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", r.actStatus(ctx, id)
+	}
+	return status, err
+}
+
+func (r *Retention) ExecuteAct(ctx context.Context, id int) error {
+	err := r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		rtn, err := tx.Exec(
+			ctx,
+			`update destruction_acts
+set status      = 'executed',
+    executed_at = now()
+where id = $1
+  and status = 'approved';`,
+			id,
+		)
+		if err != nil {
+			return err
+		}
+
+		if rtn.RowsAffected() < 1 {
+			return pgx.ErrNoRows
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`update letters
+set content            = '',
+    destroyed_at       = now(),
+    destruction_act_id = $1,
+    version            = version + 1
+where id in (select letter_id from destruction_act_letters where act_id = $1);`,
+			id,
+		)
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return r.actStatus(ctx, id)
+	}
+	return err
+}
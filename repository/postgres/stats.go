@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"sed/models"
+)
+
+type Stats struct {
+	pool *pgxpool.Pool
+}
+
+func (r *Stats) Stats(ctx context.Context) (stats models.Stats, err error) {
+	err = r.pool.QueryRow(
+		ctx,
+		`select (select count(*)
+        from agreements a
+                 join letters l on l.id = a.letter_id
+        where a.decided_at is null
+          and l.deleted_at is null),
+       (select count(*)
+        from described_letters dl
+                 join letters l on l.id = dl.letter_id
+        where dl.due_date < now()
+          and l.distribution_date is null
+          and l.deleted_at is null);`,
+	).Scan(&stats.PendingAgreements, &stats.OverdueAssignments)
+	return stats, err
+}
@@ -0,0 +1,251 @@
+// Package repository declares the storage interfaces used by the handlers.
+// Implementations live in the postgres and memory subpackages.
+package repository
+
+import (
+	"context"
+	"errors"
+	"sed/db"
+	"sed/importer"
+	"sed/models"
+)
+
+// ErrNotFound is returned when the requested row does not exist. Its text
+// matches pgx.ErrNoRows, which clients have been seeing so far.
+var ErrNotFound = errors.New("no rows in result set")
+
+// ErrConflict is returned by versioned updates when the row has changed
+// since the version the caller read.
+var ErrConflict = errors.New("version conflict")
+
+// ErrNotDue is returned when a letter put in a destruction act is not past
+// its retention period or is in another open act.
+var ErrNotDue = errors.New("letter not due for destruction")
+
+// ErrActStatus is returned when a destruction act is not in the status the
+// step requires.
+var ErrActStatus = errors.New("destruction act in another status")
+
+// ErrNotAllowed is returned when the employee acting may not make the
+// change.
+var ErrNotAllowed = errors.New("not allowed")
+
+// ErrDuplicate is returned when a row with the same unique key exists.
+var ErrDuplicate = errors.New("duplicate key")
+
+// ErrInAct is returned when a letter is purged while a destruction act
+// lists it.
+var ErrInAct = errors.New("letter in a destruction act")
+
+// ErrCycle is returned when a department would end up below itself.
+var ErrCycle = errors.New("department cycle")
+
+// ErrCaseClosed is returned when a letter is filed into or out of a closed
+// case.
+var ErrCaseClosed = errors.New("case closed")
+
+// LetterRepository stores letters. Letters in the trash are left out of
+// every method but Deleted, Trash, Restore and Purge, as if they did not
+// exist.
+type LetterRepository interface {
+	// List returns a page of letters matching filter, newest first by
+	// default. When filter.DepartmentId is set only letters described to
+	// one of filter.DepartmentIds are returned.
+	List(ctx context.Context, filter models.LetterFilter) ([]models.Letter, models.Page, error)
+	Get(ctx context.Context, id int) (models.Letter, error)
+	Create(ctx context.Context, letter models.Letter) (int, error)
+	// Update stores letter if its version is still letter.Version and
+	// returns the new version, or ErrConflict.
+	Update(ctx context.Context, letter models.Letter) (int, error)
+	Describe(ctx context.Context, describedLetter models.DescribedLetter) error
+	DocumentTypes(ctx context.Context) ([]models.DocumentType, error)
+	// Delete moves a letter to the trash, recording who deleted it and why.
+	Delete(ctx context.Context, id, actorId int, reason string) error
+	// Deleted returns a letter in the trash, or ErrNotFound.
+	Deleted(ctx context.Context, id int) (models.Letter, error)
+	// Trash returns a page of the letters in the trash, most recently
+	// deleted first by default.
+	Trash(ctx context.Context, filter models.TrashFilter) ([]models.Letter, models.Page, error)
+	// Restore takes a letter out of the trash.
+	Restore(ctx context.Context, id int) error
+	// Purge removes a letter in the trash for good, with its assignments
+	// and agreements, or returns ErrInAct if a destruction act lists it, in
+	// any status, since acts are kept as records.
+	Purge(ctx context.Context, id int) error
+}
+
+type EmployeeRepository interface {
+	// ByToken returns the id and preferred language of the employee
+	// holding the session token.
+	ByToken(ctx context.Context, token string) (models.Employee, error)
+	// ByEmail returns the employee with Password set to the stored hash.
+	ByEmail(ctx context.Context, email string) (models.Employee, error)
+	SetToken(ctx context.Context, id int, token string) error
+	Get(ctx context.Context, id int) (models.Employee, error)
+	// List returns a page of employees matching filter, newest first by
+	// default. When filter.DepartmentIds is not nil only employees of those
+	// departments are returned, none when it is empty.
+	List(ctx context.Context, filter models.EmployeeFilter) ([]models.Employee, models.Page, error)
+	// All returns every employee with DepartmentId set, ordered by name.
+	All(ctx context.Context) ([]models.Employee, error)
+	// Update stores employee and, when the department changes, records a
+	// transfer made by actorId.
+	Update(ctx context.Context, employee models.Employee, actorId int) error
+	Transfers(ctx context.Context, employeeId int) ([]models.EmployeeTransfer, error)
+	// Roles returns a page of role groups in id order by default.
+	Roles(ctx context.Context, filter models.RoleFilter) ([]models.RoleGroup, models.Page, error)
+}
+
+type DepartmentRepository interface {
+	// List returns a page of departments with their head and deputy,
+	// newest first by default. Archived departments are skipped unless
+	// filter.Archived is set.
+	List(ctx context.Context, filter models.DepartmentFilter) ([]models.Department, models.Page, error)
+	// Get returns a department, archived or not, with its head and deputy.
+	Get(ctx context.Context, id int) (models.Department, error)
+	Create(ctx context.Context, department models.Department) (int, error)
+	// Update changes the name, internal number and phone of an active
+	// department if its version is still department.Version and returns the
+	// new version, or ErrConflict. Every other change bumps the version too.
+	Update(ctx context.Context, department models.Department) (int, error)
+	// Move puts the department below parentId, or at the top for 0, and
+	// returns ErrCycle when parentId is the department or below it.
+	Move(ctx context.Context, id, parentId int) error
+	SetHeads(ctx context.Context, id, headId, deputyId int) error
+	// IsArchived reports whether the department is archived, or ErrNotFound.
+	IsArchived(ctx context.Context, id int) (bool, error)
+	// Members counts employees and active sub-departments of the department.
+	Members(ctx context.Context, id int) (employees int, children int, err error)
+	Archive(ctx context.Context, id int) error
+	// Merge moves employees, sub-departments and open assignments of
+	// sourceId into targetId and archives the source. Assignments are open
+	// while their agreement is not decided or their letter is not yet
+	// distributed; closed ones keep pointing at the source for history.
+	// It returns ErrCycle when targetId is below the source.
+	Merge(ctx context.Context, sourceId, targetId, actorId int) error
+	// Subtree returns id itself and, when subdepartments is set, the ids of
+	// every department below it in the hierarchy. It is empty, not nil, for
+	// an unknown id, so that filters by it match nothing.
+	Subtree(ctx context.Context, id int, subdepartments bool) ([]int, error)
+}
+
+type AgreementRepository interface {
+	// Inbox returns a page of agreements addressed to departments headed by
+	// the employee, falling back to the DEP_HEAD and ADMIN roles for
+	// departments without an explicit head. Newest first by default.
+	Inbox(ctx context.Context, employeeId int, filter models.AgreementFilter) ([]models.Agreement, models.Page, error)
+	Create(ctx context.Context, agreement models.Agreement) (int, error)
+	Get(ctx context.Context, id int) (models.Agreement, error)
+	MarkViewed(ctx context.Context, id int) error
+	// Holds reports whether the inbox of employeeId, with sub-departments,
+	// holds agreement id: it returns ErrNotAllowed when it does not.
+	Holds(ctx context.Context, id, employeeId int) error
+	// Decide records the decision of employeeId on an undecided agreement.
+	// Only the employees whose inbox, with sub-departments, holds the
+	// agreement may decide it: otherwise it returns ErrNotAllowed, and
+	// ErrConflict once the agreement is decided.
+	Decide(ctx context.Context, id, employeeId int, agree bool) error
+}
+
+// RetentionRepository keeps the retention rules and the destruction acts
+// that remove letters past their retention period.
+type RetentionRepository interface {
+	// Rules returns every rule ordered by document type.
+	Rules(ctx context.Context) ([]models.RetentionRule, error)
+	// SetRule creates or replaces the rule of rule.DocumentTypeId and
+	// returns its id.
+	SetRule(ctx context.Context, rule models.RetentionRule) (int, error)
+	DeleteRule(ctx context.Context, documentTypeId int) error
+	// Overdue returns a page of the letters past their retention period,
+	// that of their case or else of their document type, that are neither
+	// destroyed nor in an open act, the longest overdue first by default.
+	Overdue(ctx context.Context, filter models.RetentionFilter) ([]models.Letter, models.Page, error)
+	// CreateAct stores a draft act made by act.CreatedBy and returns its
+	// id, or ErrNotDue if a letter is not overdue.
+	CreateAct(ctx context.Context, act models.DestructionAct) (int, error)
+	Act(ctx context.Context, id int) (models.DestructionAct, error)
+	// Acts returns a page of acts, newest first by default.
+	Acts(ctx context.Context, filter models.DestructionActFilter) ([]models.DestructionAct, models.Page, error)
+	// SubmitAct makes a draft act pending and addresses an agreement on
+	// each of its letters to its department.
+	SubmitAct(ctx context.Context, id int) error
+	// SettleAct approves or rejects a pending act once its agreements are
+	// decided, and returns its status.
+	SettleAct(ctx context.Context, id int) (string, error)
+	// ExecuteAct destroys the letters of an approved act, keeping their
+	// registration data, and marks it executed.
+	ExecuteAct(ctx context.Context, id int) error
+}
+
+// CaseRepository keeps the nomenclature of cases and files letters into
+// them. Letters in the trash are left out of the letter counts.
+type CaseRepository interface {
+	// List returns a page of cases matching filter, newest first by
+	// default.
+	List(ctx context.Context, filter models.CaseFilter) ([]models.Case, models.Page, error)
+	Get(ctx context.Context, id int) (models.Case, error)
+	// Create stores an open case and returns its id, or ErrDuplicate if
+	// the department already has a case with the index in the year.
+	Create(ctx context.Context, c models.Case) (int, error)
+	// SetClosed closes or reopens a case.
+	SetClosed(ctx context.Context, id int, closed bool) error
+	// File moves a letter into case caseId and bumps its version. It
+	// returns ErrCaseClosed if that case or the one the letter is in is
+	// closed.
+	File(ctx context.Context, letterId, caseId int) error
+}
+
+// HealthRepository reports whether the storage can serve requests.
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	// Migrations lists the embedded migrations with AppliedAt set on the
+	// applied ones.
+	Migrations(ctx context.Context) ([]db.Migration, error)
+}
+
+// StatsRepository counts the business state exported as metrics.
+type StatsRepository interface {
+	// Stats counts undecided agreements and assignments past their due
+	// date whose letter is not distributed yet.
+	Stats(ctx context.Context) (models.Stats, error)
+}
+
+// IdempotencyRepository remembers requests sent with an Idempotency-Key
+// until they expire. Keys are scoped to the employee sending them.
+type IdempotencyRepository interface {
+	// Begin records request as in progress and returns found false, unless
+	// an unexpired request with the same key exists, which it returns
+	// instead. Expired requests are deleted.
+	Begin(ctx context.Context, request models.IdempotentRequest) (stored models.IdempotentRequest, found bool, err error)
+	// Complete stores the status, headers and body of a begun request.
+	Complete(ctx context.Context, request models.IdempotentRequest) error
+	// Release forgets a begun request, so that a retry runs it again.
+	Release(ctx context.Context, employeeId int, key string) error
+}
+
+// AuditRepository appends to and reads the audit log, which is never
+// changed otherwise.
+type AuditRepository interface {
+	// Append stores entry after the last one, with PreviousHash set to the
+	// hash of the last entry and Hash computed by AuditHash. Appends are
+	// serialized, so that the chain does not fork.
+	Append(ctx context.Context, entry models.AuditEntry) error
+	// List returns a page of entries matching filter, newest first by
+	// default.
+	List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, models.Page, error)
+}
+
+// Repositories bundles every repository a handler may need.
+type Repositories struct {
+	Letters     LetterRepository
+	Employees   EmployeeRepository
+	Departments DepartmentRepository
+	Agreements  AgreementRepository
+	Imports     importer.Store
+	Health      HealthRepository
+	Stats       StatsRepository
+	Idempotency IdempotencyRepository
+	Audit       AuditRepository
+	Retention   RetentionRepository
+	Cases       CaseRepository
+}
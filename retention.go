@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sed/db"
+	"sed/models"
+	"sed/repository"
+	"sed/repository/postgres"
+	"text/tabwriter"
+)
+
+// runRetention implements "sed retention", the job listing the letters
+// past their retention period that no destruction act covers yet, for
+// cron. It exits with 3 when there are any, so that the job can alert.
+func runRetention(args []string) int {
+	flags := flag.NewFlagSet("retention", flag.ContinueOnError)
+	documentTypeId := flags.Int("document-type", 0, "list only letters of this document type id")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx := context.Background()
+
+	settings, err := loadDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	pool, err := db.Connect(ctx, settings)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer pool.Close()
+
+	overdue, err := printOverdue(ctx, os.Stdout, postgres.New(pool).Retention, models.RetentionFilter{DocumentTypeId: *documentTypeId})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if overdue > 0 {
+		return 3
+	}
+
+	return 0
+}
+
+// printOverdue writes a table of the overdue letters matching filter to w,
+// a page at a time, and returns how many there are.
+func printOverdue(ctx context.Context, w io.Writer, retention repository.RetentionRepository, filter models.RetentionFilter) (int, error) {
+	filter.RowsLimit = 500
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tREGISTRATION NUMBER\tTYPE\tNAME\tRETAIN UNTIL")
+
+	overdue := 0
+	for {
+		letters, page, err := retention.Overdue(ctx, filter)
+		if err != nil {
+			return overdue, err
+		}
+
+		for _, letter := range letters {
+			overdue++
+			fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\n", letter.Id, letter.RegistrationNumber, letter.DocumentType.Type,
+				letter.Name, letter.RetainUntil.Format("2006-01-02"))
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	return overdue, table.Flush()
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// oldLetter stores a letter of documentTypeId entered years ago.
+func (s *testServer) oldLetter(documentTypeId int, name string, years int) int {
+	return s.store.AddLetter(models.Letter{
+		Name:               name,
+		Sender:             "Ministry of Finance",
+		DocumentTypeId:     documentTypeId,
+		RegistrationNumber: "01-05/" + name,
+		EntryDate:          time.Now().AddDate(-years, 0, -1),
+		Content:            letterContent,
+		Version:            1,
+	})
+}
+
+// decideAll decides every agreement in the inbox of token.
+func (s *testServer) decideAll(token string, agreed bool) {
+	s.t.Helper()
+
+	var agreements []models.Agreement
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements", token, nil), &agreements)
+	for _, agreement := range agreements {
+		s.ok(s.do(http.MethodPatch, "/api/v2/agreements/"+strconv.Itoa(agreement.Id), token, models.AgreementDecision{Agreed: &agreed}), nil)
+	}
+}
+
+func TestRetentionRules(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	documentTypeId := s.documentTypeId(token)
+	path := "/api/v2/document-types/" + strconv.Itoa(documentTypeId) + "/retention"
+
+	s.invalid(s.do(http.MethodPut, path, token, models.RetentionRule{}), "years", "required")
+	s.fails(s.do(http.MethodPut, "/api/v2/document-types/9999/retention", token, models.RetentionRule{Years: 5}), apperr.DocumentTypeNotFound)
+
+	s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+	s.fails(s.do(http.MethodPut, path, s.login("clerk@example.com"), models.RetentionRule{Years: 5}), apperr.Forbidden)
+
+	s.ok(s.do(http.MethodPut, path, token, models.RetentionRule{Years: 5, Article: "Art. 12"}), nil)
+	s.ok(s.do(http.MethodPut, path, token, models.RetentionRule{Years: 10, Article: "Art. 14"}), nil)
+
+	var rules []models.RetentionRule
+	s.ok(s.do(http.MethodGet, "/api/v2/retention-rules", token, nil), &rules)
+	if len(rules) != 1 || rules[0].Years != 10 || rules[0].DocumentType.Label == "" {
+		t.Fatalf("rules %+v", rules)
+	}
+
+	s.ok(s.do(http.MethodDelete, path, token, nil), nil)
+	s.fails(s.do(http.MethodDelete, path, token, nil), apperr.RetentionRuleNotFound)
+}
+
+func TestDestructionAct(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	documentTypeId := s.documentTypeId(token)
+	s.ok(s.do(http.MethodPut, "/api/v2/document-types/"+strconv.Itoa(documentTypeId)+"/retention", token, models.RetentionRule{Years: 5}), nil)
+
+	commission := s.department(token, models.Department{Name: "Expert commission"})
+	s.ok(s.do(http.MethodPut, "/department/heads", token,
+		models.Department{Id: commission, HeadId: s.addEmployee("head@example.com", "DEP_HEAD", commission)}), nil)
+	head := s.login("head@example.com")
+
+	old := s.oldLetter(documentTypeId, "Budget proposal", 6)
+	recent := s.oldLetter(documentTypeId, "Budget report", 2)
+
+	var overdue []models.Letter
+	s.ok(s.do(http.MethodGet, "/api/v2/retention/overdue", token, nil), &overdue)
+	if len(overdue) != 1 || overdue[0].Id != old || overdue[0].RetainUntil == nil || overdue[0].RetainUntil.After(time.Now()) {
+		t.Fatalf("overdue %+v", overdue)
+	}
+
+	s.fails(s.do(http.MethodPost, "/api/v2/destruction-acts", token, models.DestructionAct{DepartmentId: commission, LetterIds: []int{old, recent}}), apperr.LetterNotDue)
+
+	var act models.DestructionAct
+	s.created(s.do(http.MethodPost, "/api/v2/destruction-acts", token, models.DestructionAct{DepartmentId: commission, LetterIds: []int{old}}), &act)
+	if act.Status != models.ActDraft {
+		t.Fatalf("new act %+v", act)
+	}
+	path := "/api/v2/destruction-acts/" + strconv.Itoa(act.Id)
+
+	// A letter is in one open act at a time.
+	s.ok(s.do(http.MethodGet, "/api/v2/retention/overdue", token, nil), &overdue)
+	if len(overdue) != 0 {
+		t.Errorf("overdue %+v while in an act", overdue)
+	}
+	s.fails(s.do(http.MethodPost, "/api/v2/destruction-acts", token, models.DestructionAct{DepartmentId: commission, LetterIds: []int{old}}), apperr.LetterNotDue)
+
+	s.fails(s.do(http.MethodPost, path+"/execute", token, nil), apperr.DestructionActStatus)
+	s.ok(s.do(http.MethodPost, path+"/submit", token, nil), &act)
+	if act.Status != models.ActPending || act.SubmittedAt == nil {
+		t.Fatalf("submitted act %+v", act)
+	}
+	s.fails(s.do(http.MethodPost, path+"/submit", token, nil), apperr.DestructionActStatus)
+
+	var agreements []models.Agreement
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements", head, nil), &agreements)
+	if len(agreements) != 1 || agreements[0].DestructionActId != act.Id || agreements[0].Letter.Id != old {
+		t.Fatalf("inbox %+v", agreements)
+	}
+
+	// Only the commission approves its destruction agreements.
+	agreed := true
+	s.addEmployee("clerk@example.com", "EMPLOYEE", commission)
+	s.fails(s.do(http.MethodPatch, "/api/v2/agreements/"+strconv.Itoa(agreements[0].Id), s.login("clerk@example.com"),
+		models.AgreementDecision{Agreed: &agreed}), apperr.Forbidden)
+	s.ok(s.do(http.MethodGet, path, token, nil), &act)
+	if act.Status != models.ActPending {
+		t.Fatalf("act %s after a refused decision", act.Status)
+	}
+
+	s.decideAll(head, true)
+
+	s.ok(s.do(http.MethodGet, path, token, nil), &act)
+	if act.Status != models.ActApproved {
+		t.Fatalf("act %s after the agreement", act.Status)
+	}
+
+	s.ok(s.do(http.MethodPost, path+"/execute", token, nil), &act)
+	if act.Status != models.ActExecuted || act.ExecutedAt == nil {
+		t.Errorf("executed act %+v", act)
+	}
+
+	// The letter stays as a stub without content.
+	var stub models.Letter
+	s.ok(s.do(http.MethodGet, "/api/v2/letters/"+strconv.Itoa(old), token, nil), &stub)
+	if stub.Content != "" || stub.DestroyedAt == nil || stub.DestructionActId != act.Id || stub.RegistrationNumber != "01-05/Budget proposal" {
+		t.Errorf("stub %+v", stub)
+	}
+	s.fails(s.do(http.MethodPatch, "/api/v2/letters/"+strconv.Itoa(old), token, map[string]interface{}{"content": letterContent, "version": stub.Version}), apperr.LetterDestroyed)
+
+	var entries []models.AuditEntry
+	s.ok(s.do(http.MethodGet, "/api/v2/audit?entity=letter&entity_id="+strconv.Itoa(old), token, nil), &entries)
+	if len(entries) != 1 || entries[0].Action != "destroy" {
+		t.Fatalf("letter entries %+v", entries)
+	}
+	if _, ok := entries[0].Changes["content"]; ok {
+		t.Error("destroyed content kept in the audit log")
+	}
+}
+
+func TestRejectedDestructionAct(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	documentTypeId := s.documentTypeId(token)
+	s.ok(s.do(http.MethodPut, "/api/v2/document-types/"+strconv.Itoa(documentTypeId)+"/retention", token, models.RetentionRule{Years: 5}), nil)
+
+	commission := s.department(token, models.Department{Name: "Expert commission"})
+	s.ok(s.do(http.MethodPut, "/department/heads", token,
+		models.Department{Id: commission, HeadId: s.addEmployee("head@example.com", "DEP_HEAD", commission)}), nil)
+
+	first := s.oldLetter(documentTypeId, "Budget proposal", 6)
+	second := s.oldLetter(documentTypeId, "Budget report", 7)
+
+	var act models.DestructionAct
+	s.created(s.do(http.MethodPost, "/api/v2/destruction-acts", token, models.DestructionAct{DepartmentId: commission, LetterIds: []int{first, second}}), &act)
+	s.ok(s.do(http.MethodPost, "/api/v2/destruction-acts/"+strconv.Itoa(act.Id)+"/submit", token, nil), nil)
+	s.decideAll(s.login("head@example.com"), false)
+
+	var acts []models.DestructionAct
+	s.ok(s.do(http.MethodGet, "/api/v2/destruction-acts?status=rejected", token, nil), &acts)
+	if len(acts) != 1 || acts[0].Id != act.Id || len(acts[0].LetterIds) != 2 {
+		t.Fatalf("rejected acts %+v", acts)
+	}
+	s.fails(s.do(http.MethodPost, "/api/v2/destruction-acts/"+strconv.Itoa(act.Id)+"/execute", token, nil), apperr.DestructionActStatus)
+
+	// The letters of a rejected act are due again, the longest overdue
+	// first.
+	var overdue []models.Letter
+	s.ok(s.do(http.MethodGet, "/api/v2/retention/overdue", token, nil), &overdue)
+	if len(overdue) != 2 || overdue[0].Id != second {
+		t.Errorf("overdue %+v", overdue)
+	}
+
+	var out bytes.Buffer
+	count, err := printOverdue(context.Background(), &out, s.store.Repositories().Retention, models.RetentionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || !strings.Contains(out.String(), "01-05/Budget report") {
+		t.Errorf("printed %d:\n%s", count, out.String())
+	}
+}
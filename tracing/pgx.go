@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// StatementNameKey names the statement by the repository method that sent
+// it, such as "Agreements.Inbox".
+const StatementNameKey = attribute.Key("db.statement.name")
+
+// repositoryPackage is where statements are named after.
+const repositoryPackage = "sed/repository/postgres."
+
+// QueryTracer records a span for every query pgx reports. pgx v4 has no
+// tracing hooks, so it is installed as the connection logger, which pgx
+// calls once a query has finished with the time it took.
+type QueryTracer struct{}
+
+func (QueryTracer) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	sql, ok := data["sql"].(string)
+	if !ok || !trace.SpanFromContext(ctx).IsRecording() {
+		return
+	}
+
+	end := time.Now()
+	start := end
+	if took, ok := data["time"].(time.Duration); ok {
+		start = end.Add(-took)
+	}
+
+	operation := msg
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+
+	name := statementName()
+	if name == "" {
+		name = operation
+	}
+
+	_, span := tracer().Start(ctx, "postgres "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationKey.String(operation),
+			semconv.DBStatementKey.String(sql),
+			StatementNameKey.String(name),
+		),
+	)
+
+	if err, ok := data["err"].(error); ok {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End(trace.WithTimestamp(end))
+}
+
+// statementName finds the repository method on the call stack, or returns
+// "" when the query was not sent by a repository.
+func statementName() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+
+	for {
+		frame, more := frames.Next()
+		if name := repositoryMethod(frame.Function); name != "" {
+			return name
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// repositoryMethod turns "sed/repository/postgres.(*Departments).Merge.func1"
+// into "Departments.Merge".
+func repositoryMethod(function string) string {
+	if !strings.HasPrefix(function, repositoryPackage) {
+		return ""
+	}
+
+	parts := strings.Split(strings.TrimPrefix(function, repositoryPackage), ".")
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "(") {
+		return ""
+	}
+
+	receiver := strings.TrimSuffix(strings.TrimPrefix(parts[0], "(*"), ")")
+	return receiver + "." + parts[1]
+}
@@ -0,0 +1,80 @@
+// Package tracing sets up OpenTelemetry: a span for every HTTP request and
+// a child span for every query sent through pgx.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"os"
+	"sed/config"
+)
+
+const instrumentation = "sed"
+
+// propagator reads and writes W3C trace context and baggage headers.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Setup installs the global tracer provider and W3C propagators configured
+// by settings. The returned function flushes pending spans; call it before
+// exiting. With the none exporter nothing is recorded.
+func Setup(ctx context.Context, settings config.Tracing) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagator)
+
+	var (
+		exporter sdktrace.SpanExporter
+		file     io.WriteCloser
+	)
+	switch settings.Exporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		options := []otlptracehttp.Option{}
+		if settings.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(settings.Endpoint))
+		}
+		if settings.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		file, err = os.OpenFile(settings.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err == nil {
+			exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		}
+	default:
+		err = fmt.Errorf("unknown trace exporter %q", settings.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(settings.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(settings.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			_ = file.Close()
+		}
+		return err
+	}, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
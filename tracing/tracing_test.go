@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"os"
+	"path/filepath"
+	"sed/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+// record installs a tracer provider recording every span until the test ends.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+
+	provider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+
+	return recorder
+}
+
+func TestRepositoryMethod(t *testing.T) {
+	tests := []struct {
+		function string
+		want     string
+	}{
+		{"sed/repository/postgres.(*Agreements).Inbox", "Agreements.Inbox"},
+		{"sed/repository/postgres.(*Departments).Merge.func1", "Departments.Merge"},
+		{"sed/repository/postgres.notFound", ""},
+		{"sed/repository/postgres.New.func1", ""},
+		{"sed/handlers.(*Handler).GetAgreements", ""},
+	}
+
+	for _, tt := range tests {
+		if got := repositoryMethod(tt.function); got != tt.want {
+			t.Errorf("repositoryMethod(%q) = %q, want %q", tt.function, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTracer(t *testing.T) {
+	recorder := record(t)
+
+	QueryTracer{}.Log(context.Background(), pgx.LogLevelInfo, "Query", map[string]interface{}{"sql": "select 1"})
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Fatalf("query outside a trace recorded %d spans", len(spans))
+	}
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+
+	QueryTracer{}.Log(ctx, pgx.LogLevelInfo, "Dialing PostgreSQL server", map[string]interface{}{"host": "localhost"})
+	QueryTracer{}.Log(ctx, pgx.LogLevelInfo, "Query", map[string]interface{}{
+		"sql":  "\n  select id from letters where id = $1",
+		"time": 30 * time.Millisecond,
+	})
+	QueryTracer{}.Log(ctx, pgx.LogLevelError, "Exec", map[string]interface{}{
+		"sql": "update letters set name = $1",
+		"err": errors.New("deadlock detected"),
+	})
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+
+	query, exec := spans[0], spans[1]
+	if query.Name() != "postgres SELECT" || query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("unexpected query span %q with parent %s", query.Name(), query.Parent().SpanID())
+	}
+	if took := query.EndTime().Sub(query.StartTime()); took != 30*time.Millisecond {
+		t.Errorf("query span took %s", took)
+	}
+
+	attributes := map[attribute.Key]string{}
+	for _, kv := range query.Attributes() {
+		attributes[kv.Key] = kv.Value.Emit()
+	}
+	if attributes["db.system"] != "postgresql" || attributes["db.operation"] != "SELECT" || !strings.Contains(attributes["db.statement"], "from letters") || attributes[StatementNameKey] != "SELECT" {
+		t.Errorf("unexpected attributes %v", attributes)
+	}
+
+	if exec.Status().Code != codes.Error || exec.Status().Description != "deadlock detected" {
+		t.Errorf("failed exec has status %+v", exec.Status())
+	}
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	provider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+
+	file := filepath.Join(t.TempDir(), "traces.json")
+
+	shutdown, err := Setup(context.Background(), config.Tracing{Exporter: "file", File: file, SampleRatio: 1, ServiceName: "sed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := tracer().Start(context.Background(), "POST /letters")
+	span.End()
+
+	err = shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Name":"POST /letters"`) {
+		t.Errorf("span was not exported: %s", data)
+	}
+}
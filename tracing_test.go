@@ -0,0 +1,45 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"sed/apperr"
+	"testing"
+)
+
+func TestRequestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+
+	s := newTestServer(t)
+	logs := s.captureLogs(s.store.Repositories())
+
+	const (
+		traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanId  = "00f067aa0ba902b7"
+	)
+	s.fails(s.request(http.MethodPost, "/letter/7", map[string]string{"traceparent": "00-" + traceId + "-" + spanId + "-01"}, nil), apperr.Unauthorized)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "POST /letter/:id" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("unexpected span %q of kind %s", span.Name(), span.SpanKind())
+	}
+	if span.SpanContext().TraceID().String() != traceId || span.Parent().SpanID().String() != spanId {
+		t.Errorf("span does not continue the caller's trace: %s", span.SpanContext().TraceID())
+	}
+
+	lines := logs()
+	if len(lines) != 1 || lines[0].TraceId != traceId || lines[0].SpanId != span.SpanContext().SpanID().String() {
+		t.Errorf("log lines lack the trace %+v", lines)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"sed/apperr"
+	"sed/models"
+	"sed/repository/memory"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLetterTrash(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	s.ok(s.do(http.MethodPut, "/department/heads", token,
+		models.Department{Id: finance, HeadId: s.addEmployee("head@example.com", "DEP_HEAD", finance)}), nil)
+
+	id := s.letter(token, "Budget proposal")
+	kept := s.letter(token, "Budget report")
+	s.ok(s.do(http.MethodPost, "/letters/agreement", token, models.Agreement{LetterId: id, DepartmentId: finance}), nil)
+	path := "/api/v2/letters/" + strconv.Itoa(id)
+
+	s.invalid(s.do(http.MethodDelete, path, token, models.LetterDeletion{}), "reason", "required")
+
+	s.addEmployee("clerk@example.com", "EMPLOYEE", 0)
+	clerk := s.login("clerk@example.com")
+	s.fails(s.do(http.MethodDelete, path, clerk, models.LetterDeletion{Reason: "Registered twice"}), apperr.Forbidden)
+	s.ok(s.do(http.MethodDelete, path, token, models.LetterDeletion{Reason: "Registered twice"}), nil)
+	s.fails(s.do(http.MethodDelete, path, token, models.LetterDeletion{Reason: "Registered twice"}), apperr.LetterNotFound)
+
+	// The letter is gone everywhere but the trash.
+	var letters []models.Letter
+	s.ok(s.do(http.MethodGet, "/api/v2/letters?name=budget", token, nil), &letters)
+	if len(letters) != 1 || letters[0].Id != kept {
+		t.Errorf("letters after a delete %+v", letters)
+	}
+	s.fails(s.do(http.MethodGet, path, token, nil), apperr.LetterNotFound)
+	s.fails(s.do(http.MethodPatch, path, token, map[string]interface{}{"sender": "Tax Committee", "version": 2}), apperr.LetterNotFound)
+
+	var agreements []models.Agreement
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements", s.login("head@example.com"), nil), &agreements)
+	if len(agreements) != 0 {
+		t.Errorf("inbox still lists %+v", agreements)
+	}
+
+	s.fails(s.do(http.MethodGet, "/api/v2/trash/letters", clerk, nil), apperr.Forbidden)
+	var trash []models.Letter
+	s.ok(s.do(http.MethodGet, "/api/v2/trash/letters", token, nil), &trash)
+	if len(trash) != 1 || trash[0].Id != id || trash[0].DeleteReason != "Registered twice" || trash[0].DeletedAt == nil {
+		t.Fatalf("trash %+v", trash)
+	}
+
+	// Within the retention period the letter can only be restored.
+	s.fails(s.do(http.MethodDelete, "/api/v2/trash/letters/"+strconv.Itoa(id), token, nil), apperr.LetterRetained)
+
+	var restored models.Letter
+	s.ok(s.do(http.MethodPost, "/api/v2/trash/letters/"+strconv.Itoa(id)+"/restore", token, nil), &restored)
+	if restored.Id != id || restored.DeletedAt != nil {
+		t.Errorf("restored %+v", restored)
+	}
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements", s.login("head@example.com"), nil), &agreements)
+	if len(agreements) != 1 {
+		t.Errorf("%d agreements after a restore", len(agreements))
+	}
+
+	var entries []models.AuditEntry
+	s.ok(s.do(http.MethodGet, "/api/v2/audit?entity=letter&entity_id="+strconv.Itoa(id), token, nil), &entries)
+	if len(entries) != 3 || entries[0].Action != "restore" || entries[1].Action != "delete" {
+		t.Fatalf("letter entries %+v", entries)
+	}
+	if reason := entries[1].Changes["delete_reason"]; reason.After != "Registered twice" {
+		t.Errorf("delete entry %+v", entries[1].Changes)
+	}
+}
+
+func TestLetterPurge(t *testing.T) {
+	store := memory.New()
+	settings := testSettings()
+	settings.Storage.TrashRetention = time.Millisecond
+	s := &testServer{t: t, store: store, router: newTestRouter(store.Repositories(), settings)}
+	token := s.admin()
+
+	id := s.letter(token, "Budget proposal")
+	path := "/api/v2/trash/letters/" + strconv.Itoa(id)
+
+	s.fails(s.do(http.MethodDelete, path, token, nil), apperr.LetterNotFound)
+
+	s.ok(s.do(http.MethodDelete, "/api/v2/letters/"+strconv.Itoa(id), token, models.LetterDeletion{Reason: "Sent by mistake"}), nil)
+	time.Sleep(2 * time.Millisecond)
+	s.ok(s.do(http.MethodDelete, path, token, nil), nil)
+	s.fails(s.do(http.MethodPost, path+"/restore", token, nil), apperr.LetterNotFound)
+
+	// The audit log still tells what the letter was.
+	var entries []models.AuditEntry
+	s.ok(s.do(http.MethodGet, "/api/v2/audit?entity=letter&entity_id="+strconv.Itoa(id), token, nil), &entries)
+	if len(entries) != 3 || entries[0].Action != "purge" || entries[0].Changes["name"].Before != "Budget proposal" {
+		t.Errorf("letter entries %+v", entries)
+	}
+
+	// A letter listed in a destruction act is kept with the act.
+	documentTypeId := s.documentTypeId(token)
+	s.ok(s.do(http.MethodPut, "/api/v2/document-types/"+strconv.Itoa(documentTypeId)+"/retention", token, models.RetentionRule{Years: 5}), nil)
+	old := s.oldLetter(documentTypeId, "Budget report", 6)
+	chancellery := s.department(token, models.Department{Name: "Chancellery"})
+	s.ok(s.do(http.MethodPut, "/department/heads", token,
+		models.Department{Id: chancellery, HeadId: s.addEmployee("head@example.com", "DEP_HEAD", chancellery)}), nil)
+	var act models.DestructionAct
+	s.created(s.do(http.MethodPost, "/api/v2/destruction-acts", token,
+		models.DestructionAct{DepartmentId: chancellery, LetterIds: []int{old}}), &act)
+
+	s.ok(s.do(http.MethodDelete, "/api/v2/letters/"+strconv.Itoa(old), token, models.LetterDeletion{Reason: "Sent by mistake"}), nil)
+	time.Sleep(2 * time.Millisecond)
+	s.fails(s.do(http.MethodDelete, "/api/v2/trash/letters/"+strconv.Itoa(old), token, nil), apperr.LetterInAct)
+
+	s.ok(s.do(http.MethodGet, "/api/v2/destruction-acts/"+strconv.Itoa(act.Id), token, nil), &act)
+	if len(act.LetterIds) != 1 || act.LetterIds[0] != old {
+		t.Errorf("act after a refused purge %+v", act)
+	}
+
+	// Also once the act is closed, which keeps its letters on record.
+	s.ok(s.do(http.MethodPost, "/api/v2/trash/letters/"+strconv.Itoa(old)+"/restore", token, nil), nil)
+	s.ok(s.do(http.MethodPost, "/api/v2/destruction-acts/"+strconv.Itoa(act.Id)+"/submit", token, nil), nil)
+	s.decideAll(s.login("head@example.com"), false)
+	s.ok(s.do(http.MethodDelete, "/api/v2/letters/"+strconv.Itoa(old), token, models.LetterDeletion{Reason: "Sent by mistake"}), nil)
+	time.Sleep(2 * time.Millisecond)
+	s.fails(s.do(http.MethodDelete, "/api/v2/trash/letters/"+strconv.Itoa(old), token, nil), apperr.LetterInAct)
+
+	s.ok(s.do(http.MethodGet, "/api/v2/destruction-acts/"+strconv.Itoa(act.Id), token, nil), &act)
+	if act.Status != models.ActRejected || len(act.LetterIds) != 1 || act.LetterIds[0] != old {
+		t.Errorf("rejected act after a refused purge %+v", act)
+	}
+}
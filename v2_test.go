@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sed/apperr"
+	"sed/handlers"
+	"sed/models"
+	"strconv"
+	"testing"
+)
+
+// created fails the test unless response is a 201 and decodes its payload
+// into v when v is not nil.
+func (s *testServer) created(response envelope, v interface{}) {
+	s.t.Helper()
+
+	if response.Code != http.StatusCreated {
+		s.t.Fatalf("code %d: %s", response.Code, response.Message)
+	}
+
+	response.Code = http.StatusOK
+	s.ok(response, v)
+}
+
+func TestV2Letters(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	for i := 0; i < handlers.DefaultRowsLimit+1; i++ {
+		s.letter(token, "Budget "+strconv.Itoa(i))
+	}
+
+	var letters []models.Letter
+	s.ok(s.do(http.MethodGet, "/api/v2/letters", token, nil), &letters)
+	if len(letters) != handlers.DefaultRowsLimit {
+		t.Errorf("%d letters without rows_limit", len(letters))
+	}
+
+	s.ok(s.do(http.MethodGet, "/api/v2/letters?rows_limit=5&rows_offset=20", token, nil), &letters)
+	if len(letters) != 1 {
+		t.Errorf("%d letters on the last page", len(letters))
+	}
+
+	s.fails(s.do(http.MethodGet, "/api/v2/letters?rows_limit=many", token, nil), apperr.MalformedQuery)
+	s.invalid(s.do(http.MethodGet, "/api/v2/letters?rows_limit=0", token, nil), "rows_limit", "required")
+
+	letter := models.Letter{
+		Name:           "Audit request",
+		Sender:         "Ministry of Finance",
+		DocumentTypeId: s.documentTypeId(token),
+		Content:        "Please schedule the annual audit.",
+	}
+	data, _ := json.Marshal(letter)
+	request := httptest.NewRequest(http.MethodPost, "/api/v2/letters", bytes.NewReader(data))
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("POST /api/v2/letters: status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var response struct {
+		Payload models.Letter `json:"payload"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := response.Payload.Id
+	if location := recorder.Header().Get("Location"); location != "/api/v2/letters/"+strconv.Itoa(id) {
+		t.Errorf("Location %q for letter %d", location, id)
+	}
+
+	var patched models.Letter
+	s.ok(s.do(http.MethodPatch, "/api/v2/letters/"+strconv.Itoa(id), token, map[string]interface{}{"sender": "Tax Committee", "version": 1}), &patched)
+	if patched.Sender != "Tax Committee" || patched.Name != letter.Name || patched.Content != letter.Content {
+		t.Errorf("PATCH did not merge %+v", patched)
+	}
+
+	s.invalid(s.do(http.MethodPatch, "/api/v2/letters/"+strconv.Itoa(id), token, map[string]string{"name": "x"}), "name", "min")
+	s.fails(s.do(http.MethodPatch, "/api/v2/letters/"+strconv.Itoa(id+100), token, map[string]string{"sender": "x"}), apperr.LetterNotFound)
+	s.fails(s.do(http.MethodGet, "/api/v2/letters/"+strconv.Itoa(id+100), token, nil), apperr.LetterNotFound)
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	executive := s.addEmployee("executive@example.com", "EMPLOYEE", finance)
+	s.created(s.do(http.MethodPost, "/api/v2/letters/"+strconv.Itoa(id)+"/assignments", token,
+		models.DescribedLetter{DepartmentId: finance, ExecutiveEmployee: executive}), nil)
+}
+
+func TestV2Users(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	legal := s.department(token, models.Department{Name: "Legal"})
+	accountant := s.addEmployee("accountant@example.com", "EMPLOYEE", finance)
+	s.addEmployee("lawyer@example.com", "EMPLOYEE", legal)
+
+	var employees []models.Employee
+	s.ok(s.do(http.MethodGet, "/api/v2/users?email=ACCOUNTANT", token, nil), &employees)
+	if len(employees) != 1 || employees[0].Id != accountant {
+		t.Errorf("email filter %+v", employees)
+	}
+
+	s.ok(s.do(http.MethodGet, "/api/v2/users?department=leg", token, nil), &employees)
+	if len(employees) != 1 || employees[0].Email != "lawyer@example.com" {
+		t.Errorf("department filter %+v", employees)
+	}
+
+	var patched models.Employee
+	s.ok(s.do(http.MethodPatch, "/api/v2/users/"+strconv.Itoa(accountant), token, map[string]int{"department_id": legal}), &patched)
+	if patched.DepartmentId != legal || patched.Email != "accountant@example.com" {
+		t.Errorf("PATCH did not merge %+v", patched)
+	}
+
+	employee := s.login("lawyer@example.com")
+	s.fails(s.do(http.MethodPatch, "/api/v2/users/"+strconv.Itoa(accountant), employee, map[string]int{"department_id": finance}), apperr.Forbidden)
+	s.fails(s.do(http.MethodPatch, "/api/v2/users/"+strconv.Itoa(accountant+100), token, map[string]int{"department_id": finance}), apperr.EmployeeNotFound)
+}
+
+func TestV2Departments(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	var finance models.Department
+	s.created(s.do(http.MethodPost, "/api/v2/departments", token, models.Department{Name: "Finance", Phone: "100"}), &finance)
+	if finance.Id == 0 || finance.Name != "Finance" {
+		t.Fatalf("created %+v", finance)
+	}
+	path := "/api/v2/departments/" + strconv.Itoa(finance.Id)
+
+	var patched models.Department
+	s.ok(s.do(http.MethodPatch, path, token, map[string]interface{}{"name": "Finance and budget", "version": finance.Version}), &patched)
+	if patched.Name != "Finance and budget" || patched.Phone != "100" {
+		t.Errorf("PATCH did not merge %+v", patched)
+	}
+
+	legal := s.department(token, models.Department{Name: "Legal"})
+	var moved models.Department
+	s.ok(s.do(http.MethodPut, path+"/parent", token, models.DepartmentParent{ParentId: legal}), &moved)
+	if moved.ParentId != legal {
+		t.Errorf("not moved %+v", moved)
+	}
+	s.fails(s.do(http.MethodPut, "/api/v2/departments/"+strconv.Itoa(legal)+"/parent", token, models.DepartmentParent{ParentId: finance.Id}), apperr.DepartmentCycle)
+
+	head := s.addEmployee("head@example.com", "EMPLOYEE", finance.Id)
+	var headed models.Department
+	s.ok(s.do(http.MethodPut, path+"/heads", token, models.DepartmentHeads{HeadId: head}), &headed)
+	if headed.HeadId != head {
+		t.Errorf("head not assigned %+v", headed)
+	}
+
+	s.fails(s.do(http.MethodDelete, path, token, nil), apperr.DepartmentNotEmpty)
+	s.invalid(s.do(http.MethodPost, path+"/merge", token, map[string]int{}), "target_id", "required")
+	s.ok(s.do(http.MethodPost, path+"/merge", token, models.DepartmentMergeTarget{TargetId: legal}), nil)
+	var merged models.Department
+	s.ok(s.do(http.MethodGet, path, token, nil), &merged)
+	if merged.ArchivedAt == nil {
+		t.Errorf("merged department is not archived %+v", merged)
+	}
+
+	empty := s.department(token, models.Department{Name: "Empty"})
+	s.ok(s.do(http.MethodDelete, "/api/v2/departments/"+strconv.Itoa(empty), token, nil), nil)
+}
+
+func TestV2Agreements(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+
+	finance := s.department(token, models.Department{Name: "Finance"})
+	s.ok(s.do(http.MethodPut, "/api/v2/departments/"+strconv.Itoa(finance)+"/heads", token,
+		models.DepartmentHeads{HeadId: s.addEmployee("head@example.com", "DEP_HEAD", finance)}), nil)
+	head := s.login("head@example.com")
+	letter := s.letter(token, "Budget proposal")
+
+	s.created(s.do(http.MethodPost, "/api/v2/agreements", token, models.Agreement{LetterId: letter, DepartmentId: finance}), nil)
+
+	var agreements []models.Agreement
+	s.ok(s.do(http.MethodGet, "/api/v2/agreements", head, nil), &agreements)
+	if len(agreements) != 1 {
+		t.Fatalf("inbox %+v", agreements)
+	}
+	path := "/api/v2/agreements/" + strconv.Itoa(agreements[0].Id)
+
+	s.invalid(s.do(http.MethodPatch, path, head, map[string]string{}), "agreed", "required_without")
+	s.invalid(s.do(http.MethodPatch, path, head, map[string]bool{"viewed": false}), "viewed", "eq")
+
+	// Reading an agreement does not mark it viewed; the PATCH does.
+	var agreement models.Agreement
+	s.ok(s.do(http.MethodGet, path, head, nil), &agreement)
+	if agreement.Viewed {
+		t.Errorf("viewed by a GET %+v", agreement)
+	}
+	s.ok(s.do(http.MethodPatch, path, head, map[string]bool{"viewed": true}), &agreement)
+	if !agreement.Viewed || agreement.Agreed || agreement.DecidedAt != nil {
+		t.Errorf("not viewed or decided %+v", agreement)
+	}
+
+	var decided models.Agreement
+	s.ok(s.do(http.MethodPatch, path, head, map[string]bool{"agreed": true}), &decided)
+	if !decided.Agreed {
+		t.Errorf("not agreed %+v", decided)
+	}
+}
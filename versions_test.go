@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sed/apperr"
+	"sed/models"
+	"strconv"
+	"testing"
+)
+
+// etag returns the ETag header of a GET of path.
+func (s *testServer) etag(path, token string) string {
+	s.t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		s.t.Fatalf("GET %s: status %d: %s", path, recorder.Code, recorder.Body.String())
+	}
+
+	return recorder.Header().Get("ETag")
+}
+
+func TestLetterVersions(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	path := "/api/v2/letters/" + strconv.Itoa(s.letter(token, "Budget proposal"))
+
+	etag := s.etag(path, token)
+	if etag != `"1"` {
+		t.Fatalf("ETag %q of a new letter", etag)
+	}
+
+	headers := map[string]string{"Authorization": "Bearer " + token, "If-Match": etag}
+	var patched models.Letter
+	s.ok(s.request(http.MethodPatch, path, headers, map[string]string{"sender": "Tax Committee"}), &patched)
+	if patched.Version != 2 || s.etag(path, token) != `"2"` {
+		t.Errorf("version not bumped %+v", patched)
+	}
+
+	// A second client still holding version 1 loses and gets the letter
+	// as it is now.
+	response := s.request(http.MethodPatch, path, headers, map[string]string{"sender": "Customs Committee"})
+	s.fails(response, apperr.VersionConflict)
+	var current models.Letter
+	err := json.Unmarshal(response.Payload, &current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Sender != "Tax Committee" || current.Version != 2 {
+		t.Errorf("conflict payload %+v", current)
+	}
+
+	s.fails(s.do(http.MethodPatch, path, token, map[string]string{"sender": "Customs Committee"}), apperr.VersionRequired)
+
+	// The header takes precedence over the body.
+	headers["If-Match"] = `"2"`
+	s.ok(s.request(http.MethodPatch, path, headers, map[string]interface{}{"sender": "Customs Committee", "version": 1}), &patched)
+	if patched.Sender != "Customs Committee" || patched.Version != 3 {
+		t.Errorf("If-Match ignored %+v", patched)
+	}
+}
+
+func TestDepartmentVersions(t *testing.T) {
+	s := newTestServer(t)
+	token := s.admin()
+	finance := s.department(token, models.Department{Name: "Finance"})
+	legal := s.department(token, models.Department{Name: "Legal"})
+	path := "/api/v2/departments/" + strconv.Itoa(finance)
+
+	s.ok(s.do(http.MethodPut, "/department", token, models.Department{Id: finance, Name: "Finance and budget", Version: 1}), nil)
+	s.fails(s.do(http.MethodPut, "/department", token, models.Department{Id: finance, Name: "Treasury", Version: 1}), apperr.VersionConflict)
+
+	// Moving a department changes it too.
+	s.ok(s.do(http.MethodPut, path+"/parent", token, models.DepartmentParent{ParentId: legal}), nil)
+	if etag := s.etag(path, token); etag != `"3"` {
+		t.Errorf("ETag %q after a move", etag)
+	}
+
+	s.fails(s.do(http.MethodPut, "/department", token, models.Department{Id: finance, Name: "Treasury"}), apperr.VersionRequired)
+	s.fails(s.do(http.MethodPut, "/department", token, models.Department{Id: finance + 100, Name: "Treasury", Version: 1}), apperr.DepartmentNotFound)
+}